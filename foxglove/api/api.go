@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -15,7 +16,12 @@ type Record interface {
 
 type TokenRequest struct {
 	ClientID   string `json:"clientId"`
-	DeviceCode string `json:"deviceCode"`
+	DeviceCode string `json:"deviceCode,omitempty"`
+	// Code, CodeVerifier and RedirectURI exchange an authorization code
+	// from a browser login in place of a device code.
+	Code         string `json:"code,omitempty"`
+	CodeVerifier string `json:"codeVerifier,omitempty"`
+	RedirectURI  string `json:"redirectUri,omitempty"`
 }
 
 type TokenResponse struct {
@@ -30,10 +36,19 @@ type UploadRequest struct {
 	DeviceName string `json:"device.name,omitempty"`
 	SessionID  string `json:"sessionId,omitempty"`
 	SessionKey string `json:"sessionKey,omitempty"`
+	// Resumable requests a link that starts a resumable upload session. It
+	// depends on server support: a server that does not recognize it ignores
+	// it, and returns a link for a single PUT.
+	Resumable bool `json:"resumable,omitempty"`
 }
 
 type UploadResponse struct {
 	Link string `json:"link"`
+	// Resumable reports whether Link starts a resumable upload session,
+	// rather than accepting the object in a single PUT. It is unset by
+	// servers without resumable upload support, so that the client falls
+	// back to a single PUT.
+	Resumable bool `json:"resumable,omitempty"`
 }
 
 type StreamRequest struct {
@@ -227,6 +242,8 @@ type RecordingsRequest struct {
 	SortOrder    string `json:"sortOrder" form:"sortOrder,omitempty"`
 }
 
+type RecordingRequest struct{}
+
 type SiteSummary struct {
 	Name string `json:"name"`
 	ID   string `json:"id"`
@@ -288,7 +305,7 @@ func (r RecordingsResponse) Fields() []string {
 	return []string{
 		r.ID,
 		r.Path,
-		humanReadableBytes(r.Size),
+		HumanReadableBytes(r.Size),
 		fmt.Sprint(r.MessageCount),
 		r.CreatedAt,
 		r.ImportedAt,
@@ -465,6 +482,65 @@ type SignInResponse struct {
 	BearerToken string `json:"bearerToken"`
 }
 
+type SignOutRequest struct{}
+
+type APIKeysRequest struct{}
+
+// APIKeyResponse describes an API key. The key itself is only returned when
+// it is created.
+type APIKeyResponse struct {
+	ID           string     `json:"id"`
+	Label        string     `json:"label"`
+	Capabilities []string   `json:"capabilities"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastUsedAt   *time.Time `json:"lastUsedAt"`
+}
+
+func (r APIKeyResponse) Fields() []string {
+	lastUsedAt := "never"
+	if r.LastUsedAt != nil {
+		lastUsedAt = r.LastUsedAt.Format(time.RFC3339)
+	}
+	return []string{
+		r.ID,
+		r.Label,
+		strings.Join(r.Capabilities, ", "),
+		r.CreatedAt.Format(time.RFC3339),
+		lastUsedAt,
+	}
+}
+
+func (r APIKeyResponse) Headers() []string {
+	return []string{
+		"ID",
+		"Label",
+		"Capabilities",
+		"Created At",
+		"Last Used At",
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Label        string   `json:"label"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	// Key is the secret to authenticate with. It cannot be retrieved again.
+	Key string `json:"key"`
+}
+
+func (r CreateAPIKeyResponse) Fields() []string {
+	return append(r.APIKeyResponse.Fields(), r.Key)
+}
+
+func (r CreateAPIKeyResponse) Headers() []string {
+	return append(r.APIKeyResponse.Headers(), "Key")
+}
+
+type SignOutResponse struct{}
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
@@ -795,7 +871,8 @@ func requiredVal(val *string) string {
 	}
 }
 
-func humanReadableBytes(b int64) string {
+// HumanReadableBytes formats a size in bytes with a binary unit, e.g. 1.5 MiB.
+func HumanReadableBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
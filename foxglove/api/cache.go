@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheTTL is how long a cached response is used without being
+// revalidated, for clients whose CacheTTL is zero.
+const DefaultCacheTTL = 5 * time.Minute
+
+// CacheKey identifies a cached response.
+type CacheKey struct {
+	// Scope identifies the API and credentials the response was fetched
+	// with, so that responses are never shared between organizations; see
+	// cacheScope.
+	Scope string
+	// Endpoint is the path of the request, e.g. /v1/devices.
+	Endpoint string
+	// Query is the encoded query string of the request.
+	Query string
+}
+
+// CacheEntry is a cached response body.
+type CacheEntry struct {
+	Body     []byte    `json:"body"`
+	ETag     string    `json:"etag,omitempty"`
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// Cache stores responses to lookups of slow-changing resources, such as
+// devices and custom properties. Failures to read or write the cache are
+// ignored by the client, which falls back to the API.
+type Cache interface {
+	// Get returns the entry for key, or nil if there is none.
+	Get(key CacheKey) (*CacheEntry, error)
+	Put(key CacheKey, entry *CacheEntry) error
+	// Invalidate removes all entries for an endpoint within a scope.
+	Invalidate(scope string, endpoint string) error
+}
+
+// FileCache is a Cache that stores entries as JSON files in a directory.
+type FileCache struct {
+	dir string
+}
+
+// NewFileCache returns a cache storing entries under dir.
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{dir: dir}
+}
+
+// DefaultCacheDir returns the directory in which responses are cached by
+// default.
+func DefaultCacheDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "foxglove-cli", "responses"), nil
+}
+
+func (c *FileCache) endpointDir(scope string, endpoint string) string {
+	return filepath.Join(c.dir, scope, url.PathEscape(endpoint))
+}
+
+func (c *FileCache) path(key CacheKey) string {
+	sum := sha256.Sum256([]byte(key.Query))
+	return filepath.Join(c.endpointDir(key.Scope, key.Endpoint), hex.EncodeToString(sum[:8])+".json")
+}
+
+func (c *FileCache) Get(key CacheKey) (*CacheEntry, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		// Treat a corrupt entry as absent.
+		return nil, nil
+	}
+	return entry, nil
+}
+
+func (c *FileCache) Put(key CacheKey, entry *CacheEntry) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write through a temporary file so that a concurrent reader never sees
+	// a partial entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "entry")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *FileCache) Invalidate(scope string, endpoint string) error {
+	return os.RemoveAll(c.endpointDir(scope, endpoint))
+}
+
+// Clear removes all cached entries.
+func (c *FileCache) Clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+// cacheScope returns the scope of responses fetched with a token. The cache
+// is keyed on the token rather than the organization: finding the
+// organization takes a request to /v1/me, which API keys can't make and
+// which would cost the round trip the cache exists to save. A token belongs
+// to a single organization, so organizations are still kept apart; the cost
+// is that a new login or API key starts with an empty cache. The token itself
+// is hashed so that it isn't written to disk.
+func cacheScope(baseURL string, token string) string {
+	sum := sha256.Sum256([]byte(baseURL + "\x00" + token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// getCached is like get, but serves the response from the client's cache
+// while it is fresh, and revalidates it with the server once stale.
+func (c *FoxgloveClient) getCached(ctx context.Context, endpoint string, req any, target any) error {
+	if c.cache == nil {
+		return c.get(ctx, endpoint, req, target)
+	}
+	query, err := encodeQuery(req)
+	if err != nil {
+		return err
+	}
+	key := CacheKey{Scope: c.cacheScope, Endpoint: endpoint, Query: query}
+	entry, _ := c.cache.Get(key)
+	if entry != nil && time.Since(entry.StoredAt) < c.cacheTTL {
+		if err := json.Unmarshal(entry.Body, target); err == nil {
+			return nil
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseurl+endpoint+"?"+query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if entry != nil && entry.ETag != "" {
+		httpReq.Header.Set("If-None-Match", entry.ETag)
+	}
+	res, err := c.authed.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotModified && entry != nil:
+		entry.StoredAt = time.Now()
+	case res.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		entry = &CacheEntry{Body: body, ETag: res.Header.Get("ETag"), StoredAt: time.Now()}
+	default:
+		return newAPIError(res)
+	}
+	if err := json.Unmarshal(entry.Body, target); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	_ = c.cache.Put(key, entry)
+	return nil
+}
+
+// invalidateCache discards cached responses from an endpoint after a change
+// to the resources it lists.
+func (c *FoxgloveClient) invalidateCache(endpoint string) {
+	if c.cache != nil {
+		_ = c.cache.Invalidate(c.cacheScope, endpoint)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*MockFoxgloveServer, string) {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		token, err := NewRemoteFoxgloveClient(sv.BaseURL(), "client", "", "user-agent").SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		return sv, token
+	}
+
+	t.Run("serves fresh lookups from the cache", func(t *testing.T) {
+		sv, token := setup(t)
+		cache := NewFileCache(t.TempDir())
+		client := NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: cache})
+		first, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		second, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, sv.RequestCount("/v1/devices"))
+
+		// A new client, e.g. in a later process, shares the cache.
+		client = NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: cache})
+		_, err = client.DeviceCustomProperties(ctx, CustomPropertiesRequest{ResourceType: "device"})
+		assert.Nil(t, err)
+		_, err = client.DeviceCustomProperties(ctx, CustomPropertiesRequest{ResourceType: "device"})
+		assert.Nil(t, err)
+		_, err = client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sv.RequestCount("/v1/devices"))
+		assert.Equal(t, 1, sv.RequestCount("/v1/custom-properties"))
+	})
+	t.Run("leaves listings uncached", func(t *testing.T) {
+		sv, token := setup(t)
+		client := NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: NewFileCache(t.TempDir())})
+		_, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		_, err = client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		_, err = client.Projects(ctx, ProjectsRequest{})
+		assert.Nil(t, err)
+		_, err = client.Projects(ctx, ProjectsRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, sv.RequestCount("/v1/devices"))
+		assert.Equal(t, 2, sv.RequestCount("/v1/projects"))
+	})
+	t.Run("revalidates stale entries with the etag", func(t *testing.T) {
+		sv, token := setup(t)
+		trace := &bytes.Buffer{}
+		client := NewClient(ClientOptions{
+			BaseURL:     sv.BaseURL(),
+			Token:       token,
+			Cache:       NewFileCache(t.TempDir()),
+			CacheTTL:    time.Nanosecond,
+			TraceLevel:  TraceRequests,
+			TraceOutput: trace,
+		})
+		first, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		second, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 2, sv.RequestCount("/v1/devices"))
+		assert.Contains(t, trace.String(), " 304 ")
+
+		// A change on the server is picked up.
+		other := NewRemoteFoxgloveClient(sv.BaseURL(), "client", token, "user-agent")
+		_, err = other.CreateDevice(ctx, CreateDeviceRequest{Name: "new-device"})
+		assert.Nil(t, err)
+		third, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Len(t, third, len(first)+1)
+	})
+	t.Run("invalidates devices after a change", func(t *testing.T) {
+		sv, token := setup(t)
+		client := NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: NewFileCache(t.TempDir())})
+		before, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		_, err = client.CreateDevice(ctx, CreateDeviceRequest{Name: "new-device"})
+		assert.Nil(t, err)
+		after, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Len(t, after, len(before)+1)
+	})
+	t.Run("keeps credentials apart", func(t *testing.T) {
+		sv, token := setup(t)
+		cache := NewFileCache(t.TempDir())
+		client := NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: cache})
+		_, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		client = NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: "other-token", Cache: cache})
+		_, err = client.CachedDevices(ctx, DevicesRequest{})
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.Equal(t, 2, sv.RequestCount("/v1/devices"))
+	})
+	t.Run("clear removes all entries", func(t *testing.T) {
+		sv, token := setup(t)
+		cache := NewFileCache(t.TempDir())
+		client := NewClient(ClientOptions{BaseURL: sv.BaseURL(), Token: token, Cache: cache})
+		_, err := client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Nil(t, cache.Clear())
+		_, err = client.CachedDevices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, sv.RequestCount("/v1/devices"))
+	})
+}
@@ -2,13 +2,14 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"time"
 
 	"github.com/ajg/form"
 )
@@ -16,14 +17,33 @@ import (
 var (
 	ErrForbidden = errors.New("Forbidden. Have you signed in with `foxglove auth login`?")
 	ErrNotFound  = errors.New("not found")
+	// ErrUnauthorized is matched by 401 responses, which mean the token was
+	// not accepted at all, e.g. because the session expired or was revoked.
+	// 403 responses, for tokens that lack a permission, only match
+	// ErrForbidden.
+	ErrUnauthorized = errors.New("Unauthorized. Your session may have expired or been revoked: sign in with `foxglove auth login`")
+	// ErrLoginExpired is returned by Login if the device code expires before
+	// the user authorizes it.
+	ErrLoginExpired = errors.New("the login code expired before it was authorized. Run `foxglove auth login` to try again")
+	// ErrLoginDenied is returned by Login if the user declines to authorize
+	// the device code.
+	ErrLoginDenied = errors.New("the login was denied")
 )
 
 type FoxgloveClient struct {
-	baseurl   string
-	clientID  string
-	userAgent string
-	authed    *http.Client
-	unauthed  *http.Client
+	baseurl     string
+	clientID    string
+	userAgent   string
+	retry       RetryPolicy
+	trace       TraceLevel
+	traceOutput io.Writer
+	transport   http.RoundTripper // shared by all of the clients below
+	cache       Cache             // nil disables caching
+	cacheTTL    time.Duration
+	cacheScope  string
+	authed      *http.Client
+	unauthed    *http.Client
+	storage     *http.Client // signed storage links; no credentials attached
 }
 
 func coalesce(strings ...string) string {
@@ -35,22 +55,9 @@ func coalesce(strings ...string) string {
 	return ""
 }
 
-func unpackErrorResponse(r io.Reader) error {
-	resp := ErrorResponse{}
-	bytes, err := io.ReadAll(r)
-	if err != nil {
-		return fmt.Errorf("failed to read error response: %w", err)
-	}
-	err = json.Unmarshal(bytes, &resp)
-	if err != nil {
-		return errors.New(string(bytes))
-	}
-	return fmt.Errorf("%s", coalesce(resp.Error, resp.Message))
-}
-
 // SignIn accepts a client ID token and uses it to authenticate to foxglove,
 // returning a bearer token for use in subsequent HTTP requests.
-func (c *FoxgloveClient) SignIn(token string) (string, error) {
+func (c *FoxgloveClient) SignIn(ctx context.Context, token string) (string, error) {
 	buf := &bytes.Buffer{}
 	err := json.NewEncoder(buf).Encode(SignInRequest{
 		Token: token,
@@ -58,109 +65,170 @@ func (c *FoxgloveClient) SignIn(token string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to encode request: %w", err)
 	}
-	resp, err := c.unauthed.Post(c.baseurl+"/v1/signin", "application/json", buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/signin", buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.unauthed.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("sign in failure: %w", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", unpackErrorResponse(resp.Body)
+		return "", newAPIError(resp)
 	}
 	r := SignInResponse{}
 	err = json.NewDecoder(resp.Body).Decode(&r)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode sign in response: %w", err)
 	}
-	c.authed = makeClient(c.userAgent, r.BearerToken)
+	c.authed = c.makeClient(r.BearerToken)
 	return r.BearerToken, nil
 }
 
+// SignOut revokes the client's bearer token, so that it can no longer be
+// used. Servers that don't support revocation respond with an error matching
+// ErrNotFound.
+func (c *FoxgloveClient) SignOut(ctx context.Context) error {
+	return c.post(ctx, "/v1/signout", SignOutRequest{}, &SignOutResponse{})
+}
+
 // Stream returns a ReadCloser wrapping a binary output stream in response to
-// the provided request.
-func (c *FoxgloveClient) Stream(r *StreamRequest) (io.ReadCloser, error) {
+// the provided request. If the download is interrupted and the signed link
+// supports range requests, the stream is transparently resumed from the
+// last byte received.
+func (c *FoxgloveClient) Stream(ctx context.Context, r *StreamRequest) (io.ReadCloser, error) {
 	buf := &bytes.Buffer{}
 	err := json.NewEncoder(buf).Encode(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.baseurl+"/v1/data/stream", buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/data/stream", buf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
+	markIdempotent(req)
 	resp, err := c.authed.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get download link: %w", err)
 	}
-	switch resp.StatusCode {
-	case http.StatusOK:
-		break
-	case http.StatusForbidden, http.StatusUnauthorized:
-		return nil, ErrForbidden
-	default:
-		return nil, unpackErrorResponse(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
 	}
 	link := StreamResponse{}
 	err = json.NewDecoder(resp.Body).Decode(&link)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-	resp, err = http.Get(link.Link)
+	downloadReq, err := http.NewRequestWithContext(ctx, http.MethodGet, link.Link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build download request: %w", err)
+	}
+	downloadResp, err := c.storage.Do(downloadReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch download: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, unpackErrorResponse(resp.Body)
+	if downloadResp.StatusCode != http.StatusOK {
+		defer downloadResp.Body.Close()
+		return nil, newAPIError(downloadResp)
 	}
-	return resp.Body, nil
+	return newResumingBody(ctx, c.storage, link.Link, downloadResp), nil
 }
 
 // Upload uploads the contents of a reader for a provided filename and device.
 // It manages the indirection through GCS signed upload links for the caller.
-func (c *FoxgloveClient) Upload(reader io.Reader, r UploadRequest) error {
+// A seekable reader is uploaded through a resumable session where the server
+// supports it; see ResumeUpload.
+func (c *FoxgloveClient) Upload(ctx context.Context, reader io.Reader, r UploadRequest) error {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		return c.ResumeUpload(ctx, seeker, r, nil)
+	}
+	link, err := c.uploadLink(ctx, r)
+	if err != nil {
+		return err
+	}
+	return c.putObject(ctx, link.Link, reader)
+}
+
+// uploadLink requests a signed link to upload an object to.
+func (c *FoxgloveClient) uploadLink(ctx context.Context, r UploadRequest) (*UploadResponse, error) {
 	buf := &bytes.Buffer{}
 	err := json.NewEncoder(buf).Encode(r)
 	if err != nil {
-		return fmt.Errorf("failed to encode import request: %w", err)
+		return nil, fmt.Errorf("failed to encode import request: %w", err)
 	}
-	resp, err := c.authed.Post(c.baseurl+"/v1/data/upload", "application/json", buf)
+	linkReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/data/upload", buf)
 	if err != nil {
-		return fmt.Errorf("import request failure: %w", err)
+		return nil, fmt.Errorf("failed to build import request: %w", err)
 	}
-	switch resp.StatusCode {
-	case http.StatusOK:
-		break
-	case http.StatusForbidden, http.StatusUnauthorized:
-		return ErrForbidden
-	default:
-		return unpackErrorResponse(resp.Body)
+	linkReq.Header.Add("Content-Type", "application/json")
+	markIdempotent(linkReq)
+	resp, err := c.authed.Do(linkReq)
+	if err != nil {
+		return nil, fmt.Errorf("import request failure: %w", err)
 	}
-	link := UploadResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&link)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+	link := &UploadResponse{}
+	err = json.NewDecoder(resp.Body).Decode(link)
 	if err != nil {
-		return fmt.Errorf("failed to decode import response: %w", err)
+		return nil, fmt.Errorf("failed to decode import response: %w", err)
+	}
+	return link, nil
+}
+
+// putObject uploads the contents of reader to a signed link in a single
+// request.
+func (c *FoxgloveClient) putObject(ctx context.Context, link string, reader io.Reader) error {
+	body := reader
+	var getBody func() (io.ReadCloser, error)
+	length := int64(-1)
+	// A seekable reader can be reread so the PUT may be retried.
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("failed to determine upload offset: %w", err)
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("failed to determine upload size: %w", err)
+		}
+		length = end - offset
+		getBody = uploadBody(seeker, offset, length)
+		if body, err = getBody(); err != nil {
+			return fmt.Errorf("failed to rewind upload: %w", err)
+		}
 	}
-	client := &http.Client{}
-	req, err := http.NewRequest("PUT", link.Link, reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, link, body)
 	if err != nil {
 		return fmt.Errorf("failed to build upload request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/octet-stream")
-	resp, err = client.Do(req)
+	if getBody != nil {
+		req.ContentLength = length
+		req.GetBody = getBody
+	}
+	uploadResp, err := c.storage.Do(req)
 	if err != nil {
 		return fmt.Errorf("upload failed: %w", err)
 	}
-	defer resp.Body.Close()
+	defer uploadResp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected %d on upload request", resp.StatusCode)
+	if uploadResp.StatusCode != http.StatusOK {
+		return newAPIError(uploadResp)
 	}
 	return nil
 }
 
 // DeviceCode retrieves a device code, which may be used to correlate a login
 // action with a token through the API.
-func (c *FoxgloveClient) DeviceCode() (*DeviceCodeResponse, error) {
+func (c *FoxgloveClient) DeviceCode(ctx context.Context) (*DeviceCodeResponse, error) {
 	buf := &bytes.Buffer{}
 	err := json.NewEncoder(buf).Encode(DeviceCodeRequest{
 		ClientID: c.clientID,
@@ -168,12 +236,18 @@ func (c *FoxgloveClient) DeviceCode() (*DeviceCodeResponse, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize device code request: %w", err)
 	}
-	resp, err := c.unauthed.Post(c.baseurl+"/v1/auth/device-code", "application/json", buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/auth/device-code", buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build device code request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.unauthed.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch device code: %w", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, unpackErrorResponse(resp.Body)
+		return nil, newAPIError(resp)
 	}
 	response := &DeviceCodeResponse{}
 	err = json.NewDecoder(resp.Body).Decode(response)
@@ -184,6 +258,7 @@ func (c *FoxgloveClient) DeviceCode() (*DeviceCodeResponse, error) {
 }
 
 func (c *FoxgloveClient) post(
+	ctx context.Context,
 	endpoint string,
 	req any,
 	target any,
@@ -193,19 +268,19 @@ func (c *FoxgloveClient) post(
 	if err != nil {
 		return fmt.Errorf("failed to encode request: %w", err)
 	}
-	resp, err := c.authed.Post(c.baseurl+endpoint, "application/json", &buf)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+endpoint, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Add("Content-Type", "application/json")
+	resp, err := c.authed.Do(httpReq)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusForbidden:
-		return ErrForbidden
-	case http.StatusOK:
-		break
-	default:
-		return unpackErrorResponse(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
 	}
 	err = json.NewDecoder(resp.Body).Decode(target)
 	if err != nil {
@@ -215,6 +290,7 @@ func (c *FoxgloveClient) post(
 }
 
 func (c *FoxgloveClient) patch(
+	ctx context.Context,
 	endpoint string,
 	reqQuery any,
 	reqBody any,
@@ -232,12 +308,14 @@ func (c *FoxgloveClient) patch(
 	if err != nil {
 		return fmt.Errorf("failed to encode request: %w", err)
 	}
-	req, err := http.NewRequest(http.MethodPatch, c.baseurl+endpoint+"?"+queryBuf.String(), &bodyBuf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.baseurl+endpoint+"?"+queryBuf.String(), &bodyBuf)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Add("content-type", "application/json")
+	// Edits set absolute values, so replaying one is harmless.
+	markIdempotent(req)
 
 	resp, err := c.authed.Do(req)
 	if err != nil {
@@ -245,13 +323,8 @@ func (c *FoxgloveClient) patch(
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusForbidden:
-		return ErrForbidden
-	case http.StatusOK:
-		break
-	default:
-		return unpackErrorResponse(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(target)
@@ -261,8 +334,8 @@ func (c *FoxgloveClient) patch(
 	return nil
 }
 
-func (c *FoxgloveClient) delete(endpoint string) error {
-	req, err := http.NewRequest(http.MethodDelete, c.baseurl+endpoint, nil)
+func (c *FoxgloveClient) delete(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseurl+endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -273,26 +346,22 @@ func (c *FoxgloveClient) delete(endpoint string) error {
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusForbidden:
-		return ErrForbidden
-	case http.StatusNotFound:
-		// Warn the user, but proceed as successful
-		fmt.Fprintln(os.Stderr, "Not found. The resource may have already been deleted.")
-	case http.StatusOK:
-		break
-	default:
-		return unpackErrorResponse(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
 	}
 	return nil
 }
 
-func (c *FoxgloveClient) CreateDevice(req CreateDeviceRequest) (resp CreateDeviceResponse, err error) {
-	err = c.post("/v1/devices", req, &resp)
+func (c *FoxgloveClient) CreateDevice(ctx context.Context, req CreateDeviceRequest) (resp CreateDeviceResponse, err error) {
+	err = c.post(ctx, "/v1/devices", req, &resp)
+	if err == nil {
+		c.invalidateCache("/v1/devices")
+	}
 	return resp, err
 }
 
 func (c *FoxgloveClient) EditDevice(
+	ctx context.Context,
 	nameOrId string,
 	reqQuery EditDeviceRequestQuery,
 	reqBody EditDeviceRequestBody,
@@ -301,12 +370,15 @@ func (c *FoxgloveClient) EditDevice(
 	if err != nil {
 		return EditDeviceResponse{}, err
 	}
-	err = c.patch(path, reqQuery, reqBody, &resp)
+	err = c.patch(ctx, path, reqQuery, reqBody, &resp)
+	if err == nil {
+		c.invalidateCache("/v1/devices")
+	}
 	return resp, err
 }
 
-func (c *FoxgloveClient) CreateEvent(req CreateEventRequest) (resp CreateEventResponse, err error) {
-	err = c.post("/v1/events", req, &resp)
+func (c *FoxgloveClient) CreateEvent(ctx context.Context, req CreateEventRequest) (resp CreateEventResponse, err error) {
+	err = c.post(ctx, "/v1/events", req, &resp)
 	return resp, err
 }
 
@@ -314,8 +386,8 @@ func (c *FoxgloveClient) CreateEvent(req CreateEventRequest) (resp CreateEventRe
 // This endpoint  can be used to create an extension, or update with a new version.
 // Extension & version information is parsed from the extension's package.json.
 // The content should be a valid .foxe file.
-func (c *FoxgloveClient) UploadExtension(reader io.Reader) error {
-	req, err := http.NewRequest("POST", c.baseurl+"/v1/extension-upload", reader)
+func (c *FoxgloveClient) UploadExtension(ctx context.Context, reader io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/extension-upload", reader)
 	if err != nil {
 		return fmt.Errorf("failed to build upload extension request: %w", err)
 	}
@@ -326,41 +398,44 @@ func (c *FoxgloveClient) UploadExtension(reader io.Reader) error {
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		return nil
-	case http.StatusForbidden, http.StatusUnauthorized:
-		return fmt.Errorf("%w\n%s", ErrForbidden, unpackErrorResponse(resp.Body))
-	default:
-		return unpackErrorResponse(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
 	}
+	return nil
 }
 
-func (c *FoxgloveClient) DeleteExtension(id string) error {
-	return c.delete("/v1/extensions/" + id)
+func (c *FoxgloveClient) DeleteExtension(ctx context.Context, id string) error {
+	return c.delete(ctx, "/v1/extensions/"+id)
 }
 
-func (c *FoxgloveClient) get(endpoint string, req any, target any) error {
+// encodeQuery encodes a request struct as a query string.
+func encodeQuery(req any) (string, error) {
 	buf := &bytes.Buffer{}
 	encoder := form.NewEncoder(buf)
 	encoder.DelimitWith('/') // required to support dotted fields in query strings
 	err := encoder.Encode(req)
 	if err != nil {
-		return fmt.Errorf("failed to encode request: %w", err)
+		return "", fmt.Errorf("failed to encode request: %w", err)
 	}
-	res, err := c.authed.Get(c.baseurl + endpoint + "?" + buf.String())
+	return buf.String(), nil
+}
+
+func (c *FoxgloveClient) get(ctx context.Context, endpoint string, req any, target any) error {
+	query, err := encodeQuery(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseurl+endpoint+"?"+query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	res, err := c.authed.Do(httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to fetch records: %w", err)
 	}
-	switch res.StatusCode {
-	case http.StatusForbidden, http.StatusUnauthorized:
-		return fmt.Errorf("%w\n%s", ErrForbidden, unpackErrorResponse(res.Body))
-	case http.StatusNotFound:
-		return ErrNotFound
-	case http.StatusOK:
-		break
-	default:
-		return unpackErrorResponse(res.Body)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return newAPIError(res)
 	}
 	err = json.NewDecoder(res.Body).Decode(target)
 	if err != nil {
@@ -369,31 +444,40 @@ func (c *FoxgloveClient) get(endpoint string, req any, target any) error {
 	return nil
 }
 
-func (c *FoxgloveClient) Devices(req DevicesRequest) (resp []DevicesResponse, err error) {
-	err = c.get("/v1/devices", req, &resp)
+func (c *FoxgloveClient) Devices(ctx context.Context, req DevicesRequest) (resp []DevicesResponse, err error) {
+	err = c.get(ctx, "/v1/devices", req, &resp)
+	return resp, err
+}
+
+// CachedDevices is like Devices, but may serve the devices from the client's
+// cache. It is meant for lookups such as completion and resolving device
+// names, where a slightly stale answer is better than a slow one; listings
+// should use Devices.
+func (c *FoxgloveClient) CachedDevices(ctx context.Context, req DevicesRequest) (resp []DevicesResponse, err error) {
+	err = c.getCached(ctx, "/v1/devices", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Sessions(req SessionsRequest) (resp []SessionResponse, err error) {
-	err = c.get("/v1/sessions", req, &resp)
+func (c *FoxgloveClient) Sessions(ctx context.Context, req SessionsRequest) (resp []SessionResponse, err error) {
+	err = c.get(ctx, "/v1/sessions", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) GetSession(keyOrID string, projectID string) (resp SessionResponse, err error) {
+func (c *FoxgloveClient) GetSession(ctx context.Context, keyOrID string, projectID string) (resp SessionResponse, err error) {
 	path, err := url.JoinPath("/v1/sessions", keyOrID)
 	if err != nil {
 		return SessionResponse{}, err
 	}
-	err = c.get(path, GetSessionRequest{ProjectID: projectID}, &resp)
+	err = c.get(ctx, path, GetSessionRequest{ProjectID: projectID}, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) CreateSession(req CreateSessionRequest) (resp CreateSessionResponse, err error) {
-	err = c.post("/v1/sessions", req, &resp)
+func (c *FoxgloveClient) CreateSession(ctx context.Context, req CreateSessionRequest) (resp CreateSessionResponse, err error) {
+	err = c.post(ctx, "/v1/sessions", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) DeleteSession(keyOrID string, projectID string) error {
+func (c *FoxgloveClient) DeleteSession(ctx context.Context, keyOrID string, projectID string) error {
 	path, err := url.JoinPath("/v1/sessions", keyOrID)
 	if err != nil {
 		return err
@@ -402,12 +486,12 @@ func (c *FoxgloveClient) DeleteSession(keyOrID string, projectID string) error {
 	if projectID != "" {
 		endpoint = path + "?projectId=" + url.QueryEscape(projectID)
 	}
-	return c.delete(endpoint)
+	return c.delete(ctx, endpoint)
 }
 
 // ListSessionRecordings returns recording IDs for a session (from GET session's recordings array).
-func (c *FoxgloveClient) ListSessionRecordings(keyOrID string, projectID string) (resp SessionRecordingsResponse, err error) {
-	session, err := c.GetSession(keyOrID, projectID)
+func (c *FoxgloveClient) ListSessionRecordings(ctx context.Context, keyOrID string, projectID string) (resp SessionRecordingsResponse, err error) {
+	session, err := c.GetSession(ctx, keyOrID, projectID)
 	if err != nil {
 		return SessionRecordingsResponse{}, err
 	}
@@ -419,124 +503,182 @@ func (c *FoxgloveClient) ListSessionRecordings(keyOrID string, projectID string)
 }
 
 // PatchSessionRecordings adds or removes recordings in a session via PATCH /sessions/{keyOrId}.
-func (c *FoxgloveClient) PatchSessionRecordings(keyOrID string, projectID string, req PatchSessionRecordingsRequest) (UpdateSessionResponse, error) {
+func (c *FoxgloveClient) PatchSessionRecordings(ctx context.Context, keyOrID string, projectID string, req PatchSessionRecordingsRequest) (UpdateSessionResponse, error) {
 	path, err := url.JoinPath("/v1/sessions", keyOrID)
 	if err != nil {
 		return UpdateSessionResponse{}, err
 	}
 	var resp UpdateSessionResponse
-	err = c.patch(path, GetSessionRequest{ProjectID: projectID}, req, &resp)
+	err = c.patch(ctx, path, GetSessionRequest{ProjectID: projectID}, req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) AddRecordingToSession(keyOrID string, projectID string, recordingID string) error {
-	_, err := c.PatchSessionRecordings(keyOrID, projectID, PatchSessionRecordingsRequest{
+func (c *FoxgloveClient) AddRecordingToSession(ctx context.Context, keyOrID string, projectID string, recordingID string) error {
+	_, err := c.PatchSessionRecordings(ctx, keyOrID, projectID, PatchSessionRecordingsRequest{
 		AddRecordingIDs: []string{recordingID},
 	})
 	return err
 }
 
-func (c *FoxgloveClient) RemoveRecordingFromSession(keyOrID string, projectID string, recordingID string) error {
-	_, err := c.PatchSessionRecordings(keyOrID, projectID, PatchSessionRecordingsRequest{
+func (c *FoxgloveClient) RemoveRecordingFromSession(ctx context.Context, keyOrID string, projectID string, recordingID string) error {
+	_, err := c.PatchSessionRecordings(ctx, keyOrID, projectID, PatchSessionRecordingsRequest{
 		RemoveRecordingIDs: []string{recordingID},
 	})
 	return err
 }
 
-func (c *FoxgloveClient) Events(req *EventsRequest) (resp []EventResponseItem, err error) {
-	err = c.get("/v1/events", req, &resp)
+func (c *FoxgloveClient) Events(ctx context.Context, req *EventsRequest) (resp []EventResponseItem, err error) {
+	err = c.get(ctx, "/v1/events", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) EventTypes(req *EventTypesRequest) (resp []EventTypeResponse, err error) {
-	err = c.get("/v1/event-types", req, &resp)
+func (c *FoxgloveClient) EventTypes(ctx context.Context, req *EventTypesRequest) (resp []EventTypeResponse, err error) {
+	err = c.get(ctx, "/v1/event-types", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Imports(req *ImportsRequest) (resp []ImportsResponse, err error) {
-	err = c.get("/v1/data/imports", req, &resp)
+func (c *FoxgloveClient) Imports(ctx context.Context, req *ImportsRequest) (resp []ImportsResponse, err error) {
+	err = c.get(ctx, "/v1/data/imports", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Projects(req ProjectsRequest) (resp []ProjectsResponse, err error) {
-	err = c.get("/v1/projects", req, &resp)
+func (c *FoxgloveClient) Projects(ctx context.Context, req ProjectsRequest) (resp []ProjectsResponse, err error) {
+	err = c.get(ctx, "/v1/projects", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Recordings(req *RecordingsRequest) (resp []RecordingsResponse, err error) {
-	err = c.get("/v1/recordings", req, &resp)
+func (c *FoxgloveClient) Recordings(ctx context.Context, req *RecordingsRequest) (resp []RecordingsResponse, err error) {
+	err = c.get(ctx, "/v1/recordings", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) DeleteRecording(id string) error {
-	return c.delete("/v1/recordings/" + id)
+// Recording returns the recording with the given ID.
+func (c *FoxgloveClient) Recording(ctx context.Context, id string) (resp RecordingsResponse, err error) {
+	path, err := url.JoinPath("/v1/recordings", id)
+	if err != nil {
+		return RecordingsResponse{}, err
+	}
+	err = c.get(ctx, path, RecordingRequest{}, &resp)
+	return resp, err
 }
 
-func (c *FoxgloveClient) Attachments(req *AttachmentsRequest) (resp []AttachmentsResponse, err error) {
-	err = c.get("/v1/recording-attachments", req, &resp)
+func (c *FoxgloveClient) DeleteRecording(ctx context.Context, id string) error {
+	return c.delete(ctx, "/v1/recordings/"+id)
+}
+
+func (c *FoxgloveClient) Attachments(ctx context.Context, req *AttachmentsRequest) (resp []AttachmentsResponse, err error) {
+	err = c.get(ctx, "/v1/recording-attachments", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Coverage(req *CoverageRequest) (resp []CoverageResponse, err error) {
-	err = c.get("/v1/data/coverage", *req, &resp)
+func (c *FoxgloveClient) Coverage(ctx context.Context, req *CoverageRequest) (resp []CoverageResponse, err error) {
+	err = c.get(ctx, "/v1/data/coverage", *req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Extensions(req ExtensionsRequest) (resp []ExtensionResponse, err error) {
-	err = c.get("/v1/extensions", req, &resp)
+func (c *FoxgloveClient) Extensions(ctx context.Context, req ExtensionsRequest) (resp []ExtensionResponse, err error) {
+	err = c.get(ctx, "/v1/extensions", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) DeviceCustomProperties(req CustomPropertiesRequest) (resp []CustomPropertiesResponseItem, err error) {
-	err = c.get("/v1/custom-properties", req, &resp)
+// DeviceCustomProperties returns the custom properties defined for a resource
+// type. They are used to validate properties, and may be served from the
+// client's cache.
+func (c *FoxgloveClient) DeviceCustomProperties(ctx context.Context, req CustomPropertiesRequest) (resp []CustomPropertiesResponseItem, err error) {
+	err = c.getCached(ctx, "/v1/custom-properties", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Attachment(id string) (io.ReadCloser, error) {
-	res, err := c.authed.Get(c.baseurl + "/v1/recording-attachments/" + id + "/download")
+func (c *FoxgloveClient) Attachment(ctx context.Context, id string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseurl+"/v1/recording-attachments/"+id+"/download", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	res, err := c.authed.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch records: %w", err)
 	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, newAPIError(res)
+	}
 	return res.Body, nil
 }
 
-func (c *FoxgloveClient) PendingImports(req PendingImportsRequest) (resp []PendingImportsResponseItem, err error) {
-	err = c.get("/v1/data/pending-imports", req, &resp)
+func (c *FoxgloveClient) PendingImports(ctx context.Context, req PendingImportsRequest) (resp []PendingImportsResponseItem, err error) {
+	err = c.get(ctx, "/v1/data/pending-imports", req, &resp)
+	return resp, err
+}
+
+func (c *FoxgloveClient) ImportFromEdge(ctx context.Context, req ImportFromEdgeRequest, id string) (resp ImportFromEdgeResponse, err error) {
+	err = c.post(ctx, "/v1/recordings/"+id+"/import", req, &resp)
+	return resp, err
+}
+
+func (c *FoxgloveClient) APIKeys(ctx context.Context, req APIKeysRequest) (resp []APIKeyResponse, err error) {
+	err = c.get(ctx, "/v1/api-keys", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) ImportFromEdge(req ImportFromEdgeRequest, id string) (resp ImportFromEdgeResponse, err error) {
-	err = c.post("/v1/recordings/"+id+"/import", req, &resp)
+func (c *FoxgloveClient) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (resp CreateAPIKeyResponse, err error) {
+	err = c.post(ctx, "/v1/api-keys", req, &resp)
 	return resp, err
 }
 
-func (c *FoxgloveClient) Me() (resp MeResponse, err error) {
+// RevokeAPIKey revokes an API key, so that requests made with it are
+// rejected.
+func (c *FoxgloveClient) RevokeAPIKey(ctx context.Context, id string) error {
+	return c.delete(ctx, "/v1/api-keys/"+id)
+}
+
+func (c *FoxgloveClient) Me(ctx context.Context) (resp MeResponse, err error) {
 	req := MeRequest{}
-	err = c.get("/v1/me", req, &resp)
+	err = c.get(ctx, "/v1/me", req, &resp)
 	return resp, err
 }
 
 // Token returns a token for the provided device code. If the token for the
-// device code does not exist yet, ErrForbidden is returned. It is up to the
-// caller to give up after sufficient retries.
-func (c *FoxgloveClient) Token(deviceCode string) (string, error) {
+// device code does not exist yet, an error matching ErrForbidden is returned.
+// It is up to the caller to give up after sufficient retries.
+func (c *FoxgloveClient) Token(ctx context.Context, deviceCode string) (string, error) {
+	return c.requestToken(ctx, TokenRequest{DeviceCode: deviceCode}, true)
+}
+
+// ExchangeAuthorizationCode returns a token for an authorization code
+// received from a browser login, given the PKCE verifier and redirect URI the
+// login was started with.
+func (c *FoxgloveClient) ExchangeAuthorizationCode(ctx context.Context, code, codeVerifier, redirectURI string) (string, error) {
+	// An authorization code may only be exchanged once, so the request is not
+	// safe to retry.
+	return c.requestToken(ctx, TokenRequest{
+		Code:         code,
+		CodeVerifier: codeVerifier,
+		RedirectURI:  redirectURI,
+	}, false)
+}
+
+func (c *FoxgloveClient) requestToken(ctx context.Context, tokenRequest TokenRequest, idempotent bool) (string, error) {
+	tokenRequest.ClientID = c.clientID
 	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(TokenRequest{
-		DeviceCode: deviceCode,
-		ClientID:   c.clientID,
-	})
+	err := json.NewEncoder(buf).Encode(tokenRequest)
 	if err != nil {
 		return "", fmt.Errorf("failed to encode token request: %w", err)
 	}
-	resp, err := c.unauthed.Post(c.baseurl+"/v1/auth/token", "application/json", buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseurl+"/v1/auth/token", buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if idempotent {
+		markIdempotent(req)
+	}
+	resp, err := c.unauthed.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("token request failure: %w", err)
 	}
-	switch {
-	case resp.StatusCode == http.StatusForbidden:
-		return "", ErrForbidden
-	case resp.StatusCode != http.StatusOK:
-		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp)
 	}
 	tokenResponse := TokenResponse{}
 	err = json.NewDecoder(resp.Body).Decode(&tokenResponse)
@@ -550,20 +692,43 @@ type customTransport struct {
 	baseTransport http.RoundTripper
 	token         string
 	userAgent     string
+	trace         TraceLevel
+	traceOutput   io.Writer
 }
 
 func (t *customTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", t.token))
+	if t.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", t.token))
+	}
 	req.Header.Add("User-Agent", t.userAgent)
-	return t.baseTransport.RoundTrip(req)
+	if t.trace == TraceOff {
+		return t.baseTransport.RoundTrip(req)
+	}
+	tr := &tracer{w: t.traceOutput, level: t.trace, req: req, start: time.Now()}
+	tr.traceRequest()
+	resp, err := t.baseTransport.RoundTrip(req)
+	if err != nil {
+		tr.traceError(err)
+		return nil, err
+	}
+	tr.traceResponse(resp)
+	return resp, nil
 }
 
-func makeClient(userAgent, token string) *http.Client {
+// makeClient returns an HTTP client that attaches the supplied token, if
+// any, to each request. Requests are retried according to the client's retry
+// policy and traced according to its trace level.
+func (c *FoxgloveClient) makeClient(token string) *http.Client {
 	return &http.Client{
 		Transport: &customTransport{
-			userAgent:     userAgent,
-			token:         token,
-			baseTransport: http.DefaultTransport,
+			userAgent:   c.userAgent,
+			token:       token,
+			trace:       c.trace,
+			traceOutput: c.traceOutput,
+			baseTransport: &retryTransport{
+				baseTransport: c.transport,
+				policy:        c.retry,
+			},
 		},
 	}
 }
@@ -571,13 +736,13 @@ func makeClient(userAgent, token string) *http.Client {
 // NewRemoteFoxgloveClient returns a client implementation backed by the remote
 // cloud service. The "token" parameter will be passed in authorization headers.
 // For unauthenticated usage (token, device code - the initial signin flow) it
-// may be passed as empty, however authorized requests will fail.
+// may be passed as empty, however authorized requests will fail. See NewClient
+// for further configuration.
 func NewRemoteFoxgloveClient(baseurl, clientID, token, userAgent string) *FoxgloveClient {
-	return &FoxgloveClient{
-		baseurl:   baseurl,
-		clientID:  clientID,
-		userAgent: userAgent,
-		authed:    makeClient(userAgent, token),
-		unauthed:  makeClient(userAgent, ""),
-	}
+	return NewClient(ClientOptions{
+		BaseURL:   baseurl,
+		ClientID:  clientID,
+		Token:     token,
+		UserAgent: userAgent,
+	})
 }
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv, err := NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := NewMockAuthedClient(t, sv.BaseURL())
+
+	t.Run("returns an error response as an APIError", func(t *testing.T) {
+		// The mock serves no attachments, so every download is a 404.
+		body, err := client.Attachment(ctx, "missing")
+		assert.Nil(t, body)
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxStalledResumes is the number of consecutive times a download may be
+// resumed without receiving any further data before it gives up.
+const maxStalledResumes = 3
+
+// resumingBody is the body of a download from a signed link. If the transfer
+// is cut off partway and the link supports HTTP range requests, it requests
+// the remainder of the object from the offset received so far, so that the
+// caller sees one uninterrupted stream. If the link has expired or does not
+// support ranges, the original error is returned.
+type resumingBody struct {
+	ctx    context.Context
+	client *http.Client
+	link   string
+	// etag identifies the version of the object being downloaded. If set, a
+	// resumed request only succeeds if the object is unchanged.
+	etag    string
+	body    io.ReadCloser
+	offset  int64
+	stalled int
+}
+
+// newResumingBody wraps the body of a successful download response. It only
+// resumes downloads of links that advertise byte range support.
+func newResumingBody(ctx context.Context, client *http.Client, link string, resp *http.Response) io.ReadCloser {
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return resp.Body
+	}
+	return &resumingBody{
+		ctx:    ctx,
+		client: client,
+		link:   link,
+		etag:   resp.Header.Get("ETag"),
+		body:   resp.Body,
+	}
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if n > 0 {
+			b.stalled = 0
+		}
+		if err == nil || errors.Is(err, io.EOF) || b.ctx.Err() != nil {
+			return n, err
+		}
+		if b.stalled >= maxStalledResumes {
+			return n, err
+		}
+		b.stalled++
+		if resumeErr := b.resume(); resumeErr != nil {
+			return n, fmt.Errorf("%w (resume failed: %v)", err, resumeErr)
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume replaces the body with the remainder of the object from the current
+// offset.
+func (b *resumingBody) resume() error {
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, b.link, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	if b.etag != "" {
+		req.Header.Set("If-Range", b.etag)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		// A 200 means the object changed or the range was ignored, so the
+		// response can't be spliced onto what was already received.
+		defer resp.Body.Close()
+		return newAPIError(resp)
+	}
+	start, err := parseContentRangeStart(resp.Header.Get("Content-Range"))
+	if err != nil || start != b.offset {
+		resp.Body.Close()
+		return fmt.Errorf("server resumed download at the wrong offset: %q", resp.Header.Get("Content-Range"))
+	}
+	b.body.Close()
+	b.body = resp.Body
+	return nil
+}
+
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
+
+// parseContentRangeStart returns the first byte position of a Content-Range
+// header of the form "bytes first-last/size".
+func parseContentRangeStart(value string) (int64, error) {
+	first, _, ok := strings.Cut(strings.TrimPrefix(value, "bytes "), "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid content range %q", value)
+	}
+	return strconv.ParseInt(first, 10, 64)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamResume(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 256*1024)
+	_, err := rand.Read(data)
+	assert.Nil(t, err)
+	request := &StreamRequest{DeviceID: "test-device", OutputFormat: "mcap0"}
+	setup := func(t *testing.T, ranges bool) *MockFoxgloveServer {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.RangeDownloads = ranges
+		sv.Uploads["device_id=test-device/data.mcap"] = data
+		return sv
+	}
+
+	t.Run("resumes an interrupted download from the byte offset received", func(t *testing.T) {
+		sv := setup(t, true)
+		sv.InjectFault(Fault{PathPrefix: "/storage/", Count: 2, TruncateAfter: 64 * 1024})
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		rc, err := client.Stream(ctx, request)
+		assert.Nil(t, err)
+		defer rc.Close()
+		received, err := io.ReadAll(rc)
+		assert.Nil(t, err)
+		assert.Equal(t, data, received)
+		assert.Equal(t, 3, sv.RequestCount("/storage/device_id=test-device/data.mcap"))
+	})
+	t.Run("returns the error if the link does not support ranges", func(t *testing.T) {
+		sv := setup(t, false)
+		sv.InjectFault(Fault{PathPrefix: "/storage/", Count: 1, TruncateAfter: 64 * 1024})
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		rc, err := client.Stream(ctx, request)
+		assert.Nil(t, err)
+		defer rc.Close()
+		received, err := io.ReadAll(rc)
+		assert.NotNil(t, err)
+		assert.Equal(t, data[:64*1024], received)
+		assert.Equal(t, 1, sv.RequestCount("/storage/device_id=test-device/data.mcap"))
+	})
+	t.Run("returns the error if the link has expired", func(t *testing.T) {
+		sv := setup(t, true)
+		sv.InjectFault(Fault{PathPrefix: "/storage/", Count: 1, TruncateAfter: 64 * 1024})
+		sv.InjectFault(Fault{PathPrefix: "/storage/", Count: 1, Status: http.StatusForbidden})
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		rc, err := client.Stream(ctx, request)
+		assert.Nil(t, err)
+		defer rc.Close()
+		received, err := io.ReadAll(rc)
+		assert.ErrorContains(t, err, "resume failed")
+		assert.Equal(t, data[:64*1024], received)
+		assert.Equal(t, 2, sv.RequestCount("/storage/device_id=test-device/data.mcap"))
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize bounds how much of an error response body is read.
+const maxErrorBodySize = 64 * 1024
+
+// requestIDHeaders are the response headers that may carry an identifier for
+// the request, in order of preference. Quoting one of these in a support
+// request makes the failure much easier to find in server logs.
+var requestIDHeaders = []string{"X-Request-Id", "Request-Id", "X-Cloud-Trace-Context"}
+
+// APIError is returned when the Foxglove API, or a signed storage link,
+// responds with an unexpected status. Use errors.As to inspect it. It matches
+// ErrForbidden for 401 and 403 responses, ErrUnauthorized for 401 responses
+// and ErrNotFound for 404 responses under errors.Is.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Err and Message hold the "error" and "message" fields of the response
+	// body, if it could be decoded.
+	Err     string
+	Message string
+	// Body holds the raw response body if it could not be decoded.
+	Body string
+	// RequestID is the server-assigned request identifier, if any.
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	msg := coalesce(e.Err, e.Message, strings.TrimSpace(e.Body))
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		prefix := ErrForbidden.Error()
+		if e.StatusCode == http.StatusUnauthorized {
+			prefix = ErrUnauthorized.Error()
+		}
+		if msg != "" {
+			msg = prefix + "\n" + msg
+		} else {
+			msg = prefix
+		}
+	default:
+		if msg == "" {
+			msg = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+		}
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request ID: %s)", e.RequestID)
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrForbidden:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// newAPIError builds an APIError from an unsuccessful response, consuming its
+// body.
+func newAPIError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	for _, header := range requestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			apiErr.RequestID = id
+			break
+		}
+	}
+	bytes, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return apiErr
+	}
+	body := ErrorResponse{}
+	if err := json.Unmarshal(bytes, &body); err != nil {
+		apiErr.Body = string(bytes)
+		return apiErr
+	}
+	apiErr.Err = body.Error
+	apiErr.Message = body.Message
+	return apiErr
+}
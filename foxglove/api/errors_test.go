@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIError(t *testing.T) {
+	response := func(status int, body string, header http.Header) *http.Response {
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+	}
+	t.Run("decodes the error body", func(t *testing.T) {
+		err := newAPIError(response(http.StatusBadRequest, `{"error": "invalid device name"}`, nil))
+		var apiErr *APIError
+		assert.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &apiErr))
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "invalid device name", apiErr.Err)
+		assert.Equal(t, "invalid device name", err.Error())
+	})
+	t.Run("falls back to the message field", func(t *testing.T) {
+		err := newAPIError(response(http.StatusConflict, `{"message": "already exists"}`, nil))
+		assert.Equal(t, "already exists", err.Error())
+	})
+	t.Run("keeps undecodable bodies", func(t *testing.T) {
+		err := newAPIError(response(http.StatusBadGateway, "upstream unavailable\n", nil))
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "upstream unavailable\n", apiErr.Body)
+		assert.Equal(t, "upstream unavailable", err.Error())
+	})
+	t.Run("describes empty bodies by status", func(t *testing.T) {
+		err := newAPIError(response(http.StatusServiceUnavailable, "", nil))
+		assert.Equal(t, "503 Service Unavailable", err.Error())
+	})
+	t.Run("records the request ID", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("X-Request-Id", "req_123")
+		err := newAPIError(response(http.StatusInternalServerError, `{"error": "boom"}`, header))
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "req_123", apiErr.RequestID)
+		assert.Equal(t, "boom (request ID: req_123)", err.Error())
+	})
+	t.Run("matches sentinel errors", func(t *testing.T) {
+		assert.ErrorIs(t, newAPIError(response(http.StatusUnauthorized, "", nil)), ErrForbidden)
+		assert.ErrorIs(t, newAPIError(response(http.StatusForbidden, "", nil)), ErrForbidden)
+		assert.ErrorIs(t, newAPIError(response(http.StatusUnauthorized, "", nil)), ErrUnauthorized)
+		assert.NotErrorIs(t, newAPIError(response(http.StatusForbidden, "", nil)), ErrUnauthorized)
+		assert.ErrorIs(t, newAPIError(response(http.StatusNotFound, "", nil)), ErrNotFound)
+		assert.NotErrorIs(t, newAPIError(response(http.StatusBadRequest, "", nil)), ErrNotFound)
+	})
+}
+
+func TestClientReturnsAPIErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv, err := NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := NewMockAuthedClient(t, sv.BaseURL())
+
+	t.Run("delete reports missing resources", func(t *testing.T) {
+		err := client.DeleteExtension(ctx, "nonexistent")
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("unauthenticated requests match ErrForbidden", func(t *testing.T) {
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "client", "bad-token", "user-agent")
+		_, err := client.Devices(ctx, DevicesRequest{})
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		assert.ErrorContains(t, err, "may have expired")
+	})
+	t.Run("signed out tokens are rejected", func(t *testing.T) {
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		_, err := client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Nil(t, client.SignOut(ctx))
+		_, err = client.Devices(ctx, DevicesRequest{})
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+}
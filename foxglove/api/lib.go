@@ -6,51 +6,27 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"runtime"
-	"time"
-
-	"github.com/schollz/progressbar/v3"
-)
-
-const (
-	tokenRetryInterval = 500 * time.Millisecond
 )
 
-type AuthDelegate interface {
-	openBrowser(url string) (*exec.Cmd, error)
-}
-
-type PlatformAuthDelegate struct{}
-
-func (_ *PlatformAuthDelegate) openBrowser(url string) (*exec.Cmd, error) {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux":
-		cmd = exec.Command("xdg-open", url)
-	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-	case "darwin":
-		cmd = exec.Command("open", url)
-	default:
-		return nil, fmt.Errorf("unsupported platform")
-	}
-	return cmd, cmd.Start()
-}
-
+// Export writes the data described by request to w.
 func Export(
 	ctx context.Context,
 	w io.Writer,
 	client *FoxgloveClient,
 	request *StreamRequest,
+	opts ...TransferOption,
 ) error {
-	rc, err := client.Stream(request)
+	o := newTransferOptions(opts)
+	rc, err := client.Stream(ctx, request)
 	if err != nil {
 		return err
 	}
 	defer rc.Close()
+	if o.progress != nil {
+		w = &progressWriter{w: w, fn: o.progress}
+	}
 	_, err = io.Copy(w, rc)
 	if err != nil {
 		return err
@@ -58,6 +34,10 @@ func Export(
 	return nil
 }
 
+// Import imports the named file.
+//
+// Deprecated: Use ImportFile, which takes the destination as an
+// UploadRequest.
 func Import(
 	ctx context.Context,
 	client *FoxgloveClient,
@@ -69,6 +49,22 @@ func Import(
 	sessionKey string,
 	filename string,
 ) error {
+	return ImportFile(ctx, client, filename, UploadRequest{
+		Key:        key,
+		ProjectID:  projectID,
+		DeviceID:   deviceID,
+		DeviceName: deviceName,
+		SessionID:  sessionID,
+		SessionKey: sessionKey,
+	})
+}
+
+// ImportFile imports the named file to the destination described by req. If
+// req.Filename is empty, the base name of the file is used. To resume an
+// interrupted import in a later call, persist its progress with
+// WithUploadState.
+func ImportFile(ctx context.Context, client *FoxgloveClient, filename string, req UploadRequest, opts ...TransferOption) error {
+	o := newTransferOptions(opts)
 	f, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
@@ -78,30 +74,47 @@ func Import(
 	if err != nil {
 		return fmt.Errorf("failed to stat input: %w", err)
 	}
-	_, name := path.Split(filename)
-	bar := progressbar.DefaultBytes(stat.Size(), "uploading")
-	defer bar.Close()
-	reader := progressbar.NewReader(f, bar)
-	err = client.Upload(&reader, UploadRequest{
-		Filename:   name,
-		Key:        key,
-		ProjectID:  projectID,
-		DeviceID:   deviceID,
-		DeviceName: deviceName,
-		SessionID:  sessionID,
-		SessionKey: sessionKey,
-	})
+	if req.Filename == "" {
+		_, req.Filename = path.Split(filename)
+	}
+	var reader io.ReadSeeker = f
+	if o.progress != nil {
+		reader = io.NewSectionReader(&progressReaderAt{ra: f, fn: o.progress, total: stat.Size()}, 0, stat.Size())
+	}
+	err = client.ResumeUpload(ctx, reader, req, o.state)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// ImportReader imports data from a reader that need not be seekable, such
+// as standard input, to the destination described by req. Size is the number
+// of bytes the reader will yield, or -1 if unknown, and is used only to
+// report progress. req.Filename is required. Since the data can't be reread,
+// a failed upload is not retried.
+func ImportReader(ctx context.Context, client *FoxgloveClient, r io.Reader, size int64, req UploadRequest, opts ...TransferOption) error {
+	o := newTransferOptions(opts)
+	if req.Filename == "" {
+		return errors.New("a filename is required")
+	}
+	if o.progress != nil {
+		r = &progressReader{r: r, fn: o.progress, total: size}
+	} else {
+		// Hide any Seek method, which for a pipe would fail.
+		r = struct{ io.Reader }{r}
+	}
+	return client.Upload(ctx, r, req)
+}
+
+// UploadExtensionFile publishes the named Studio extension (.foxe) file.
 func UploadExtensionFile(
 	ctx context.Context,
 	client *FoxgloveClient,
 	filename string,
+	opts ...TransferOption,
 ) error {
+	o := newTransferOptions(opts)
 	f, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
@@ -120,62 +133,9 @@ func UploadExtensionFile(
 		return fmt.Errorf("file size may not exceed 30mb")
 	}
 
-	bar := progressbar.DefaultBytes(stat.Size(), "uploading")
-	defer bar.Close()
-
-	if err != nil {
-		return fmt.Errorf("cannot upload extension: %w", err)
-	}
-	reader := progressbar.NewReader(f, bar)
-	return client.UploadExtension(&reader)
-}
-
-// Login initializes a browser-based login flow for foxglove studio.
-func Login(ctx context.Context, client *FoxgloveClient, authDelegate AuthDelegate) (string, error) {
-	info, err := client.DeviceCode()
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch device code: %w", err)
-	}
-	browser, err := authDelegate.openBrowser(info.VerificationUriComplete)
-	// There's no way to tell for sure whether the browser actually opened the link, even if the
-	// openBrowser command succeeds.
-	if err == nil {
-		defer func() {
-			_ = browser.Process.Kill()
-		}()
-		fmt.Println("If no window opens, copy/paste the following link into your browser:")
-	} else {
-		fmt.Println("copy/paste the following link into your browser:")
-	}
-	fmt.Println("")
-	fmt.Println(info.VerificationUriComplete)
-	fmt.Println("")
-	fmt.Println("Verify this code and click 'Authorize' to complete login: ", info.UserCode)
-
-	// now poll the token endpoint until the token for the device code appears.
-	// When the device code has not yet appeared, the endpoint returns a 403.
-	var token string
-	for {
-
-		select {
-		case <-ctx.Done():
-			return "", context.Canceled
-		default:
-		}
-
-		token, err = client.Token(info.DeviceCode)
-		if errors.Is(err, ErrForbidden) {
-			time.Sleep(tokenRetryInterval)
-			continue
-		}
-		if err != nil {
-			return "", fmt.Errorf("failed to request token: %w", err)
-		}
-		break
-	}
-	bearerToken, err := client.SignIn(token)
-	if err != nil {
-		return "", fmt.Errorf("failed to sign in: %w", err)
+	var reader io.Reader = f
+	if o.progress != nil {
+		reader = &progressReader{r: f, fn: o.progress, total: stat.Size()}
 	}
-	return bearerToken, nil
+	return client.UploadExtension(ctx, reader)
 }
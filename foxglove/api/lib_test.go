@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -125,6 +127,23 @@ func TestExport(t *testing.T) {
 		assert.Nil(t, err)
 		assert.NotEmpty(t, buf.Bytes())
 	})
+	t.Run("aborts when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		token, err := login(ctx, sv)
+		assert.Nil(t, err)
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", token, "test-app")
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		reqCancel()
+		err = Export(reqCtx, &bytes.Buffer{}, client, &StreamRequest{
+			DeviceID:     "test-device",
+			Topics:       []string{},
+			OutputFormat: "mcap",
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
 }
 
 func TestLogin(t *testing.T) {
@@ -149,4 +168,223 @@ func TestLogin(t *testing.T) {
 		assert.ErrorIs(t, context.Canceled, err)
 		assert.Empty(t, bearerToken)
 	})
+	t.Run("stops when the device code expires", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.DeviceCodeExpiry = time.Nanosecond
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		_, err = Login(ctx, client, &MockAuthDelegate{}, WithLoginPrompt(func(LoginPrompt) {}))
+		assert.ErrorIs(t, err, ErrLoginExpired)
+	})
+	t.Run("shows the prompt without opening a browser", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.DeviceCodeExpiry = time.Minute
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		prompts := []LoginPrompt{}
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{}, WithoutBrowser(), WithLoginPrompt(func(prompt LoginPrompt) {
+			prompts = append(prompts, prompt)
+		}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Len(t, prompts, 1)
+		assert.False(t, prompts[0].BrowserOpened)
+		assert.NotEmpty(t, prompts[0].UserCode)
+		assert.Contains(t, prompts[0].VerificationURIComplete, prompts[0].UserCode)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), prompts[0].ExpiresAt, 5*time.Second)
+	})
+	t.Run("logs in through the browser when one is available", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		prompts := []LoginPrompt{}
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{Browser: true}, WithLoginPrompt(func(prompt LoginPrompt) {
+			prompts = append(prompts, prompt)
+		}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Equal(t, 0, sv.RequestCount("/v1/auth/device-code"))
+		assert.Len(t, prompts, 1)
+		assert.Empty(t, prompts[0].UserCode)
+		assert.Contains(t, prompts[0].VerificationURIComplete, "code_challenge_method=S256")
+	})
+	t.Run("reports a denied browser login", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.DenyAuthorization = true
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		_, err = Login(ctx, client, &MockAuthDelegate{Browser: true}, WithLoginPrompt(func(LoginPrompt) {}))
+		assert.ErrorIs(t, err, ErrLoginDenied)
+	})
+	t.Run("falls back to a device code if the server has no browser login", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.NoBrowserLogins = true
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		prompts := []LoginPrompt{}
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{Browser: true}, WithLoginPrompt(func(prompt LoginPrompt) {
+			prompts = append(prompts, prompt)
+		}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Len(t, prompts, 1)
+		assert.NotEmpty(t, prompts[0].UserCode)
+	})
+	t.Run("falls back to a device code if the login page fails", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.InjectFault(Fault{PathPrefix: "/v1/auth/authorize", Count: 1, Status: http.StatusMethodNotAllowed})
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		prompts := []LoginPrompt{}
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{Browser: true}, WithLoginPrompt(func(prompt LoginPrompt) {
+			prompts = append(prompts, prompt)
+		}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Len(t, prompts, 1)
+		assert.NotEmpty(t, prompts[0].UserCode)
+		assert.Equal(t, 1, sv.RequestCount("/v1/auth/authorize"))
+	})
+	t.Run("falls back to a device code if the browser login is abandoned", func(t *testing.T) {
+		timeout := browserLoginTimeout
+		browserLoginTimeout = 100 * time.Millisecond
+		t.Cleanup(func() {
+			browserLoginTimeout = timeout
+		})
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		prompts := []LoginPrompt{}
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{Browser: true, AbandonLogin: true}, WithLoginPrompt(func(prompt LoginPrompt) {
+			prompts = append(prompts, prompt)
+		}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Len(t, prompts, 2)
+		assert.Empty(t, prompts[0].UserCode)
+		assert.NotEmpty(t, prompts[1].UserCode)
+		assert.Equal(t, 1, sv.RequestCount("/v1/auth/device-code"))
+	})
+	t.Run("uses the device code flow when asked", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+		bearerToken, err := Login(ctx, client, &MockAuthDelegate{Browser: true}, WithDeviceCode(), WithLoginPrompt(func(LoginPrompt) {}))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, bearerToken)
+		assert.Equal(t, 1, sv.RequestCount("/v1/auth/device-code"))
+	})
+}
+
+func TestAuthorizationCodeExchange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv, err := NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "test-app")
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	authorize := func(challenge string) string {
+		resp, err := noRedirects.Get(client.authorizeURL("http://127.0.0.1:1234/callback", challenge, "state"))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		location, err := resp.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "state", location.Query().Get("state"))
+		return location.Query().Get("code")
+	}
+	verifier, challenge, err := newPKCEChallenge()
+	assert.Nil(t, err)
+
+	t.Run("rejects the wrong verifier", func(t *testing.T) {
+		code := authorize(challenge)
+		_, err := client.ExchangeAuthorizationCode(ctx, code, "wrong", "http://127.0.0.1:1234/callback")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+	t.Run("accepts a code once", func(t *testing.T) {
+		code := authorize(challenge)
+		token, err := client.ExchangeAuthorizationCode(ctx, code, verifier, "http://127.0.0.1:1234/callback")
+		assert.Nil(t, err)
+		assert.NotEmpty(t, token)
+		_, err = client.ExchangeAuthorizationCode(ctx, code, verifier, "http://127.0.0.1:1234/callback")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+}
+
+func TestLoginCallbackHandler(t *testing.T) {
+	results := make(chan authorizationResult, 1)
+	handler := loginCallbackHandler("expected", results)
+	request := func(query string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/callback?"+query, nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, request("state=other&code=abc"))
+	assert.Len(t, results, 0)
+	assert.Equal(t, http.StatusOK, request("state=expected&code=abc"))
+	result := <-results
+	assert.Nil(t, result.err)
+	assert.Equal(t, "abc", result.code)
+
+	results = make(chan authorizationResult, 1)
+	handler = loginCallbackHandler("expected", results)
+	assert.Equal(t, http.StatusBadRequest, request("state=expected&error=access_denied"))
+	assert.ErrorIs(t, (<-results).err, ErrLoginDenied)
+}
+
+func TestDevicePoll(t *testing.T) {
+	now := time.Now()
+	pending := &APIError{StatusCode: http.StatusForbidden}
+	t.Run("uses the server's interval", func(t *testing.T) {
+		poll := newDevicePoll(&DeviceCodeResponse{Interval: 5}, now)
+		wait, err := poll.next(pending, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 5*time.Second, wait)
+		wait, err = poll.next(&APIError{StatusCode: http.StatusBadRequest, Err: "authorization_pending"}, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 5*time.Second, wait)
+	})
+	t.Run("slows down when asked", func(t *testing.T) {
+		poll := newDevicePoll(&DeviceCodeResponse{Interval: 5}, now)
+		wait, err := poll.next(&APIError{StatusCode: http.StatusBadRequest, Err: "slow_down"}, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 10*time.Second, wait)
+		wait, err = poll.next(pending, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 10*time.Second, wait)
+	})
+	t.Run("makes a last poll at expiry and then gives up", func(t *testing.T) {
+		poll := newDevicePoll(&DeviceCodeResponse{Interval: 5, ExpiresIn: 12}, now)
+		wait, err := poll.next(pending, now.Add(10*time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, 2*time.Second, wait)
+		_, err = poll.next(pending, now.Add(12*time.Second))
+		assert.ErrorIs(t, err, ErrLoginExpired)
+	})
+	t.Run("fails on other errors", func(t *testing.T) {
+		poll := newDevicePoll(&DeviceCodeResponse{}, now)
+		_, err := poll.next(&APIError{StatusCode: http.StatusBadRequest, Err: "access_denied"}, now)
+		assert.ErrorIs(t, err, ErrLoginDenied)
+		_, err = poll.next(&APIError{StatusCode: http.StatusBadRequest, Err: "expired_token"}, now)
+		assert.ErrorIs(t, err, ErrLoginExpired)
+		_, err = poll.next(&APIError{StatusCode: http.StatusInternalServerError}, now)
+		assert.ErrorContains(t, err, "failed to request token")
+	})
 }
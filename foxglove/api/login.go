@@ -0,0 +1,379 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTokenPollInterval is used if the server doesn't specify how
+	// often the token endpoint may be polled.
+	defaultTokenPollInterval = 500 * time.Millisecond
+	// slowDownIncrement is added to the poll interval each time the server
+	// asks the client to slow down, as specified by RFC 8628.
+	slowDownIncrement = 5 * time.Second
+)
+
+// browserLoginTimeout is how long a browser login waits for the user to be
+// redirected back before falling back to the device code flow, in case the
+// browser never opened the page or the user closed it.
+var browserLoginTimeout = 5 * time.Minute
+
+// errBrowserLoginUnavailable is returned by loginWithBrowser if the login
+// could not be started, so that Login falls back to the device code flow.
+var errBrowserLoginUnavailable = errors.New("browser login unavailable")
+
+// AuthDelegate opens pages in the user's browser during Login.
+type AuthDelegate interface {
+	// browserAvailable reports whether there is a browser on this machine to
+	// complete a login in, so that the authorization server can redirect
+	// back to the CLI.
+	browserAvailable() bool
+	openBrowser(url string) (*exec.Cmd, error)
+}
+
+// PlatformAuthDelegate opens pages in the system's default browser.
+type PlatformAuthDelegate struct{}
+
+func (_ *PlatformAuthDelegate) browserAvailable() bool {
+	switch runtime.GOOS {
+	case "linux":
+		// Without a display, xdg-open would fall back to a text-mode
+		// browser, or fail noisily.
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	case "windows", "darwin":
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *PlatformAuthDelegate) openBrowser(url string) (*exec.Cmd, error) {
+	if !d.browserAvailable() {
+		return nil, fmt.Errorf("no browser available")
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("xdg-open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	}
+	return cmd, cmd.Start()
+}
+
+// Login signs in interactively and returns a bearer token. If a browser is
+// available, the user logs in with it and is redirected back to a listener
+// on the loopback interface, using the OAuth authorization code flow with
+// PKCE. Otherwise, if the server doesn't offer browser logins, if the user
+// isn't redirected back within a few minutes, or if WithoutBrowser or
+// WithDeviceCode is given, it uses the device code flow: the user enters a
+// code shown with the prompt set by WithLoginPrompt, on any device, and Login
+// polls for the token until the code is authorized or expires.
+func Login(ctx context.Context, client *FoxgloveClient, authDelegate AuthDelegate, opts ...LoginOption) (string, error) {
+	o := newLoginOptions(opts)
+	if !o.noBrowser && !o.deviceCode && authDelegate.browserAvailable() {
+		token, err := loginWithBrowser(ctx, client, authDelegate, o)
+		if !errors.Is(err, errBrowserLoginUnavailable) {
+			return token, err
+		}
+	}
+	return loginWithDeviceCode(ctx, client, authDelegate, o)
+}
+
+// authorizationResult is the outcome of a login in the browser, delivered to
+// the loopback listener.
+type authorizationResult struct {
+	code string
+	err  error
+}
+
+func loginWithBrowser(ctx context.Context, client *FoxgloveClient, authDelegate AuthDelegate, o *loginOptions) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errBrowserLoginUnavailable, err)
+	}
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr())
+	verifier, challenge, err := newPKCEChallenge()
+	if err != nil {
+		listener.Close()
+		return "", err
+	}
+	state, err := randomURLSafeString(16)
+	if err != nil {
+		listener.Close()
+		return "", err
+	}
+	authorizeURL := client.authorizeURL(redirectURI, challenge, state)
+	if err := client.checkAuthorizeEndpoint(ctx, authorizeURL); err != nil {
+		listener.Close()
+		return "", fmt.Errorf("%w: %w", errBrowserLoginUnavailable, err)
+	}
+	results := make(chan authorizationResult, 1)
+	server := &http.Server{
+		Handler:           loginCallbackHandler(state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	browser, err := authDelegate.openBrowser(authorizeURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errBrowserLoginUnavailable, err)
+	}
+	defer func() {
+		_ = browser.Process.Kill()
+	}()
+	o.prompt(LoginPrompt{
+		VerificationURI:         authorizeURL,
+		VerificationURIComplete: authorizeURL,
+		BrowserOpened:           true,
+	})
+
+	var result authorizationResult
+	timer := time.NewTimer(browserLoginTimeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return "", context.Canceled
+	case <-timer.C:
+		return "", fmt.Errorf("%w: no response from the browser within %s", errBrowserLoginUnavailable, browserLoginTimeout)
+	case result = <-results:
+	}
+	if result.err != nil {
+		return "", result.err
+	}
+	token, err := client.ExchangeAuthorizationCode(ctx, result.code, verifier, redirectURI)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	bearerToken, err := client.SignIn(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign in: %w", err)
+	}
+	return bearerToken, nil
+}
+
+// loginCallbackHandler receives the redirect from the authorization server
+// once the user has logged in, and delivers the first result. Requests that
+// don't carry the login's state are rejected, since they weren't caused by
+// this login.
+func loginCallbackHandler(state string, results chan<- authorizationResult) http.Handler {
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "This login link has expired. Run `foxglove auth login` again.", http.StatusBadRequest)
+			return
+		}
+		result := authorizationResult{code: query.Get("code")}
+		switch {
+		case query.Get("error") == "access_denied":
+			result.err = ErrLoginDenied
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s", coalesce(query.Get("error_description"), query.Get("error")))
+		case result.code == "":
+			result.err = fmt.Errorf("authorization response is missing a code")
+		}
+		if result.err != nil {
+			http.Error(w, "Login failed: "+result.err.Error(), http.StatusBadRequest)
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, loginCompletePage)
+		}
+		once.Do(func() {
+			results <- result
+		})
+	})
+}
+
+const loginCompletePage = `<!DOCTYPE html>
+<html>
+<head><title>Foxglove CLI</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 4em">
+<p>You are logged in to the Foxglove CLI. You can close this window.</p>
+</body>
+</html>
+`
+
+// newPKCEChallenge returns a PKCE code verifier and its S256 challenge.
+func newPKCEChallenge() (verifier string, challenge string, err error) {
+	verifier, err = randomURLSafeString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func randomURLSafeString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// authorizeURL returns the page at which the user logs in to authorize the
+// CLI, after which they are redirected to redirectURI.
+func (c *FoxgloveClient) authorizeURL(redirectURI, challenge, state string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+	}
+	return c.baseurl + "/v1/auth/authorize?" + query.Encode()
+}
+
+// checkAuthorizeEndpoint returns an error unless the server has a login page
+// at authorizeURL for browser logins, which servers that only offer device
+// codes do not. The page is expected to respond with a success or to redirect,
+// which is not followed; any other response, such as a 404 or a server error,
+// means the browser login is unavailable.
+func (c *FoxgloveClient) checkAuthorizeEndpoint(ctx context.Context, authorizeURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authorizeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build authorize request: %w", err)
+	}
+	markIdempotent(req)
+	probe := *c.unauthed
+	probe.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := probe.Do(req)
+	if err != nil {
+		return fmt.Errorf("authorize request failure: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+func loginWithDeviceCode(ctx context.Context, client *FoxgloveClient, authDelegate AuthDelegate, o *loginOptions) (string, error) {
+	info, err := client.DeviceCode(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch device code: %w", err)
+	}
+	poll := newDevicePoll(info, time.Now())
+	prompt := LoginPrompt{
+		VerificationURI:         info.VerificationUri,
+		VerificationURIComplete: coalesce(info.VerificationUriComplete, info.VerificationUri),
+		UserCode:                info.UserCode,
+		ExpiresAt:               poll.deadline,
+	}
+	if !o.noBrowser {
+		browser, err := authDelegate.openBrowser(prompt.VerificationURIComplete)
+		// There's no way to tell for sure whether the browser actually opened
+		// the link, even if the openBrowser command succeeds.
+		if err == nil {
+			defer func() {
+				_ = browser.Process.Kill()
+			}()
+			prompt.BrowserOpened = true
+		}
+	}
+	o.prompt(prompt)
+
+	// Poll the token endpoint until the token for the device code appears.
+	// While the user has yet to authorize the code, the endpoint returns a
+	// 403 or an authorization_pending error.
+	var token string
+	for {
+		token, err = client.Token(ctx, info.DeviceCode)
+		if ctx.Err() != nil {
+			return "", context.Canceled
+		}
+		if err == nil {
+			break
+		}
+		wait, err := poll.next(err, time.Now())
+		if err != nil {
+			return "", err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", context.Canceled
+		case <-timer.C:
+		}
+	}
+	bearerToken, err := client.SignIn(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign in: %w", err)
+	}
+	return bearerToken, nil
+}
+
+// devicePoll schedules polls of the token endpoint during a device code
+// login.
+type devicePoll struct {
+	interval time.Duration
+	// deadline is when the device code expires, or zero if unknown.
+	deadline time.Time
+}
+
+func newDevicePoll(info *DeviceCodeResponse, now time.Time) *devicePoll {
+	poll := &devicePoll{interval: defaultTokenPollInterval}
+	if info.Interval > 0 {
+		poll.interval = time.Duration(info.Interval) * time.Second
+	}
+	if info.ExpiresIn > 0 {
+		poll.deadline = now.Add(time.Duration(info.ExpiresIn) * time.Second)
+	}
+	return poll
+}
+
+// next returns how long to wait before polling again after a poll failed
+// with err, or the error that ends the login.
+func (p *devicePoll) next(err error, now time.Time) (time.Duration, error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return 0, fmt.Errorf("failed to request token: %w", err)
+	}
+	switch {
+	case apiErr.Err == "slow_down", apiErr.StatusCode == http.StatusTooManyRequests:
+		p.interval += slowDownIncrement
+	case apiErr.Err == "expired_token":
+		return 0, ErrLoginExpired
+	case apiErr.Err == "access_denied":
+		return 0, ErrLoginDenied
+	case apiErr.Err == "authorization_pending", errors.Is(err, ErrForbidden):
+	default:
+		return 0, fmt.Errorf("failed to request token: %w", err)
+	}
+	if p.deadline.IsZero() {
+		return p.interval, nil
+	}
+	if !now.Before(p.deadline) {
+		return 0, ErrLoginExpired
+	}
+	// Make a last poll as the code expires, in case the user authorized it
+	// just in time.
+	return min(p.interval, p.deadline.Sub(now)), nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +14,7 @@ func NewMockAuthedClient(t *testing.T, baseUrl string) *FoxgloveClient {
 		"",
 		"user-agent",
 	)
-	token, err := client.SignIn("client-id")
+	token, err := client.SignIn(context.Background(), "client-id")
 	assert.Nil(t, err)
 	return NewRemoteFoxgloveClient(
 		baseUrl,
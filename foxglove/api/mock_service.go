@@ -1,16 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,8 +33,59 @@ type MockFoxgloveServer struct {
 	registeredDevices    []DevicesResponse
 	registeredSessions   []SessionResponse
 	registeredProperties []CustomPropertiesResponseItem
+	registeredRecordings []RecordingsResponse
+	registeredEvents     []EventResponseItem
+	apiKeys              []APIKeyResponse
+	apiKeySecrets        map[string]string // API key -> API key ID
 	tokenRequests        int
+	deviceCodeExpiry     map[string]time.Time          // device code -> expiry
+	authorizations       map[string]*mockAuthorization // authorization code -> login
 	port                 int
+	faults               []*Fault
+	uploadSessions       map[string]*mockUploadSession
+	committedUploadBytes int64
+	// ResumableUploads enables resumable upload sessions for clients that
+	// request them.
+	ResumableUploads bool
+	// RangeDownloads enables HTTP range requests on download links.
+	RangeDownloads bool
+	// DeviceCodeExpiry, if set, is how long device codes may be polled for
+	// a token before the token endpoint reports them expired.
+	DeviceCodeExpiry time.Duration
+	// DenyAuthorization makes browser logins fail as if the user declined
+	// them.
+	DenyAuthorization bool
+	// NoBrowserLogins removes the login page for browser logins, as on
+	// servers that only offer device codes.
+	NoBrowserLogins bool
+	// StreamData, if set, returns the data served for a stream request in
+	// place of the file uploaded for the requested device.
+	StreamData    func(req StreamRequest) []byte
+	streamCount   int
+	requestCounts map[string]int // path -> number of requests received
+}
+
+// Fault describes a transient failure injected into the mock server, for
+// exercising client retries.
+type Fault struct {
+	// PathPrefix selects the requests the fault applies to.
+	PathPrefix string
+	// Count is the number of matching requests that fail before the server
+	// recovers.
+	Count int
+	// Status is the HTTP status returned. Zero drops the connection without
+	// writing a response.
+	Status int
+	// RetryAfter, if set, is returned in the Retry-After header.
+	RetryAfter string
+	// TruncateAfter, if positive, lets the request through but drops the
+	// connection once this many bytes of the response body are written.
+	// Status is ignored.
+	TruncateAfter int
+	// DropResponse, if set, lets the request through but drops the
+	// connection in place of the response, as if the response was lost on
+	// its way back. Status is ignored.
+	DropResponse bool
 }
 
 func randomString(n int) (string, error) {
@@ -71,6 +129,15 @@ func (s *MockFoxgloveServer) signIn(w http.ResponseWriter, r *http.Request) {
 	s.BearerTokens[bearerToken] = req.Token
 }
 
+// signOut revokes the bearer token the request was made with.
+func (s *MockFoxgloveServer) signOut(w http.ResponseWriter, r *http.Request) {
+	_, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	s.mtx.Lock()
+	delete(s.BearerTokens, token)
+	s.mtx.Unlock()
+	_ = json.NewEncoder(w).Encode(SignOutResponse{})
+}
+
 func (s *MockFoxgloveServer) stream(w http.ResponseWriter, r *http.Request) {
 	req := StreamRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -80,10 +147,19 @@ func (s *MockFoxgloveServer) stream(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var path string
-	for k := range s.Uploads {
-		if strings.HasPrefix(k, fmt.Sprintf("device_id=%s/", req.DeviceID)) {
-			path = k
-			break
+	if s.StreamData != nil {
+		data := s.StreamData(req)
+		s.mtx.Lock()
+		s.streamCount++
+		path = fmt.Sprintf("streams/%d", s.streamCount)
+		s.Uploads[path] = data
+		s.mtx.Unlock()
+	} else {
+		for k := range s.Uploads {
+			if strings.HasPrefix(k, fmt.Sprintf("device_id=%s/", req.DeviceID)) {
+				path = k
+				break
+			}
 		}
 	}
 	err = json.NewEncoder(w).Encode(StreamResponse{
@@ -124,7 +200,8 @@ func (s *MockFoxgloveServer) uploadRedirect(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	err = json.NewEncoder(w).Encode(UploadResponse{
-		Link: fmt.Sprintf("http://localhost:%d/storage/device_id=%s/%s", s.port, device.ID, req.Filename),
+		Link:      fmt.Sprintf("http://localhost:%d/storage/device_id=%s/%s", s.port, device.ID, req.Filename),
+		Resumable: req.Resumable && s.ResumableUploads,
 	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -142,6 +219,88 @@ func (s *MockFoxgloveServer) upload(w http.ResponseWriter, r *http.Request) {
 	s.Uploads[key] = bytes
 }
 
+type mockUploadSession struct {
+	key  string
+	data []byte
+}
+
+// startUploadSession starts a resumable upload session, in the manner of
+// cloud storage.
+func (s *MockFoxgloveServer) startUploadSession(w http.ResponseWriter, r *http.Request) {
+	if !s.ResumableUploads || r.Header.Get("x-goog-resumable") != "start" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	id, err := randomString(12)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.mtx.Lock()
+	s.uploadSessions[id] = &mockUploadSession{key: mux.Vars(r)["key"]}
+	s.mtx.Unlock()
+	w.Header().Set("Location", fmt.Sprintf("http://localhost:%d/upload-sessions/%s", s.port, id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// uploadChunk accepts a chunk of a resumable upload, or reports the
+// committed offset when the Content-Range is "bytes */size".
+func (s *MockFoxgloveServer) uploadChunk(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	session, ok := s.uploadSessions[mux.Vars(r)["id"]]
+	s.mtx.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var first, last, size int64
+	contentRange := r.Header.Get("Content-Range")
+	isQuery := false
+	if _, err := fmt.Sscanf(contentRange, "bytes */%d", &size); err == nil {
+		isQuery = true
+	} else if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &first, &last, &size); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// Read the whole chunk before committing any of it, so that an
+	// interrupted request commits nothing.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	committed := int64(len(session.data))
+	if !isQuery {
+		if first > committed || int64(len(body)) != last-first+1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fresh := body[min(committed-first, int64(len(body))):]
+		session.data = append(session.data, fresh...)
+		s.committedUploadBytes += int64(len(fresh))
+		committed = int64(len(session.data))
+	}
+	if committed == size {
+		s.Uploads[session.key] = session.data
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if committed > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", committed-1))
+	}
+	w.WriteHeader(308)
+}
+
+// CommittedUploadBytes returns the number of bytes committed across all
+// resumable upload sessions, excluding any that were resent.
+func (s *MockFoxgloveServer) CommittedUploadBytes() int64 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.committedUploadBytes
+}
+
 func (s *MockFoxgloveServer) createDevice(w http.ResponseWriter, r *http.Request) {
 	req := CreateDeviceRequest{}
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -212,10 +371,7 @@ func (s *MockFoxgloveServer) editDevice(w http.ResponseWriter, r *http.Request)
 func (s *MockFoxgloveServer) devices(w http.ResponseWriter, r *http.Request) {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
-	err := json.NewEncoder(w).Encode(s.registeredDevices)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
+	writeJSONWithETag(w, r, s.registeredDevices)
 }
 
 func (s *MockFoxgloveServer) sessionsList(w http.ResponseWriter, r *http.Request) {
@@ -309,7 +465,13 @@ func (s *MockFoxgloveServer) patchSession(w http.ResponseWriter, r *http.Request
 				recs = []SessionRecordingSummary{}
 			}
 			for _, addID := range req.AddRecordingIDs {
-				recs = append(recs, SessionRecordingSummary{ID: addID})
+				summary := SessionRecordingSummary{ID: addID}
+				for _, recording := range s.registeredRecordings {
+					if recording.ID == addID {
+						summary.Path, summary.Start, summary.End = recording.Path, recording.Start, recording.End
+					}
+				}
+				recs = append(recs, summary)
 			}
 			if len(req.RemoveRecordingIDs) > 0 {
 				removeSet := make(map[string]bool)
@@ -355,6 +517,73 @@ func (s *MockFoxgloveServer) deleteSession(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusNotFound)
 }
 
+func (s *MockFoxgloveServer) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	err := json.NewEncoder(w).Encode(append([]APIKeyResponse{}, s.apiKeys...))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// createAPIKey mints an API key, which is accepted as a bearer token until
+// it is revoked.
+func (s *MockFoxgloveServer) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	req := CreateAPIKeyRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Label == "" || len(req.Capabilities) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(ErrorResponse{Error: "label and capabilities are required"})
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	id, _ := randomString(8)
+	secret, _ := randomString(32)
+	resp := CreateAPIKeyResponse{
+		APIKeyResponse: APIKeyResponse{
+			ID:           "ak_" + id,
+			Label:        req.Label,
+			Capabilities: req.Capabilities,
+			CreatedAt:    time.Now(),
+		},
+		Key: "fox_sk_" + secret,
+	}
+	s.mtx.Lock()
+	s.apiKeys = append(s.apiKeys, resp.APIKeyResponse)
+	s.apiKeySecrets[resp.Key] = resp.ID
+	s.BearerTokens[resp.Key] = resp.ID
+	s.mtx.Unlock()
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *MockFoxgloveServer) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, key := range s.apiKeys {
+		if key.ID == id {
+			s.apiKeys = append(s.apiKeys[:i], s.apiKeys[i+1:]...)
+			for secret, keyID := range s.apiKeySecrets {
+				if keyID == id {
+					delete(s.apiKeySecrets, secret)
+					delete(s.BearerTokens, secret)
+				}
+			}
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
 func (s *MockFoxgloveServer) imports(w http.ResponseWriter, r *http.Request) {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
@@ -382,9 +611,7 @@ func (s *MockFoxgloveServer) deviceCode(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	err = json.NewEncoder(w).Encode(DeviceCodeResponse{
-		DeviceCode: deviceCode,
-	})
+	userCode, err := randomString(8)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -395,8 +622,100 @@ func (s *MockFoxgloveServer) deviceCode(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	s.mtx.Lock()
-	defer s.mtx.Unlock()
 	s.IDTokens[deviceCode] = token
+	expiresIn := 0
+	if s.DeviceCodeExpiry > 0 {
+		s.deviceCodeExpiry[deviceCode] = time.Now().Add(s.DeviceCodeExpiry)
+		expiresIn = int(math.Ceil(s.DeviceCodeExpiry.Seconds()))
+	}
+	s.mtx.Unlock()
+	err = json.NewEncoder(w).Encode(DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		ExpiresIn:               expiresIn,
+		VerificationUri:         s.BaseURL() + "/activate",
+		VerificationUriComplete: s.BaseURL() + "/activate?user_code=" + userCode,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// mockAuthorization is a browser login awaiting exchange of its code.
+type mockAuthorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+}
+
+// authorize stands in for the login page of a browser login. The user is
+// taken to have logged in, and is redirected back to the CLI with a code.
+func (s *MockFoxgloveServer) authorize(w http.ResponseWriter, r *http.Request) {
+	if s.NoBrowserLogins {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme != "http" || redirectURI.Hostname() != "127.0.0.1" {
+		http.Error(w, "redirect_uri must be a loopback address", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "a PKCE code challenge is required", http.StatusBadRequest)
+		return
+	}
+	callback := url.Values{"state": {query.Get("state")}}
+	if s.DenyAuthorization {
+		callback.Set("error", "access_denied")
+	} else {
+		code, err := randomString(16)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.mtx.Lock()
+		s.authorizations[code] = &mockAuthorization{
+			clientID:    query.Get("client_id"),
+			redirectURI: redirectURI.String(),
+			challenge:   query.Get("code_challenge"),
+		}
+		s.mtx.Unlock()
+		callback.Set("code", code)
+	}
+	redirectURI.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// exchangeCode issues a token for the code of a browser login, if the PKCE
+// verifier matches the challenge the login was started with.
+func (s *MockFoxgloveServer) exchangeCode(w http.ResponseWriter, req TokenRequest) {
+	s.mtx.Lock()
+	login, ok := s.authorizations[req.Code]
+	delete(s.authorizations, req.Code)
+	s.mtx.Unlock()
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	if !ok ||
+		login.clientID != req.ClientID ||
+		login.redirectURI != req.RedirectURI ||
+		login.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid_grant"})
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	token, err := randomString(32)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(TokenResponse{IDToken: token})
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 func (s *MockFoxgloveServer) token(w http.ResponseWriter, r *http.Request) {
@@ -406,6 +725,22 @@ func (s *MockFoxgloveServer) token(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if req.Code != "" {
+		s.exchangeCode(w, req)
+		return
+	}
+
+	s.mtx.RLock()
+	expiry, ok := s.deviceCodeExpiry[req.DeviceCode]
+	s.mtx.RUnlock()
+	if ok && time.Now().After(expiry) {
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(ErrorResponse{Error: "expired_token"})
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
 
 	// on the first two requests, return a 403 to simulate the poll during the browser interaction
 	if s.tokenRequests < 2 {
@@ -431,16 +766,16 @@ func (s *MockFoxgloveServer) getStream(w http.ResponseWriter, r *http.Request) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 	key := mux.Vars(r)["key"]
-	if bytes, ok := s.Uploads[key]; ok {
-		_, err := w.Write(bytes)
-		if err != nil {
-			fmt.Println(err)
-		}
-	} else {
-		_, err := w.Write([]byte{})
-		if err != nil {
-			fmt.Println(err)
-		}
+	data, ok := s.Uploads[key]
+	if ok && s.RangeDownloads {
+		sum := sha256.Sum256(data)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:8]))
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+		return
+	}
+	_, err := w.Write(data)
+	if err != nil {
+		fmt.Println(err)
 	}
 }
 
@@ -476,10 +811,25 @@ func (s *MockFoxgloveServer) ValidExtensionId() string {
 func (s *MockFoxgloveServer) customProperties(w http.ResponseWriter, r *http.Request) {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
-	err := json.NewEncoder(w).Encode(s.registeredProperties)
+	writeJSONWithETag(w, r, s.registeredProperties)
+}
+
+// writeJSONWithETag writes v as JSON with an ETag derived from its content,
+// or responds 304 Not Modified if the request already holds that version.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	data, err := json.Marshal(v)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := fmt.Sprintf(`"%x"`, sum[:8])
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
+	_, _ = w.Write(data)
 }
 
 func (s *MockFoxgloveServer) projects(w http.ResponseWriter, r *http.Request) {
@@ -497,6 +847,59 @@ func (s *MockFoxgloveServer) projects(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// paginate applies the limit and offset query parameters of r to items.
+func paginate[T any](items []T, r *http.Request) []T {
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = len(items)
+	}
+	if offset >= len(items) {
+		return []T{}
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
+
+func (s *MockFoxgloveServer) recordings(w http.ResponseWriter, r *http.Request) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	err := json.NewEncoder(w).Encode(paginate(s.registeredRecordings, r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *MockFoxgloveServer) recording(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, recording := range s.registeredRecordings {
+		if recording.ID == id {
+			_ = json.NewEncoder(w).Encode(recording)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (s *MockFoxgloveServer) events(w http.ResponseWriter, r *http.Request) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	err := json.NewEncoder(w).Encode(paginate(s.registeredEvents, r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *MockFoxgloveServer) RegisteredRecordings() []RecordingsResponse {
+	return s.registeredRecordings
+}
+
+func (s *MockFoxgloveServer) RegisteredEvents() []EventResponseItem {
+	return s.registeredEvents
+}
+
 func (s *MockFoxgloveServer) RegisteredProperties() []CustomPropertiesResponseItem {
 	return s.registeredProperties
 }
@@ -505,32 +908,148 @@ func (s *MockFoxgloveServer) RegisteredDevices() []DevicesResponse {
 	return s.registeredDevices
 }
 
+// InjectFault causes the next f.Count requests matching f.PathPrefix to fail.
+func (s *MockFoxgloveServer) InjectFault(f Fault) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// RequestCount returns the number of requests received for a path, including
+// those that failed due to an injected fault.
+func (s *MockFoxgloveServer) RequestCount(path string) int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.requestCounts[path]
+}
+
+func (s *MockFoxgloveServer) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mtx.Lock()
+		s.requestCounts[r.URL.Path]++
+		var fault *Fault
+		for _, f := range s.faults {
+			if f.Count > 0 && strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+				f.Count--
+				fault = f
+				break
+			}
+		}
+		s.mtx.Unlock()
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if fault.TruncateAfter > 0 {
+			next.ServeHTTP(&truncatingWriter{ResponseWriter: w, remaining: fault.TruncateAfter}, r)
+			return
+		}
+		if fault.DropResponse {
+			next.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		if fault.Status == 0 || fault.DropResponse {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			conn.Close()
+			return
+		}
+		if fault.RetryAfter != "" {
+			w.Header().Set("Retry-After", fault.RetryAfter)
+		}
+		w.WriteHeader(fault.Status)
+	})
+}
+
+// truncatingWriter drops the connection once a number of bytes have been
+// written, simulating a transfer that is cut off partway.
+type truncatingWriter struct {
+	http.ResponseWriter
+	remaining int
+	dropped   bool
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if w.dropped {
+		return 0, net.ErrClosed
+	}
+	if len(p) <= w.remaining {
+		w.remaining -= len(p)
+		return w.ResponseWriter.Write(p)
+	}
+	n, err := w.ResponseWriter.Write(p[:w.remaining])
+	if err != nil {
+		return n, err
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+	w.dropped = true
+	conn, _, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return n, err
+	}
+	conn.Close()
+	return n, net.ErrClosed
+}
+
 func (s *MockFoxgloveServer) withAuthz(next func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(r.Header.Get("Authorization"), " ")
 		if len(parts) != 2 {
-			w.WriteHeader(http.StatusForbidden)
+			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		s.mtx.RLock()
+		s.mtx.Lock()
 		if _, ok := s.BearerTokens[parts[1]]; !ok {
-			s.mtx.RUnlock()
-			w.WriteHeader(http.StatusForbidden)
+			s.mtx.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		s.mtx.RUnlock()
+		if id, ok := s.apiKeySecrets[parts[1]]; ok {
+			now := time.Now()
+			for i := range s.apiKeys {
+				if s.apiKeys[i].ID == id {
+					s.apiKeys[i].LastUsedAt = &now
+				}
+			}
+		}
+		s.mtx.Unlock()
 		next(w, r)
 	}
 }
 
 func mockServer(port int) *MockFoxgloveServer {
+	device := DeviceSummary{ID: "test-device", Name: "my test device"}
+	recordings := make([]RecordingsResponse, 25)
+	events := make([]EventResponseItem, 25)
+	for i := range recordings {
+		start := time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC)
+		recordings[i] = RecordingsResponse{
+			ID:        fmt.Sprintf("rec_%04d", i),
+			Path:      fmt.Sprintf("recording-%d.mcap", i),
+			Start:     start.Format(time.RFC3339),
+			End:       start.Add(30 * time.Minute).Format(time.RFC3339),
+			Device:    device,
+			ProjectID: "prj_1234abcd",
+		}
+		events[i] = EventResponseItem{
+			ID:     fmt.Sprintf("evt_%04d", i),
+			Device: device,
+		}
+	}
 	return &MockFoxgloveServer{
-		mtx:           &sync.RWMutex{},
-		Uploads:       make(map[string][]byte),
-		IDTokens:      make(map[string]string),
-		BearerTokens:  make(map[string]string),
-		tokenRequests: 0,
-		port:          port,
+		mtx:              &sync.RWMutex{},
+		Uploads:          make(map[string][]byte),
+		IDTokens:         make(map[string]string),
+		deviceCodeExpiry: make(map[string]time.Time),
+		authorizations:   make(map[string]*mockAuthorization),
+		BearerTokens:     make(map[string]string),
+		apiKeySecrets:    make(map[string]string),
+		tokenRequests:    0,
+		port:             port,
+		requestCounts:    make(map[string]int),
+		uploadSessions:   make(map[string]*mockUploadSession),
 		registeredDevices: []DevicesResponse{
 			{
 				ID:        "test-device",
@@ -540,7 +1059,9 @@ func mockServer(port int) *MockFoxgloveServer {
 				UpdatedAt: time.Now(),
 			},
 		},
-		registeredSessions: []SessionResponse{},
+		registeredSessions:   []SessionResponse{},
+		registeredRecordings: recordings,
+		registeredEvents:     events,
 		registeredProperties: []CustomPropertiesResponseItem{
 			{Key: "str", ResourceType: "devices", Label: "", ValueType: "string"},
 			{Key: "num", ResourceType: "devices", Label: "", ValueType: "number"},
@@ -557,12 +1078,17 @@ func (sv *MockFoxgloveServer) liveness(w http.ResponseWriter, r *http.Request) {
 func makeRoutes(sv *MockFoxgloveServer) *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/v1/signin", sv.signIn).Methods("POST")
+	r.HandleFunc("/v1/signout", sv.withAuthz(sv.signOut)).Methods("POST")
+	r.HandleFunc("/v1/api-keys", sv.withAuthz(sv.listAPIKeys)).Methods("GET")
+	r.HandleFunc("/v1/api-keys", sv.withAuthz(sv.createAPIKey)).Methods("POST")
+	r.HandleFunc("/v1/api-keys/{id}", sv.withAuthz(sv.revokeAPIKey)).Methods("DELETE")
 	r.HandleFunc("/v1/custom-properties", sv.withAuthz(sv.customProperties)).Methods("GET")
 	r.HandleFunc("/v1/data/stream", sv.withAuthz(sv.stream)).Methods("POST")
 	r.HandleFunc("/v1/data/imports", sv.withAuthz(sv.imports)).Methods("GET")
 	r.HandleFunc("/v1/data/upload", sv.withAuthz(sv.uploadRedirect)).Methods("POST")
 	r.HandleFunc("/v1/auth/device-code", sv.deviceCode).Methods("POST")
 	r.HandleFunc("/v1/auth/token", sv.token).Methods("POST")
+	r.HandleFunc("/v1/auth/authorize", sv.authorize).Methods("GET")
 	r.HandleFunc("/v1/devices", sv.withAuthz(sv.createDevice)).Methods("POST")
 	r.HandleFunc("/v1/devices", sv.withAuthz(sv.devices)).Methods("GET")
 	r.HandleFunc("/v1/devices/{id}", sv.withAuthz(sv.editDevice)).Methods("PATCH")
@@ -572,12 +1098,18 @@ func makeRoutes(sv *MockFoxgloveServer) *mux.Router {
 	r.HandleFunc("/v1/sessions/{id}", sv.withAuthz(sv.patchSession)).Methods("PATCH")
 	r.HandleFunc("/v1/sessions/{id}", sv.withAuthz(sv.deleteSession)).Methods("DELETE")
 	r.HandleFunc("/v1/projects", sv.withAuthz(sv.projects)).Methods("GET")
+	r.HandleFunc("/v1/recordings", sv.withAuthz(sv.recordings)).Methods("GET")
+	r.HandleFunc("/v1/recordings/{id}", sv.withAuthz(sv.recording)).Methods("GET")
+	r.HandleFunc("/v1/events", sv.withAuthz(sv.events)).Methods("GET")
 	r.HandleFunc("/v1/extension-upload", sv.withAuthz(sv.uploadExtension)).Methods("POST")
 	r.HandleFunc("/v1/extensions", sv.withAuthz(sv.listExtensions)).Methods("GET")
 	r.HandleFunc("/v1/extensions/{id}", sv.withAuthz(sv.deleteExtension)).Methods("DELETE")
 	r.HandleFunc("/storage/{key:.*}", sv.upload).Methods("PUT")
+	r.HandleFunc("/storage/{key:.*}", sv.startUploadSession).Methods("POST")
+	r.HandleFunc("/upload-sessions/{id}", sv.uploadChunk).Methods("PUT")
 	r.HandleFunc("/storage/{key:.*}", sv.getStream).Methods("GET")
 	r.HandleFunc("/liveness", sv.liveness).Methods("GET")
+	r.Use(sv.withFaults)
 	return r
 }
 
@@ -630,10 +1162,29 @@ func NewMockServer(ctx context.Context) (*MockFoxgloveServer, error) {
 	return sv, nil
 }
 
-// provides a no-op implementation of `openBrowser`
-type MockAuthDelegate struct{}
+// MockAuthDelegate stands in for the user's browser. By default there is no
+// browser to log in with, so Login uses the device code flow, and opening a
+// page does nothing. With Browser set, opened pages are fetched as if the user
+// had logged in, following redirects back to the CLI, unless AbandonLogin is
+// also set, in which case the user never finishes logging in.
+type MockAuthDelegate struct {
+	Browser      bool
+	AbandonLogin bool
+}
+
+func (del *MockAuthDelegate) browserAvailable() bool {
+	return del.Browser
+}
 
 func (del *MockAuthDelegate) openBrowser(url string) (*exec.Cmd, error) {
+	if del.Browser && !del.AbandonLogin {
+		go func() {
+			resp, err := http.Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
 	return &exec.Cmd{
 		Process: &os.Process{},
 	}, nil
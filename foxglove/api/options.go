@@ -0,0 +1,221 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultBaseURL is the Foxglove API used when ClientOptions.BaseURL is empty.
+const DefaultBaseURL = "https://api.foxglove.dev"
+
+// ClientOptions configures a client constructed with NewClient. Zero fields
+// take the defaults described below; none depend on package state.
+type ClientOptions struct {
+	// BaseURL is the Foxglove API to use. Empty uses DefaultBaseURL.
+	BaseURL string
+	// ClientID identifies the application to the API during sign in.
+	ClientID string
+	// Token is the bearer token or API key attached to authorized requests.
+	// It may be empty for the unauthenticated sign in flow, but authorized
+	// requests will then fail.
+	Token string
+	// UserAgent is sent with every request.
+	UserAgent string
+	// RetryPolicy governs retries of failed requests. The zero value uses
+	// DefaultRetryPolicy; set MaxAttempts to 1 to disable retries.
+	RetryPolicy RetryPolicy
+	// TraceLevel and TraceOutput control HTTP tracing. The zero level,
+	// TraceOff, disables it; a nil output writes traces to stderr.
+	TraceLevel  TraceLevel
+	TraceOutput io.Writer
+	// Transport carries requests. Nil uses http.DefaultTransport; see
+	// NewTransport to apply a TransportConfig.
+	Transport http.RoundTripper
+	// Cache stores lookups of slow-changing resources such as devices and
+	// custom properties. Nil disables caching.
+	Cache Cache
+	// CacheTTL is how long a cached response is used before it is
+	// revalidated. Zero uses DefaultCacheTTL.
+	CacheTTL time.Duration
+}
+
+// NewClient returns a client for the Foxglove API configured by opts.
+func NewClient(opts ClientOptions) *FoxgloveClient {
+	if opts.RetryPolicy == (RetryPolicy{}) {
+		opts.RetryPolicy = DefaultRetryPolicy()
+	}
+	if opts.TraceOutput == nil {
+		opts.TraceOutput = os.Stderr
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	baseURL := coalesce(opts.BaseURL, DefaultBaseURL)
+	client := &FoxgloveClient{
+		baseurl:     baseURL,
+		clientID:    opts.ClientID,
+		userAgent:   opts.UserAgent,
+		retry:       opts.RetryPolicy,
+		trace:       opts.TraceLevel,
+		traceOutput: opts.TraceOutput,
+		transport:   opts.Transport,
+		cache:       opts.Cache,
+		cacheTTL:    opts.CacheTTL,
+		cacheScope:  cacheScope(baseURL, opts.Token),
+	}
+	client.authed = client.makeClient(opts.Token)
+	client.unauthed = client.makeClient("")
+	client.storage = client.makeClient("")
+	return client
+}
+
+// ProgressFunc is called as a transfer proceeds with the number of bytes
+// transferred so far and the total, which is -1 if unknown. The count may go
+// backwards if part of a transfer is retried.
+type ProgressFunc func(transferred, total int64)
+
+// TransferOption configures an import, export or extension upload.
+type TransferOption func(*transferOptions)
+
+type transferOptions struct {
+	progress ProgressFunc
+	state    UploadState
+}
+
+func newTransferOptions(opts []TransferOption) *transferOptions {
+	o := &transferOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithProgress reports the progress of a transfer to fn. By default progress
+// is not reported.
+func WithProgress(fn ProgressFunc) TransferOption {
+	return func(o *transferOptions) {
+		o.progress = fn
+	}
+}
+
+// WithUploadState persists the progress of a file import in state, so that
+// an interrupted import is resumed by a later call with the same state. By
+// default progress is not persisted; see NewFileUploadState.
+func WithUploadState(state UploadState) TransferOption {
+	return func(o *transferOptions) {
+		o.state = state
+	}
+}
+
+// progressReader reports reads to a ProgressFunc. It deliberately hides any
+// Seek method of the underlying reader, which for a pipe would fail.
+type progressReader struct {
+	r           io.Reader
+	fn          ProgressFunc
+	transferred int64
+	total       int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.fn(r.transferred, r.total)
+	}
+	return n, err
+}
+
+// progressReaderAt reports reads to a ProgressFunc. Unlike progressReader it
+// can be reread from any offset, which allows failed uploads to be retried.
+type progressReaderAt struct {
+	ra    io.ReaderAt
+	fn    ProgressFunc
+	total int64
+}
+
+func (r *progressReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ra.ReadAt(p, off)
+	if n > 0 {
+		r.fn(off+int64(n), r.total)
+	}
+	return n, err
+}
+
+// progressWriter reports writes to a ProgressFunc.
+type progressWriter struct {
+	w           io.Writer
+	fn          ProgressFunc
+	transferred int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.transferred += int64(n)
+		w.fn(w.transferred, -1)
+	}
+	return n, err
+}
+
+// LoginPrompt holds what the user needs to authorize a device code login.
+type LoginPrompt struct {
+	// VerificationURI is the page on which to enter UserCode.
+	VerificationURI string
+	// VerificationURIComplete is the page with UserCode already filled in.
+	VerificationURIComplete string
+	// UserCode is the code the user confirms. It is empty for a login in a
+	// browser on this machine, which needs no code.
+	UserCode string
+	// ExpiresAt is when the code expires, or zero if unknown.
+	ExpiresAt time.Time
+	// BrowserOpened is set if a browser was launched with
+	// VerificationURIComplete. A window may not have opened even so.
+	BrowserOpened bool
+}
+
+// LoginOption configures Login.
+type LoginOption func(*loginOptions)
+
+type loginOptions struct {
+	noBrowser  bool
+	deviceCode bool
+	prompt     func(LoginPrompt)
+}
+
+func newLoginOptions(opts []LoginOption) *loginOptions {
+	o := &loginOptions{prompt: func(LoginPrompt) {}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithoutBrowser stops Login from opening the verification URL in a browser,
+// e.g. on a remote machine.
+func WithoutBrowser() LoginOption {
+	return func(o *loginOptions) {
+		o.noBrowser = true
+	}
+}
+
+// WithDeviceCode makes Login use the device code flow even if a browser is
+// available, e.g. so that the code can be relayed to the user by a script.
+func WithDeviceCode() LoginOption {
+	return func(o *loginOptions) {
+		o.deviceCode = true
+	}
+}
+
+// WithLoginPrompt shows the verification URL and user code with fn. By
+// default they are not shown, and the library writes nothing to the terminal,
+// so a caller that may use the device code flow must supply a prompt.
+func WithLoginPrompt(fn func(LoginPrompt)) LoginOption {
+	return func(o *loginOptions) {
+		o.prompt = fn
+	}
+}
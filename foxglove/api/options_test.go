@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foxglove/mcap/go/mcap"
+	"github.com/stretchr/testify/assert"
+)
+
+// progressRecorder records the reports made to a ProgressFunc.
+type progressRecorder struct {
+	transferred []int64
+	total       int64
+}
+
+func (r *progressRecorder) report(transferred, total int64) {
+	r.transferred = append(r.transferred, transferred)
+	r.total = total
+}
+
+func (r *progressRecorder) last() int64 {
+	if len(r.transferred) == 0 {
+		return 0
+	}
+	return r.transferred[len(r.transferred)-1]
+}
+
+func testMCAP(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	w, err := mcap.NewWriter(buf, &mcap.WriterOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, w.WriteHeader(&mcap.Header{}))
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestNewClient(t *testing.T) {
+	t.Run("uses defaults for zero options", func(t *testing.T) {
+		client := NewClient(ClientOptions{})
+		assert.Equal(t, DefaultBaseURL, client.baseurl)
+		assert.Equal(t, DefaultRetryPolicy(), client.retry)
+		assert.Equal(t, TraceOff, client.trace)
+		assert.Equal(t, http.DefaultTransport, client.transport)
+		assert.Nil(t, client.cache)
+	})
+	t.Run("applies supplied options", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		trace := &bytes.Buffer{}
+		client := NewClient(ClientOptions{
+			BaseURL:     sv.BaseURL(),
+			UserAgent:   "test-app",
+			RetryPolicy: RetryPolicy{MaxAttempts: 1},
+			TraceLevel:  TraceRequests,
+			TraceOutput: trace,
+		})
+		sv.InjectFault(Fault{PathPrefix: "/v1/auth/device-code", Count: 1, Status: http.StatusServiceUnavailable})
+		_, err = client.DeviceCode(ctx)
+		assert.ErrorContains(t, err, "503")
+		assert.Equal(t, 1, sv.RequestCount("/v1/auth/device-code"))
+		assert.Contains(t, trace.String(), "POST "+sv.BaseURL()+"/v1/auth/device-code 503")
+	})
+}
+
+func TestImportOptions(t *testing.T) {
+	ctx := context.Background()
+	data := testMCAP(t)
+	req := UploadRequest{Filename: "data.mcap", DeviceID: "test-device"}
+
+	t.Run("reports progress of a file import", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		filename := filepath.Join(t.TempDir(), "input.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		progress := &progressRecorder{}
+		err = ImportFile(ctx, client, filename, req, WithProgress(progress.report))
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads["device_id=test-device/data.mcap"])
+		assert.Equal(t, int64(len(data)), progress.last())
+		assert.Equal(t, int64(len(data)), progress.total)
+	})
+	t.Run("imports from a reader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		progress := &progressRecorder{}
+		err = ImportReader(ctx, client, bytes.NewReader(data), -1, req, WithProgress(progress.report))
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads["device_id=test-device/data.mcap"])
+		assert.Equal(t, int64(len(data)), progress.last())
+		assert.Equal(t, int64(-1), progress.total)
+	})
+	t.Run("requires a filename to import from a reader", func(t *testing.T) {
+		client := NewClient(ClientOptions{})
+		err := ImportReader(ctx, client, bytes.NewReader(data), -1, UploadRequest{DeviceID: "test-device"})
+		assert.EqualError(t, err, "a filename is required")
+	})
+	t.Run("reports progress of an extension upload", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		stat, err := os.Stat("../testdata/fg.mock-0.0.0.foxe")
+		assert.Nil(t, err)
+		progress := &progressRecorder{}
+		err = UploadExtensionFile(ctx, client, "../testdata/fg.mock-0.0.0.foxe", WithProgress(progress.report))
+		assert.Nil(t, err)
+		assert.Equal(t, stat.Size(), progress.last())
+		assert.Equal(t, stat.Size(), progress.total)
+	})
+	t.Run("reports progress of an export", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.Uploads["device_id=test-device/data.mcap"] = data
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		output := &bytes.Buffer{}
+		progress := &progressRecorder{}
+		err = Export(ctx, output, client, &StreamRequest{DeviceID: "test-device", OutputFormat: "mcap0"}, WithProgress(progress.report))
+		assert.Nil(t, err)
+		assert.Equal(t, data, output.Bytes())
+		assert.Equal(t, int64(len(data)), progress.last())
+	})
+}
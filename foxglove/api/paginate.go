@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"iter"
+)
+
+// DefaultPageSize is the page size used by Pages when the request does not
+// specify a limit.
+const DefaultPageSize = 100
+
+// PagedRequest is implemented by list requests that support limit/offset
+// pagination.
+type PagedRequest interface {
+	Page() (limit, offset int)
+	SetPage(limit, offset int)
+}
+
+func (r *RecordingsRequest) Page() (int, int) {
+	return r.Limit, r.Offset
+}
+
+func (r *RecordingsRequest) SetPage(limit, offset int) {
+	r.Limit = limit
+	r.Offset = offset
+}
+
+func (r *EventsRequest) Page() (int, int) {
+	return r.Limit, r.Offset
+}
+
+func (r *EventsRequest) SetPage(limit, offset int) {
+	r.Limit = limit
+	r.Offset = offset
+}
+
+// Pages returns an iterator over successive pages of results for req,
+// starting at its offset and using its limit as the page size. The offset of
+// req is advanced as pages are fetched. Iteration stops at the first page
+// that is shorter than the limit, which saves a request for an empty page.
+// Only one page is held in memory at a time.
+func Pages[RequestType PagedRequest, ResponseType any](
+	ctx context.Context,
+	req RequestType,
+	fn func(context.Context, RequestType) ([]ResponseType, error),
+) iter.Seq2[[]ResponseType, error] {
+	return func(yield func([]ResponseType, error) bool) {
+		limit, offset := req.Page()
+		if limit <= 0 {
+			limit = DefaultPageSize
+		}
+		for {
+			req.SetPage(limit, offset)
+			page, err := fn(ctx, req)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(page) == 0 {
+				return
+			}
+			if !yield(page, nil) || len(page) < limit {
+				return
+			}
+			offset += len(page)
+		}
+	}
+}
+
+// All returns an iterator over every record returned by fn, fetching pages
+// as required. See Pages.
+func All[RequestType PagedRequest, ResponseType any](
+	ctx context.Context,
+	req RequestType,
+	fn func(context.Context, RequestType) ([]ResponseType, error),
+) iter.Seq2[ResponseType, error] {
+	return func(yield func(ResponseType, error) bool) {
+		for page, err := range Pages(ctx, req, fn) {
+			if err != nil {
+				var zero ResponseType
+				yield(zero, err)
+				return
+			}
+			for _, record := range page {
+				if !yield(record, nil) {
+					return
+				}
+			}
+		}
+	}
+}
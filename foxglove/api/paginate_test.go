@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	ctx := context.Background()
+	t.Run("walks pages until exhausted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		pageSizes := []int{}
+		ids := []string{}
+		for page, err := range Pages(ctx, &RecordingsRequest{Limit: 10}, client.Recordings) {
+			assert.Nil(t, err)
+			pageSizes = append(pageSizes, len(page))
+			for _, recording := range page {
+				ids = append(ids, recording.ID)
+			}
+		}
+		assert.Equal(t, []int{10, 10, 5}, pageSizes)
+		// The short last page ends the walk without asking for another.
+		assert.Equal(t, 3, sv.RequestCount("/v1/recordings"))
+		expected := []string{}
+		for _, recording := range sv.RegisteredRecordings() {
+			expected = append(expected, recording.ID)
+		}
+		assert.Equal(t, expected, ids)
+	})
+	t.Run("starts at the requested offset", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := NewMockAuthedClient(t, sv.BaseURL())
+		count := 0
+		for event, err := range All(ctx, &EventsRequest{Limit: 7, Offset: 20}, client.Events) {
+			assert.Nil(t, err)
+			assert.NotEmpty(t, event.ID)
+			count++
+		}
+		assert.Equal(t, 5, count)
+	})
+	t.Run("uses the default page size when no limit is set", func(t *testing.T) {
+		req := &EventsRequest{}
+		for range Pages(ctx, req, func(_ context.Context, req *EventsRequest) ([]int, error) {
+			assert.Equal(t, DefaultPageSize, req.Limit)
+			return nil, nil
+		}) {
+		}
+	})
+	t.Run("stops on error", func(t *testing.T) {
+		calls := 0
+		expected := errors.New("boom")
+		var errs []error
+		for _, err := range Pages(ctx, &EventsRequest{Limit: 1}, func(context.Context, *EventsRequest) ([]int, error) {
+			calls++
+			if calls == 2 {
+				return nil, expected
+			}
+			return []int{calls}, nil
+		}) {
+			errs = append(errs, err)
+		}
+		assert.Equal(t, []error{nil, expected}, errs)
+		assert.Equal(t, 2, calls)
+	})
+	t.Run("stops when the consumer breaks", func(t *testing.T) {
+		calls := 0
+		for range All(ctx, &EventsRequest{Limit: 2}, func(context.Context, *EventsRequest) ([]int, error) {
+			calls++
+			return []int{1, 2}, nil
+		}) {
+			break
+		}
+		assert.Equal(t, 1, calls)
+	})
+}
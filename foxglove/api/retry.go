@@ -0,0 +1,185 @@
+package api
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries requests that fail with
+// transient errors. Requests are retried with exponentially increasing,
+// jittered delays unless the server supplies a Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request,
+	// including the first. Values less than 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the delay before the first retry. It
+	// doubles on each subsequent retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. A Retry-After value larger
+	// than MaxDelay is not honored, and the response is returned instead.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by clients constructed with
+// NewRemoteFoxgloveClient, or with a zero ClientOptions.RetryPolicy. To retry
+// differently, pass a policy in ClientOptions.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// markIdempotent flags a request that is safe to replay even though its
+// method is not idempotent, e.g. a POST that only mints a signed link. The
+// header is a nil slice so it is never sent on the wire; this is the same
+// convention net/http uses internally.
+func markIdempotent(req *http.Request) {
+	req.Header["Idempotency-Key"] = nil
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	return ok
+}
+
+// retryable reports whether a request that produced the given response or
+// error may be retried. A 429 means the server rejected the request without
+// processing it, so it is retried regardless of method. Gateway errors and
+// connection failures are only retried for idempotent requests, since the
+// server may have acted on the original. A DELETE is idempotent in effect,
+// but a replay of one that was applied finds nothing to delete; see
+// retryTransport.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isIdempotent(req)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req)
+	}
+	return false
+}
+
+// parseRetryAfter interprets a Retry-After header, which may be either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// backoff returns a "full jitter" delay for the given retry number, starting
+// at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.BaseDelay
+	for i := 1; i < retry && ceiling < p.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+type retryTransport struct {
+	baseTransport http.RoundTripper
+	policy        RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests with a body can only be replayed if the body can be
+	// recreated.
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	attempt := req
+	// applied is set once an attempt may have been acted on by the server
+	// even though it failed.
+	applied := false
+	for i := 1; ; i++ {
+		resp, err := t.baseTransport.RoundTrip(attempt)
+		if applied && req.Method == http.MethodDelete && err == nil && resp.StatusCode == http.StatusNotFound {
+			// An earlier attempt already deleted the resource.
+			resp.Body.Close()
+			return deletedResponse(attempt), nil
+		}
+		if i >= t.policy.MaxAttempts || !rewindable || !retryable(attempt, resp, err) {
+			return resp, err
+		}
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			applied = true
+		}
+		delay := t.policy.backoff(i)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > t.policy.MaxDelay {
+					return resp, err
+				}
+				delay = retryAfter
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		attempt = req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+	}
+}
+
+// deletedResponse stands in for the lost response to a DELETE that was
+// applied, once a replay of it finds nothing left to delete.
+func deletedResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
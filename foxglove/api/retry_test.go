@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		assertion string
+		input     string
+		delay     time.Duration
+		ok        bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"negative seconds", "-1", 0, false},
+		{"http date", now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{"http date in the past", now.Add(-10 * time.Second).Format(http.TimeFormat), 0, true},
+		{"garbage", "soon", 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.assertion, func(t *testing.T) {
+			delay, ok := parseRetryAfter(c.input, now)
+			assert.Equal(t, c.ok, ok)
+			assert.Equal(t, c.delay, delay)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for retry := 1; retry < 10; retry++ {
+		delay := policy.backoff(retry)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.Less(t, delay, policy.MaxDelay)
+	}
+}
+
+// newRetryTestClient is NewMockAuthedClient with the given retry policy.
+func newRetryTestClient(t *testing.T, baseURL string, policy RetryPolicy) *FoxgloveClient {
+	token, err := NewRemoteFoxgloveClient(baseURL, "client", "", "user-agent").SignIn(context.Background(), "client-id")
+	assert.Nil(t, err)
+	return NewClient(ClientOptions{
+		BaseURL:     baseURL,
+		ClientID:    "client",
+		Token:       token,
+		UserAgent:   "user-agent",
+		RetryPolicy: policy,
+	})
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	t.Run("retries idempotent requests on 503", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/devices", Count: 2, Status: http.StatusServiceUnavailable})
+		devices, err := client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, devices)
+		assert.Equal(t, 3, sv.RequestCount("/v1/devices"))
+	})
+	t.Run("treats a replayed delete that finds nothing as done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		key, err := client.CreateAPIKey(ctx, CreateAPIKeyRequest{Label: "ci", Capabilities: []string{"data.upload"}})
+		assert.Nil(t, err)
+		// The first attempt is applied, but its response is lost.
+		sv.InjectFault(Fault{PathPrefix: "/v1/api-keys/", Count: 1, DropResponse: true})
+		assert.Nil(t, client.RevokeAPIKey(ctx, key.ID))
+		assert.Equal(t, 2, sv.RequestCount("/v1/api-keys/"+key.ID))
+		keys, err := client.APIKeys(ctx, APIKeysRequest{})
+		assert.Nil(t, err)
+		assert.Empty(t, keys)
+
+		// A delete of something that never existed still fails.
+		assert.ErrorIs(t, client.RevokeAPIKey(ctx, "missing"), ErrNotFound)
+	})
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/devices", Count: 5, Status: http.StatusBadGateway})
+		_, err = client.Devices(ctx, DevicesRequest{})
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, sv.RequestCount("/v1/devices"))
+	})
+	t.Run("retries dropped connections", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/projects", Count: 1})
+		projects, err := client.Projects(ctx, ProjectsRequest{})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, projects)
+	})
+	t.Run("does not retry non-idempotent requests on 503", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/sessions", Count: 1, Status: http.StatusServiceUnavailable})
+		_, err = client.CreateSession(ctx, CreateSessionRequest{Name: "s"})
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, sv.RequestCount("/v1/sessions"))
+	})
+	t.Run("retries non-idempotent requests on 429 honoring Retry-After", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/sessions", Count: 1, Status: http.StatusTooManyRequests, RetryAfter: "1"})
+		start := time.Now()
+		_, err = client.CreateSession(ctx, CreateSessionRequest{Name: "s"})
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, 2, sv.RequestCount("/v1/sessions"))
+	})
+	t.Run("does not wait longer than the max delay", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/v1/devices", Count: 1, Status: http.StatusTooManyRequests, RetryAfter: "3600"})
+		_, err = client.Devices(ctx, DevicesRequest{})
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, sv.RequestCount("/v1/devices"))
+	})
+	t.Run("retries signed upload links, rewinding the body", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := newRetryTestClient(t, sv.BaseURL(), policy)
+		sv.InjectFault(Fault{PathPrefix: "/storage/", Count: 2, Status: http.StatusServiceUnavailable})
+		sv.InjectFault(Fault{PathPrefix: "/v1/data/upload", Count: 1, Status: http.StatusBadGateway})
+		data := bytes.Repeat([]byte("x"), 1024)
+		err = client.Upload(ctx, bytes.NewReader(data), UploadRequest{
+			Filename: "data.mcap",
+			DeviceID: "test-device",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads["device_id=test-device/data.mcap"])
+		assert.Equal(t, 3, sv.RequestCount("/storage/device_id=test-device/data.mcap"))
+	})
+}
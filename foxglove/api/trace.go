@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceLevel controls how much of each HTTP exchange a client logs.
+type TraceLevel int
+
+const (
+	// TraceOff disables HTTP tracing.
+	TraceOff TraceLevel = iota
+	// TraceRequests logs the method, URL, status, response size and latency
+	// of each request.
+	TraceRequests
+	// TraceBodies additionally logs headers and the leading portion of each
+	// request and response body.
+	TraceBodies
+)
+
+// maxTracedBodySize bounds how much of each body is logged at TraceBodies.
+const maxTracedBodySize = 4096
+
+const redacted = "[REDACTED]"
+
+var (
+	// Query parameters that carry the signature of a signed storage URL, for
+	// GCS, S3 and Azure respectively.
+	signatureParams = regexp.MustCompile(`(?i)((?:x-goog-signature|x-amz-signature|x-amz-security-token|signature|sig)=)[^&"\s]+`)
+	bearerTokens    = regexp.MustCompile(`(Bearer\s+)\S+`)
+	apiKeys         = regexp.MustCompile(`fox_sk_[A-Za-z0-9_-]+`)
+	// JSON fields that carry credentials in auth requests and responses.
+	credentialFields = regexp.MustCompile(`("(?:bearerToken|idToken|token|deviceCode|code|codeVerifier)"\s*:\s*")[^"]*(")`)
+)
+
+// redact removes credentials from text that is about to be logged.
+func redact(s string) string {
+	s = signatureParams.ReplaceAllString(s, "${1}"+redacted)
+	s = bearerTokens.ReplaceAllString(s, "${1}"+redacted)
+	s = apiKeys.ReplaceAllString(s, "fox_sk_"+redacted)
+	s = credentialFields.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	return s
+}
+
+func redactURL(u *url.URL) string {
+	return redact(u.String())
+}
+
+// isTextContent reports whether a body of the given content type is worth
+// dumping. Binary payloads such as recordings are skipped.
+func isTextContent(contentType string) bool {
+	return strings.Contains(contentType, "json") || strings.HasPrefix(contentType, "text/")
+}
+
+// tracer writes HTTP traces for a single request.
+type tracer struct {
+	w     io.Writer
+	level TraceLevel
+	req   *http.Request
+	start time.Time
+}
+
+func (t *tracer) printf(format string, args ...any) {
+	fmt.Fprintf(t.w, "[DEBUG] http: "+format+"\n", args...)
+}
+
+func (t *tracer) dumpHeaders(prefix string, header http.Header) {
+	for key, values := range header {
+		for _, value := range values {
+			t.printf("%s %s: %s", prefix, key, redact(value))
+		}
+	}
+}
+
+func (t *tracer) dumpBody(prefix string, contentType string, body []byte, truncated bool) {
+	if len(body) == 0 {
+		return
+	}
+	if !isTextContent(contentType) {
+		t.printf("%s <%s body omitted>", prefix, coalesce(contentType, "binary"))
+		return
+	}
+	suffix := ""
+	if truncated {
+		suffix = " ..."
+	}
+	t.printf("%s %s%s", prefix, redact(string(body)), suffix)
+}
+
+// traceRequest logs an outgoing request. Text bodies are read from GetBody,
+// which for the JSON bodies the client builds from byte slices returns an
+// independent snapshot, so the request itself is left untouched. Other bodies
+// are not read: an upload's GetBody may share the reader the request is about
+// to send.
+func (t *tracer) traceRequest() {
+	if t.level < TraceBodies {
+		return
+	}
+	t.printf("> %s %s", t.req.Method, redactURL(t.req.URL))
+	t.dumpHeaders(">", t.req.Header)
+	if t.req.Body == nil || t.req.Body == http.NoBody {
+		return
+	}
+	contentType := t.req.Header.Get("Content-Type")
+	if !isTextContent(contentType) {
+		t.printf("> <%s body omitted>", coalesce(contentType, "binary"))
+		return
+	}
+	if t.req.GetBody == nil {
+		return
+	}
+	body, err := t.req.GetBody()
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(io.LimitReader(body, maxTracedBodySize+1))
+	truncated := len(data) > maxTracedBodySize
+	if truncated {
+		data = data[:maxTracedBodySize]
+	}
+	t.dumpBody(">", contentType, data, truncated)
+}
+
+func (t *tracer) traceError(err error) {
+	t.printf("%s %s: %s (%s)", t.req.Method, redactURL(t.req.URL), redact(err.Error()), time.Since(t.start).Round(time.Millisecond))
+}
+
+// traceResponse arranges for the response to be logged once its body has
+// been consumed, so that the reported size and latency cover the transfer.
+func (t *tracer) traceResponse(resp *http.Response) {
+	if t.level >= TraceBodies {
+		t.printf("< %s %s", resp.Proto, resp.Status)
+		t.dumpHeaders("<", resp.Header)
+	}
+	resp.Body = &tracedBody{
+		ReadCloser: resp.Body,
+		tracer:     t,
+		resp:       resp,
+		firstByte:  time.Since(t.start),
+	}
+}
+
+// tracedBody counts the bytes read from a response body and logs the
+// exchange when it is closed.
+type tracedBody struct {
+	io.ReadCloser
+	tracer    *tracer
+	resp      *http.Response
+	firstByte time.Duration
+	size      int64
+	head      bytes.Buffer
+	truncated bool
+	once      sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if b.tracer.level >= TraceBodies && n > 0 {
+		room := maxTracedBodySize - b.head.Len()
+		if room >= n {
+			b.head.Write(p[:n])
+		} else {
+			b.head.Write(p[:max(room, 0)])
+			b.truncated = true
+		}
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		t := b.tracer
+		if t.level >= TraceBodies {
+			t.dumpBody("<", b.resp.Header.Get("Content-Type"), b.head.Bytes(), b.truncated)
+		}
+		t.printf(
+			"%s %s %d %d bytes in %s (headers %s)",
+			t.req.Method,
+			redactURL(t.req.URL),
+			b.resp.StatusCode,
+			b.size,
+			time.Since(t.start).Round(time.Millisecond),
+			b.firstByte.Round(time.Millisecond),
+		)
+	})
+	return err
+}
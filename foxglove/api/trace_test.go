@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		assertion string
+		input     string
+		output    string
+	}{
+		{
+			"bearer tokens",
+			"Bearer abc.def.ghi",
+			"Bearer [REDACTED]",
+		},
+		{
+			"api keys",
+			"key is fox_sk_1234abcdEFGH",
+			"key is fox_sk_[REDACTED]",
+		},
+		{
+			"gcs signatures",
+			"https://storage.googleapis.com/b/o?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Signature=deadbeef&X-Goog-Expires=900",
+			"https://storage.googleapis.com/b/o?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Signature=[REDACTED]&X-Goog-Expires=900",
+		},
+		{
+			"s3 signatures",
+			"https://bucket.s3.amazonaws.com/o?X-Amz-Signature=cafe&X-Amz-Security-Token=tok",
+			"https://bucket.s3.amazonaws.com/o?X-Amz-Signature=[REDACTED]&X-Amz-Security-Token=[REDACTED]",
+		},
+		{
+			"signed links in json",
+			`{"link":"https://example.com/o?sig=abc"}`,
+			`{"link":"https://example.com/o?sig=[REDACTED]"}`,
+		},
+		{
+			"credential fields in json",
+			`{"bearerToken": "abc", "idToken":"def", "name": "ok"}`,
+			`{"bearerToken": "[REDACTED]", "idToken":"[REDACTED]", "name": "ok"}`,
+		},
+		{
+			"authorization codes in json",
+			`{"clientId":"abc","code":"def","codeVerifier":"ghi","redirectUri":"http://127.0.0.1:1234/callback"}`,
+			`{"clientId":"abc","code":"[REDACTED]","codeVerifier":"[REDACTED]","redirectUri":"http://127.0.0.1:1234/callback"}`,
+		},
+		{
+			"ordinary text",
+			"GET https://api.foxglove.dev/v1/devices?limit=10",
+			"GET https://api.foxglove.dev/v1/devices?limit=10",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.assertion, func(t *testing.T) {
+			assert.Equal(t, c.output, redact(c.input))
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv, err := NewMockServer(ctx)
+	assert.Nil(t, err)
+
+	// withTrace returns a signed-in client tracing at level, and the buffer
+	// its traces, including the sign in, are written to.
+	withTrace := func(t *testing.T, level TraceLevel) (*FoxgloveClient, *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		opts := ClientOptions{
+			BaseURL:     sv.BaseURL(),
+			ClientID:    "client",
+			UserAgent:   "user-agent",
+			TraceLevel:  level,
+			TraceOutput: buf,
+		}
+		token, err := NewClient(opts).SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		opts.Token = token
+		return NewClient(opts), buf
+	}
+
+	t.Run("is silent by default", func(t *testing.T) {
+		client, buf := withTrace(t, TraceOff)
+		_, err := client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		assert.Empty(t, buf.String())
+	})
+	t.Run("logs method, url, status and size", func(t *testing.T) {
+		client, buf := withTrace(t, TraceRequests)
+		_, err := client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		last := lines[len(lines)-1]
+		assert.Regexp(t, `^\[DEBUG\] http: GET .*/v1/devices\?\S* 200 \d+ bytes in \S+ \(headers \S+\)$`, last)
+		assert.NotContains(t, buf.String(), "Authorization")
+	})
+	t.Run("logs signed storage requests", func(t *testing.T) {
+		client, buf := withTrace(t, TraceRequests)
+		err := client.Upload(ctx, bytes.NewReader([]byte("data")), UploadRequest{
+			Filename: "trace.mcap",
+			DeviceID: "test-device",
+		})
+		assert.Nil(t, err)
+		assert.Contains(t, buf.String(), "PUT "+sv.BaseURL()+"/storage/device_id=test-device/trace.mcap 200")
+	})
+	t.Run("dumps redacted headers and bodies", func(t *testing.T) {
+		client, buf := withTrace(t, TraceBodies)
+		_, err := client.Devices(ctx, DevicesRequest{})
+		assert.Nil(t, err)
+		out := buf.String()
+		assert.Contains(t, out, "> Authorization: Bearer [REDACTED]")
+		assert.Contains(t, out, `"bearerToken":"[REDACTED]"`)
+		assert.Contains(t, out, `< [{"id":"test-device"`)
+		for token := range sv.BearerTokens {
+			assert.NotContains(t, out, token)
+		}
+	})
+	t.Run("leaves upload bodies unread", func(t *testing.T) {
+		chunkSize := UploadChunkSize
+		UploadChunkSize = 6000
+		t.Cleanup(func() {
+			UploadChunkSize = chunkSize
+		})
+		data := make([]byte, 10000)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		t.Cleanup(func() {
+			sv.ResumableUploads = false
+		})
+		token, err := NewRemoteFoxgloveClient(sv.BaseURL(), "client", "", "user-agent").SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		for _, resumable := range []bool{false, true} {
+			buf := &bytes.Buffer{}
+			sv.ResumableUploads = resumable
+			client := NewClient(ClientOptions{
+				BaseURL:     sv.BaseURL(),
+				ClientID:    "client",
+				Token:       token,
+				UserAgent:   "user-agent",
+				RetryPolicy: RetryPolicy{MaxAttempts: 1},
+				TraceLevel:  TraceBodies,
+				TraceOutput: buf,
+			})
+			// Hide ReadAt, so that retries would rewind the reader being sent.
+			reader := struct{ io.ReadSeeker }{bytes.NewReader(data)}
+			err = client.Upload(ctx, reader, UploadRequest{Filename: "trace.mcap", DeviceID: "test-device"})
+			assert.Nil(t, err)
+			assert.Equal(t, data, sv.Uploads["device_id=test-device/trace.mcap"])
+			assert.Contains(t, buf.String(), "> <application/octet-stream body omitted>")
+		}
+	})
+}
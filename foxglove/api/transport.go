@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportConfig configures the HTTP transport shared by API requests and
+// signed storage transfers. The zero value matches http.DefaultTransport.
+type TransportConfig struct {
+	// ProxyURL is the proxy all requests are sent through. If empty, the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are honored.
+	ProxyURL string
+	// CAFiles are PEM files holding certificate authorities to trust in
+	// addition to the system roots, e.g. that of a TLS-inspecting proxy.
+	CAFiles []string
+	// ClientCert and ClientKey are PEM files holding a certificate and key to
+	// present for mutual TLS. Both or neither must be set.
+	ClientCert string
+	ClientKey  string
+	// ConnectTimeout bounds establishing a connection, including the TLS
+	// handshake. Zero uses the default of 30 seconds.
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds how long a read from the connection may stall,
+	// whether awaiting response headers or part of a body. Zero means no
+	// limit.
+	ReadTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes. Zero uses the
+	// default of 30 seconds. A negative value disables keep-alives and
+	// connection reuse.
+	KeepAlive time.Duration
+}
+
+// NewTransport builds an HTTP transport from the supplied configuration.
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if len(config.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range config.CAFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	switch {
+	case config.ClientCert != "" && config.ClientKey != "":
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case config.ClientCert != "" || config.ClientKey != "":
+		return nil, fmt.Errorf("client certificate and key must be supplied together")
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if config.ConnectTimeout > 0 {
+		dialer.Timeout = config.ConnectTimeout
+		transport.TLSHandshakeTimeout = config.ConnectTimeout
+	}
+	if config.KeepAlive != 0 {
+		dialer.KeepAlive = config.KeepAlive
+	}
+	if config.KeepAlive < 0 {
+		transport.DisableKeepAlives = true
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || config.ReadTimeout <= 0 {
+			return conn, err
+		}
+		return &readTimeoutConn{Conn: conn, timeout: config.ReadTimeout}, nil
+	}
+	return transport, nil
+}
+
+// readTimeoutConn extends its read deadline before each read, so that a
+// stalled transfer fails without limiting the duration of a healthy one.
+type readTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *readTimeoutConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
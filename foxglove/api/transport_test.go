@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate generates a self-signed certificate, writing the
+// certificate and key to PEM files in dir.
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foxglove-cli test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// writeServerCA writes the certificate of a TLS test server to a PEM file.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	assert.Nil(t, os.WriteFile(caFile, pem.EncodeToMemory(block), 0600))
+	return caFile
+}
+
+// forwardingProxy is an HTTP proxy that records the hosts it forwards to.
+type forwardingProxy struct {
+	mtx   sync.Mutex
+	hosts []string
+}
+
+func (p *forwardingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mtx.Lock()
+	p.hosts = append(p.hosts, r.URL.Host)
+	p.mtx.Unlock()
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func TestNewTransport(t *testing.T) {
+	ctx := context.Background()
+	t.Run("routes API and storage requests through the proxy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		proxy := &forwardingProxy{}
+		proxyServer := httptest.NewServer(proxy)
+		defer proxyServer.Close()
+
+		transport, err := NewTransport(TransportConfig{ProxyURL: proxyServer.URL})
+		assert.Nil(t, err)
+		opts := ClientOptions{
+			BaseURL:   sv.BaseURL(),
+			ClientID:  "client",
+			UserAgent: "user-agent",
+			Transport: transport,
+		}
+		opts.Token, err = NewClient(opts).SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		client := NewClient(opts)
+		err = client.Upload(ctx, bytes.NewReader([]byte("hello")), UploadRequest{
+			Filename: "proxied.mcap",
+			DeviceID: "test-device",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("hello"), sv.Uploads["device_id=test-device/proxied.mcap"])
+		// sign in, upload link, signed upload
+		assert.Len(t, proxy.hosts, 3)
+	})
+	t.Run("trusts additional certificate authorities", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		_, err := (&http.Client{Transport: http.DefaultTransport}).Get(server.URL)
+		assert.NotNil(t, err)
+
+		transport, err := NewTransport(TransportConfig{CAFiles: []string{writeServerCA(t, server)}})
+		assert.Nil(t, err)
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		assert.Nil(t, err)
+		resp.Body.Close()
+	})
+	t.Run("presents a client certificate", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Len(t, r.TLS.PeerCertificates, 1)
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		server.StartTLS()
+		defer server.Close()
+		caFile := writeServerCA(t, server)
+
+		transport, err := NewTransport(TransportConfig{CAFiles: []string{caFile}})
+		assert.Nil(t, err)
+		_, err = (&http.Client{Transport: transport}).Get(server.URL)
+		assert.NotNil(t, err)
+
+		certFile, keyFile := writeCertificate(t, t.TempDir())
+		transport, err = NewTransport(TransportConfig{
+			CAFiles:    []string{caFile},
+			ClientCert: certFile,
+			ClientKey:  keyFile,
+		})
+		assert.Nil(t, err)
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		assert.Nil(t, err)
+		resp.Body.Close()
+	})
+	t.Run("fails stalled reads", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		transport, err := NewTransport(TransportConfig{ReadTimeout: 50 * time.Millisecond})
+		assert.Nil(t, err)
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+	t.Run("rejects invalid configuration", func(t *testing.T) {
+		certFile, _ := writeCertificate(t, t.TempDir())
+		missing := filepath.Join(t.TempDir(), "missing.pem")
+		notPEM := filepath.Join(t.TempDir(), "not.pem")
+		assert.Nil(t, os.WriteFile(notPEM, []byte("hello"), 0600))
+		for _, config := range []TransportConfig{
+			{ProxyURL: "http://[::1"},
+			{CAFiles: []string{missing}},
+			{CAFiles: []string{notPEM}},
+			{ClientCert: certFile},
+			{ClientCert: certFile, ClientKey: missing},
+		} {
+			_, err := NewTransport(config)
+			assert.NotNil(t, err, "%+v", config)
+		}
+	})
+}
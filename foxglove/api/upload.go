@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UploadChunkSize is the size of each request in a resumable upload. Cloud
+// storage requires it to be a multiple of 256 KiB.
+var UploadChunkSize int64 = 16 << 20
+
+// maxStalledChunks is the number of consecutive chunks that may fail, or be
+// accepted without the committed offset advancing, before a resumable upload
+// gives up.
+const maxStalledChunks = 3
+
+// statusResumeIncomplete is returned by cloud storage for a chunk that was
+// accepted when the upload is not yet complete.
+const statusResumeIncomplete = 308
+
+// UploadSession describes a resumable upload in progress.
+type UploadSession struct {
+	// URI identifies the session. Chunks are PUT to it.
+	URI string `json:"uri"`
+	// Size is the total size of the object being uploaded.
+	Size int64 `json:"size"`
+	// Offset is the number of bytes the server has committed.
+	Offset int64 `json:"offset"`
+}
+
+// UploadState persists the progress of a resumable upload, so that an
+// interrupted upload may be continued by a later process.
+type UploadState interface {
+	// Load returns the saved session, or nil if there is none.
+	Load() (*UploadSession, error)
+	Save(session *UploadSession) error
+	Clear() error
+}
+
+// ResumeUpload uploads the remainder of reader, from its current position,
+// in chunks through a resumable upload session. If state holds a session for
+// an upload of the same size it is continued from the offset the server has
+// committed; otherwise a new session is started. State may be nil, in which
+// case progress is not persisted. If the server does not offer resumable
+// sessions the object is uploaded in a single request.
+func (c *FoxgloveClient) ResumeUpload(
+	ctx context.Context,
+	reader io.ReadSeeker,
+	r UploadRequest,
+	state UploadState,
+) error {
+	base, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to determine upload offset: %w", err)
+	}
+	end, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to determine upload size: %w", err)
+	}
+	if _, err := reader.Seek(base, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	size := end - base
+
+	var session *UploadSession
+	if state != nil {
+		session, err = state.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load upload state: %w", err)
+		}
+		if session != nil && session.Size != size {
+			session = nil
+		}
+	}
+	if session != nil {
+		complete, offset, err := c.queryUploadSession(ctx, session)
+		switch {
+		case err != nil:
+			// The session has likely expired. Start over.
+			session = nil
+		case complete:
+			return state.Clear()
+		default:
+			session.Offset = offset
+		}
+	}
+	if session == nil {
+		r.Resumable = size > 0
+		link, err := c.uploadLink(ctx, r)
+		if err != nil {
+			return err
+		}
+		if !link.Resumable {
+			return c.putObject(ctx, link.Link, reader)
+		}
+		uri, err := c.startUploadSession(ctx, link.Link)
+		if err != nil {
+			return err
+		}
+		session = &UploadSession{URI: uri, Size: size}
+	}
+
+	stalled := 0
+	for {
+		if state != nil {
+			if err := state.Save(session); err != nil {
+				return fmt.Errorf("failed to save upload state: %w", err)
+			}
+		}
+		complete, offset, err := c.putChunk(ctx, session, reader, base)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// Find out how much of the failed chunk, if any, was committed.
+			var queryErr error
+			complete, offset, queryErr = c.queryUploadSession(ctx, session)
+			if queryErr != nil {
+				return fmt.Errorf("%w (and querying the upload session failed: %v)", err, queryErr)
+			}
+		}
+		if complete {
+			if state != nil {
+				return state.Clear()
+			}
+			return nil
+		}
+		// A chunk that was accepted without the committed offset advancing
+		// counts as stalled, just like one that failed.
+		if offset > session.Offset {
+			stalled = 0
+		} else {
+			stalled++
+			if stalled >= maxStalledChunks {
+				if err == nil {
+					err = fmt.Errorf("upload stalled at %d of %d bytes", offset, session.Size)
+				}
+				return err
+			}
+		}
+		session.Offset = offset
+	}
+}
+
+// startUploadSession starts a resumable upload session at a signed link,
+// returning the session URI.
+func (c *FoxgloveClient) startUploadSession(ctx context.Context, link string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, link, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build upload session request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-goog-resumable", "start")
+	// An abandoned session is harmless, so the request may be replayed.
+	markIdempotent(req)
+	resp, err := c.storage.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to start upload session: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp)
+	}
+	uri := resp.Header.Get("Location")
+	if uri == "" {
+		return "", errors.New("upload session response has no location")
+	}
+	return uri, nil
+}
+
+// putChunk uploads the next chunk of a session, which starts base bytes into
+// reader. It returns whether the upload is complete and, if not, the committed
+// offset.
+func (c *FoxgloveClient) putChunk(
+	ctx context.Context,
+	session *UploadSession,
+	reader io.ReadSeeker,
+	base int64,
+) (bool, int64, error) {
+	length := min(UploadChunkSize, session.Size-session.Offset)
+	getBody := uploadBody(reader, base+session.Offset, length)
+	body, err := getBody()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to seek to upload offset: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.URI, body)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to build upload request: %w", err)
+	}
+	req.ContentLength = length
+	req.GetBody = getBody
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", session.Offset, session.Offset+length-1, session.Size))
+	return c.uploadSessionRequest(req)
+}
+
+// uploadBody returns a function yielding the length bytes of reader from
+// offset, for use as a request body and its GetBody. If reader is an
+// io.ReaderAt, such as an *os.File, each call returns an independent reader,
+// so that a retried attempt can't interleave with one the transport is still
+// reading. Other readers are rewound.
+func uploadBody(reader io.ReadSeeker, offset, length int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		if length == 0 {
+			return http.NoBody, nil
+		}
+		if readerAt, ok := reader.(io.ReaderAt); ok {
+			return io.NopCloser(io.NewSectionReader(readerAt, offset, length)), nil
+		}
+		if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(io.LimitReader(reader, length)), nil
+	}
+}
+
+// queryUploadSession asks the server how much of a session it has committed.
+func (c *FoxgloveClient) queryUploadSession(ctx context.Context, session *UploadSession) (bool, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.URI, http.NoBody)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to build upload status request: %w", err)
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", session.Size))
+	return c.uploadSessionRequest(req)
+}
+
+func (c *FoxgloveClient) uploadSessionRequest(req *http.Request) (bool, int64, error) {
+	resp, err := c.storage.Do(req)
+	if err != nil {
+		return false, 0, fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return true, 0, nil
+	case statusResumeIncomplete:
+		offset, err := parseCommittedRange(resp.Header.Get("Range"))
+		if err != nil {
+			return false, 0, err
+		}
+		return false, offset, nil
+	default:
+		return false, 0, newAPIError(resp)
+	}
+}
+
+// parseCommittedRange parses the Range header of a 308 response, which
+// reports the bytes committed so far as "bytes=0-N". An absent header means
+// nothing has been committed.
+func parseCommittedRange(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	_, last, ok := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid range header %q", value)
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid range header %q", value)
+	}
+	return n + 1, nil
+}
+
+// FileUploadState persists upload sessions as JSON files in a directory.
+type FileUploadState struct {
+	path string
+}
+
+// NewFileUploadState returns state for an upload of the named file to the
+// destination described by r. State is stored under dir, keyed on the file's
+// path, size and modification time so that a changed file starts afresh.
+func NewFileUploadState(dir string, filename string, r UploadRequest) (*FileUploadState, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%d\x00%d\x00%s", abs, info.Size(), info.ModTime().UnixNano(), destination)
+	return &FileUploadState{
+		path: filepath.Join(dir, hex.EncodeToString(hash.Sum(nil))+".json"),
+	}, nil
+}
+
+// DefaultUploadStateDir returns the directory in which upload state is kept
+// by default.
+func DefaultUploadStateDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "foxglove-cli", "uploads"), nil
+}
+
+func (s *FileUploadState) Load() (*UploadSession, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &UploadSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		// Treat a corrupt state file as absent.
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (s *FileUploadState) Save(session *UploadSession) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	// Write through a temporary file so an interruption can't leave a
+	// truncated state file behind.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *FileUploadState) Clear() error {
+	err := os.Remove(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
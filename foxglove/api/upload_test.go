@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// interruptingReader cancels a context once a given number of bytes have
+// been read through it.
+type interruptingReader struct {
+	io.ReadSeeker
+	remaining int
+	cancel    context.CancelFunc
+}
+
+func (r *interruptingReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		r.cancel()
+		return 0, context.Canceled
+	}
+	n, err := r.ReadSeeker.Read(p[:min(len(p), r.remaining)])
+	r.remaining -= n
+	return n, err
+}
+
+func TestParseCommittedRange(t *testing.T) {
+	offset, err := parseCommittedRange("")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), offset)
+	offset, err = parseCommittedRange("bytes=0-1023")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), offset)
+	_, err = parseCommittedRange("bytes=zero")
+	assert.NotNil(t, err)
+}
+
+func TestUploadBody(t *testing.T) {
+	getBody := uploadBody(bytes.NewReader([]byte("0123456789")), 2, 5)
+	first, err := getBody()
+	assert.Nil(t, err)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(first, buf)
+	assert.Nil(t, err)
+	// A retry reads from the start of the range, without disturbing an
+	// attempt still in progress.
+	second, err := getBody()
+	assert.Nil(t, err)
+	data, err := io.ReadAll(second)
+	assert.Nil(t, err)
+	assert.Equal(t, "23456", string(data))
+	rest, err := io.ReadAll(first)
+	assert.Nil(t, err)
+	assert.Equal(t, "23456", string(buf)+string(rest))
+
+	empty, err := uploadBody(bytes.NewReader(nil), 0, 0)()
+	assert.Nil(t, err)
+	assert.Equal(t, http.NoBody, empty)
+}
+
+func TestResumableUpload(t *testing.T) {
+	ctx := context.Background()
+	chunkSize := UploadChunkSize
+	UploadChunkSize = 1000
+	t.Cleanup(func() {
+		UploadChunkSize = chunkSize
+	})
+	data := make([]byte, 4500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	key := "device_id=test-device/data.mcap"
+	req := UploadRequest{Filename: "data.mcap", DeviceID: "test-device"}
+
+	setup := func(t *testing.T, resumable bool) (context.Context, *MockFoxgloveServer, *FoxgloveClient) {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		sv, err := NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.ResumableUploads = resumable
+		return ctx, sv, newRetryTestClient(t, sv.BaseURL(), RetryPolicy{MaxAttempts: 1})
+	}
+
+	t.Run("falls back to a single request without resumable sessions", func(t *testing.T) {
+		ctx, sv, client := setup(t, false)
+		err := client.Upload(ctx, bytes.NewReader(data), req)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads[key])
+		assert.Equal(t, 1, sv.RequestCount("/storage/"+key))
+	})
+	t.Run("uploads in chunks", func(t *testing.T) {
+		ctx, sv, client := setup(t, true)
+		err := client.Upload(ctx, bytes.NewReader(data), req)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads[key])
+		assert.Equal(t, int64(len(data)), sv.CommittedUploadBytes())
+	})
+	t.Run("resumes from the committed offset after a dropped chunk", func(t *testing.T) {
+		ctx, sv, client := setup(t, true)
+		sv.InjectFault(Fault{PathPrefix: "/upload-sessions/", Count: 1})
+		err := client.Upload(ctx, bytes.NewReader(data), req)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads[key])
+		assert.Equal(t, int64(len(data)), sv.CommittedUploadBytes())
+	})
+	t.Run("continues an interrupted upload from saved state", func(t *testing.T) {
+		ctx, sv, client := setup(t, true)
+		filename := filepath.Join(t.TempDir(), "data.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		state, err := NewFileUploadState(t.TempDir(), filename, req)
+		assert.Nil(t, err)
+
+		interruptedCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		reader := &interruptingReader{ReadSeeker: bytes.NewReader(data), remaining: 2500, cancel: cancel}
+		err = client.ResumeUpload(interruptedCtx, reader, req, state)
+		assert.ErrorIs(t, err, context.Canceled)
+		session, err := state.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2000), session.Offset)
+		assert.Equal(t, int64(len(data)), session.Size)
+
+		// Give the server a moment to observe the aborted request.
+		time.Sleep(10 * time.Millisecond)
+		err = client.ResumeUpload(ctx, bytes.NewReader(data), req, state)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads[key])
+		assert.Equal(t, int64(len(data)), sv.CommittedUploadBytes())
+		session, err = state.Load()
+		assert.Nil(t, err)
+		assert.Nil(t, session)
+	})
+	t.Run("starts over if the saved session has expired", func(t *testing.T) {
+		ctx, sv, client := setup(t, true)
+		filename := filepath.Join(t.TempDir(), "data.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		state, err := NewFileUploadState(t.TempDir(), filename, req)
+		assert.Nil(t, err)
+		assert.Nil(t, state.Save(&UploadSession{
+			URI:    sv.BaseURL() + "/upload-sessions/expired",
+			Size:   int64(len(data)),
+			Offset: 3000,
+		}))
+		err = client.ResumeUpload(ctx, bytes.NewReader(data), req, state)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads[key])
+	})
+	t.Run("gives up if the committed offset stops advancing", func(t *testing.T) {
+		var puts atomic.Int32
+		storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			if r.Header.Get("Content-Range") != fmt.Sprintf("bytes */%d", len(data)) {
+				puts.Add(1)
+			}
+			w.Header().Set("Range", "bytes=0-999")
+			w.WriteHeader(statusResumeIncomplete)
+		}))
+		defer storage.Close()
+		_, _, client := setup(t, true)
+		filename := filepath.Join(t.TempDir(), "data.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		state, err := NewFileUploadState(t.TempDir(), filename, req)
+		assert.Nil(t, err)
+		assert.Nil(t, state.Save(&UploadSession{URI: storage.URL, Size: int64(len(data))}))
+
+		err = client.ResumeUpload(ctx, bytes.NewReader(data), req, state)
+		assert.ErrorContains(t, err, "upload stalled at 1000 of 4500 bytes")
+		assert.Equal(t, int32(maxStalledChunks), puts.Load())
+	})
+	t.Run("reports the failed chunk when the session cannot be queried", func(t *testing.T) {
+		var queries atomic.Int32
+		storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			if r.Header.Get("Content-Range") != fmt.Sprintf("bytes */%d", len(data)) {
+				http.Error(w, "chunk rejected", http.StatusBadRequest)
+				return
+			}
+			// Answer the query made on loading the saved session, then fail.
+			if queries.Add(1) == 1 {
+				w.WriteHeader(statusResumeIncomplete)
+				return
+			}
+			http.Error(w, "session unavailable", http.StatusForbidden)
+		}))
+		defer storage.Close()
+		_, _, client := setup(t, true)
+		filename := filepath.Join(t.TempDir(), "data.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		state, err := NewFileUploadState(t.TempDir(), filename, req)
+		assert.Nil(t, err)
+		assert.Nil(t, state.Save(&UploadSession{URI: storage.URL, Size: int64(len(data))}))
+
+		err = client.ResumeUpload(ctx, bytes.NewReader(data), req, state)
+		assert.ErrorContains(t, err, "chunk rejected")
+		assert.ErrorContains(t, err, "querying the upload session failed")
+	})
+	t.Run("keys state on the file contents", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(t.TempDir(), "data.mcap")
+		assert.Nil(t, os.WriteFile(filename, data, 0600))
+		before, err := NewFileUploadState(dir, filename, req)
+		assert.Nil(t, err)
+		assert.Nil(t, os.WriteFile(filename, data[:10], 0600))
+		after, err := NewFileUploadState(dir, filename, req)
+		assert.Nil(t, err)
+		assert.NotEqual(t, before.path, after.path)
+	})
+}
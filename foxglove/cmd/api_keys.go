@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/cobra"
+)
+
+// executeCreateAPIKey mints an API key and renders it, including the secret,
+// to w.
+func executeCreateAPIKey(ctx context.Context, client *api.FoxgloveClient, w io.Writer, label string, capabilities []string, format string) error {
+	key, err := client.CreateAPIKey(ctx, api.CreateAPIKeyRequest{
+		Label:        label,
+		Capabilities: capabilities,
+	})
+	if err != nil {
+		return err
+	}
+	return renderRecords(w, []api.CreateAPIKeyResponse{key}, format)
+}
+
+func listAPIKeysAutocompletionFunc(
+	params *baseParams,
+) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		client := newClient(params.baseURL, *params.clientID, params.token, params.userAgent)
+		keys, err := client.APIKeys(cmd.Context(), api.APIKeysRequest{})
+		if err != nil {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+		var candidates []string
+		for _, key := range keys {
+			candidates = append(candidates, fmt.Sprintf("%s\t%s", key.ID, key.Label))
+		}
+		return candidates, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func newAPIKeysCommand(params *baseParams) *cobra.Command {
+	apiKeysCmd := &cobra.Command{
+		Use:   "api-keys",
+		Short: "Create, list and revoke API keys",
+		Long: `Manage the API keys of your organization, e.g. to give a CI job access with
+only the capabilities it needs. To authenticate with a key, set
+FOXGLOVE_API_KEY or run 'auth configure-api-key'.`,
+	}
+	apiKeysCmd.AddCommand(
+		newListAPIKeysCommand(params),
+		newCreateAPIKeyCommand(params),
+		newRevokeAPIKeyCommand(params),
+	)
+	return apiKeysCmd
+}
+
+func newListAPIKeysCommand(params *baseParams) *cobra.Command {
+	var format string
+	var isJsonFormat bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys, with when each was last used",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			client := newClient(
+				params.baseURL, *params.clientID,
+				params.token,
+				params.userAgent,
+			)
+			format = ResolveFormat(format, isJsonFormat)
+			err := renderList(
+				cmd.Context(),
+				os.Stdout,
+				api.APIKeysRequest{},
+				client.APIKeys,
+				format,
+			)
+			if err != nil {
+				dief("Failed to list API keys: %s", err)
+			}
+		},
+	}
+	listCmd.InheritedFlags()
+	AddFormatFlag(listCmd, &format)
+	AddJsonFlag(listCmd, &isJsonFormat)
+	return listCmd
+}
+
+func newCreateAPIKeyCommand(params *baseParams) *cobra.Command {
+	var format string
+	var isJsonFormat bool
+	var label string
+	var capabilities []string
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key with the given capabilities",
+		Long: `Create an API key with the given capabilities (scopes), such as data.upload
+or data.stream. The key is printed once and cannot be retrieved again.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if label == "" {
+				exitf(exitUsage, "A --label is required")
+			}
+			if len(capabilities) == 0 {
+				exitf(exitUsage, "At least one --capability is required")
+			}
+			client := newClient(
+				params.baseURL, *params.clientID,
+				params.token,
+				params.userAgent,
+			)
+			format = ResolveFormat(format, isJsonFormat)
+			err := executeCreateAPIKey(cmd.Context(), client, os.Stdout, label, capabilities, format)
+			if err != nil {
+				dief("Failed to create API key: %s", err)
+			}
+			fmt.Fprintln(os.Stderr, "Store the key now: it cannot be shown again.")
+		},
+	}
+	createCmd.InheritedFlags()
+	createCmd.PersistentFlags().StringVarP(&label, "label", "", "", "name of the key, e.g. the CI job that uses it")
+	createCmd.PersistentFlags().StringArrayVarP(&capabilities, "capability", "c", []string{}, "capability to grant, e.g. data.upload. Multiple may be specified.")
+	AddFormatFlag(createCmd, &format)
+	AddJsonFlag(createCmd, &isJsonFormat)
+	return createCmd
+}
+
+func newRevokeAPIKeyCommand(params *baseParams) *cobra.Command {
+	revokeCmd := &cobra.Command{
+		Use:               "revoke ID",
+		Short:             "Revoke an API key, so that it can no longer be used",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: listAPIKeysAutocompletionFunc(params),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := newClient(
+				params.baseURL, *params.clientID,
+				params.token,
+				params.userAgent,
+			)
+			err := client.RevokeAPIKey(cmd.Context(), args[0])
+			if err != nil {
+				dief("Failed to revoke API key: %s", err)
+			}
+			fmt.Fprintf(os.Stderr, "API key revoked: %s\n", args[0])
+		},
+	}
+	revokeCmd.InheritedFlags()
+	return revokeCmd
+}
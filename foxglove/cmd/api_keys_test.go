@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyCommands(t *testing.T) {
+	ctx := context.Background()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := api.NewMockAuthedClient(t, sv.BaseURL())
+
+	buf := &bytes.Buffer{}
+	err = executeCreateAPIKey(ctx, client, buf, "ci", []string{"data.upload", "data.stream"}, "json")
+	assert.Nil(t, err)
+	created := []api.CreateAPIKeyResponse{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &created))
+	assert.Len(t, created, 1)
+	key := created[0]
+	assert.Equal(t, "ci", key.Label)
+	assert.Equal(t, []string{"data.upload", "data.stream"}, key.Capabilities)
+	assert.Regexp(t, "^fox_sk_", key.Key)
+
+	t.Run("lists keys with their last use", func(t *testing.T) {
+		keys, err := client.APIKeys(ctx, api.APIKeysRequest{})
+		assert.Nil(t, err)
+		assert.Len(t, keys, 1)
+		assert.Nil(t, keys[0].LastUsedAt)
+		assert.Equal(t, "never", keys[0].Fields()[4])
+
+		keyClient := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client", key.Key, "user-agent")
+		_, err = keyClient.Devices(ctx, api.DevicesRequest{})
+		assert.Nil(t, err)
+		keys, err = client.APIKeys(ctx, api.APIKeysRequest{})
+		assert.Nil(t, err)
+		assert.NotNil(t, keys[0].LastUsedAt)
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, renderList(ctx, buf, api.APIKeysRequest{}, client.APIKeys, "csv"))
+		assert.Contains(t, buf.String(), "ID,Label,Capabilities,Created At,Last Used At\n")
+		assert.NotContains(t, buf.String(), key.Key)
+	})
+	t.Run("revoked keys are rejected", func(t *testing.T) {
+		assert.Nil(t, client.RevokeAPIKey(ctx, key.ID))
+		keyClient := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client", key.Key, "user-agent")
+		_, err := keyClient.Devices(ctx, api.DevicesRequest{})
+		assert.ErrorIs(t, err, api.ErrUnauthorized)
+		keys, err := client.APIKeys(ctx, api.APIKeysRequest{})
+		assert.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+	t.Run("revoking an unknown key reports it missing", func(t *testing.T) {
+		err := client.RevokeAPIKey(ctx, "ak_unknown")
+		assert.ErrorIs(t, err, api.ErrNotFound)
+	})
+	t.Run("rejects keys without capabilities", func(t *testing.T) {
+		err := executeCreateAPIKey(ctx, client, &bytes.Buffer{}, "ci", nil, "json")
+		assert.ErrorContains(t, err, "capabilities are required")
+	})
+}
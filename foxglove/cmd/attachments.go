@@ -1,13 +1,11 @@
 package cmd
 
 import (
-	"fmt"
 	"io"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newListAttachmentsCommand(params *baseParams) *cobra.Command {
@@ -26,12 +24,13 @@ func newListAttachmentsCommand(params *baseParams) *cobra.Command {
 				dief("%s", err)
 			}
 			format = ResolveFormat(format, isJsonFormat)
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
-				viper.GetString("bearer_token"),
+				params.token,
 				params.userAgent,
 			)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				&api.AttachmentsRequest{
 					ImportID:    importID,
@@ -44,8 +43,7 @@ func newListAttachmentsCommand(params *baseParams) *cobra.Command {
 				format,
 			)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list imports: %s\n", err)
-				os.Exit(1)
+				dief("Failed to list imports: %s", err)
 			}
 		},
 	}
@@ -67,21 +65,19 @@ func newDownloadAttachmentCmd(params *baseParams) *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			attachmentID := args[0]
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
-				viper.GetString("bearer_token"),
+				params.token,
 				params.userAgent,
 			)
-			rc, err := client.Attachment(attachmentID)
+			rc, err := client.Attachment(cmd.Context(), attachmentID)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to fetch attachment: %s\n", err)
-				os.Exit(1)
+				dief("Failed to fetch attachment: %s", err)
 			}
 			defer rc.Close()
 			_, err = io.Copy(os.Stdout, rc)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to fetch attachment: %s\n", err)
-				os.Exit(1)
+				dief("Failed to fetch attachment: %s", err)
 			}
 		},
 	}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/cobra"
+)
+
+// configureCache sets up the on-disk cache of slow-changing lookups, such as
+// the devices offered for completion. If the cache directory can't be
+// determined, commands run uncached.
+func configureCache(disabled bool) error {
+	clientOptions.Cache = nil
+	clientOptions.CacheTTL = 0
+	if disabled {
+		return nil
+	}
+	ttl, err := durationSetting("cache_ttl")
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		clientOptions.CacheTTL = ttl
+	}
+	dir, err := api.DefaultCacheDir()
+	if err != nil {
+		return nil
+	}
+	clientOptions.Cache = api.NewFileCache(dir)
+	return nil
+}
+
+func newCacheCommand() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:         "cache",
+		Short:       "Manage the local cache of API responses",
+		Annotations: map[string]string{noCredentials: "true"},
+		Long: `Devices and custom properties are cached locally so that completion and
+validation stay fast. Listings such as 'devices list' always fetch from the
+server. Entries are revalidated with the server once older than cache-ttl
+(default 5m). Pass --no-cache to bypass the cache for a single command.`,
+	}
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached API responses",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			dir, err := api.DefaultCacheDir()
+			if err != nil {
+				dief("Failed to locate cache: %s", err)
+			}
+			if err := api.NewFileCache(dir).Clear(); err != nil {
+				dief("Failed to clear cache: %s", err)
+			}
+			fmt.Fprintln(os.Stderr, "Cache cleared")
+		},
+	}
+	cacheCmd.AddCommand(clearCmd)
+	return cacheCmd
+}
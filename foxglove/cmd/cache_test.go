@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureCache(t *testing.T) {
+	opts := clientOptions
+	t.Cleanup(func() {
+		clientOptions = opts
+		viper.Reset()
+	})
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	t.Run("enables the cache with the configured ttl", func(t *testing.T) {
+		viper.Set("cache_ttl", "1h")
+		assert.Nil(t, configureCache(false))
+		assert.NotNil(t, clientOptions.Cache)
+		assert.Equal(t, time.Hour, clientOptions.CacheTTL)
+	})
+	t.Run("--no-cache disables the cache", func(t *testing.T) {
+		assert.Nil(t, configureCache(true))
+		assert.Nil(t, clientOptions.Cache)
+		assert.Zero(t, clientOptions.CacheTTL)
+	})
+	t.Run("rejects an invalid ttl", func(t *testing.T) {
+		viper.Set("cache_ttl", "soon")
+		assert.ErrorContains(t, configureCache(false), "invalid cache_ttl")
+	})
+}
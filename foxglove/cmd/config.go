@@ -1,90 +1,472 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
 )
 
+// configKey describes a setting that can be managed with `foxglove config`.
+type configKey struct {
+	// name is the key as given on the command line.
+	name        string
+	viperKey    string
+	description string
+	// perProfile settings are held separately by each profile.
+	perProfile bool
+	// values lists the accepted values of an enumerated setting.
+	values []string
+	// validate checks a value before it is stored. Enumerated settings are
+	// checked against values instead.
+	validate func(string) error
+	// defaultValue is shown by `config list` when the setting is not set.
+	defaultValue string
+	// completeFiles offers file names when completing a value.
+	completeFiles bool
+}
+
+// configSchema lists the settings that can be managed with `foxglove config`.
+var configSchema = []configKey{
+	{name: "project-id", viperKey: "default_project_id", perProfile: true, description: "Default project ID for commands"},
+	{name: "device-id", viperKey: "default_device_id", perProfile: true, description: "Default device ID for commands that add data, such as `data import`"},
+	{name: "format", viperKey: "format", values: []string{"table", "json", "ndjson", "csv"}, defaultValue: "table", description: "Default output format of list commands"},
+	{name: "export-format", viperKey: "export_format", values: []string{"mcap0", "bag1", "json"}, defaultValue: "mcap0", description: "Default output format of `data export`"},
+	{name: "export-compression", viperKey: "export_compression", values: []string{"lz4", "zstd", "none"}, description: "Compression of MCAP files written by `data export --output-file`; by default, complete downloads are kept as received"},
+	{name: "export-tmpdir", viperKey: "export_tmpdir", validate: validateDirectory, completeFiles: true, description: "Directory in which `data export` keeps export jobs and their partial downloads; by default, the directory of the output file"},
+	{name: "timeout", viperKey: "timeout", validate: validateDuration, description: "Default for --timeout, e.g. 30m"},
+	{name: "retry-max-attempts", viperKey: "retry_max_attempts", validate: validatePositiveInt, defaultValue: "4", description: "Number of attempts made for requests that fail with transient errors"},
+	{name: "time-zone", viperKey: "time_zone", validate: validateTimeZone, defaultValue: "UTC", description: "Time zone of timestamps given without a UTC offset, e.g. America/Los_Angeles"},
+	{name: "color", viperKey: "color", values: []string{"auto", "always", "never"}, defaultValue: "auto", description: "Use color in tables: auto uses it on terminals unless NO_COLOR is set"},
+	{name: "proxy-url", viperKey: "proxy_url", validate: validateProxyURL, description: "Proxy to send all requests through (default: HTTPS_PROXY)"},
+	{name: "ca-files", viperKey: "ca_files", completeFiles: true, description: "Extra PEM certificate authorities to trust, separated like PATH"},
+	{name: "client-cert", viperKey: "client_cert", completeFiles: true, description: "PEM client certificate for mutual TLS"},
+	{name: "client-key", viperKey: "client_key", completeFiles: true, description: "PEM private key for the client certificate"},
+	{name: "connect-timeout", viperKey: "connect_timeout", validate: validateDuration, description: "Limit on establishing a connection, e.g. 10s"},
+	{name: "read-timeout", viperKey: "read_timeout", validate: validateDuration, description: "Limit on how long a read from the server may stall, e.g. 1m"},
+	{name: "keep-alive", viperKey: "keep_alive", validate: validateSignedDuration, description: "TCP keep-alive interval, e.g. 30s; negative disables keep-alives"},
+	{name: "cache-ttl", viperKey: "cache_ttl", validate: validateDuration, defaultValue: "5m", description: "How long cached lookups are used before revalidation"},
+	{name: "credential-helper", viperKey: "credential_helper", description: `Command that stores tokens in place of the config file, or "encrypted-file"`},
+}
+
+// lookupConfigKey returns the schema of a key given on the command line.
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, key := range configSchema {
+		if key.name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+func configKeyNames() []string {
+	names := []string{}
+	for _, key := range configSchema {
+		names = append(names, key.name)
+	}
+	return names
+}
+
+// check validates a value of the setting.
+func (k configKey) check(value string) error {
+	if len(k.values) > 0 {
+		for _, v := range k.values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value %q for %s: expected one of %s", value, k.name, strings.Join(k.values, ", "))
+	}
+	if k.validate != nil {
+		if err := k.validate(value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, k.name, err)
+		}
+	}
+	return nil
+}
+
+// lookup returns the effective value of the setting and its origin.
+func (k configKey) lookup() (string, settingOrigin) {
+	if k.perProfile {
+		return lookupProfileSetting(k.viperKey)
+	}
+	return lookupConfigSetting(k.viperKey)
+}
+
+// configuredKey returns the viper key under which the setting is stored.
+func (k configKey) configuredKey() string {
+	if k.perProfile {
+		return profilePrefix(activeProfile()) + k.viperKey
+	}
+	return k.viperKey
+}
+
+func validateDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("expected a duration such as 30s or 5m")
+	}
+	if d < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+func validateSignedDuration(value string) error {
+	if _, err := time.ParseDuration(value); err != nil {
+		return fmt.Errorf("expected a duration such as 30s or 5m")
+	}
+	return nil
+}
+
+func validatePositiveInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("expected a whole number of at least 1")
+	}
+	return nil
+}
+
+func validateTimeZone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("expected an IANA time zone such as UTC or Europe/Berlin")
+	}
+	return nil
+}
+
+func validateDirectory(value string) error {
+	info, err := os.Stat(value)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	return nil
+}
+
+func validateProxyURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	default:
+		return fmt.Errorf("expected an http, https or socks5 URL")
+	}
+}
+
+// useColor reports whether tables are shown in color, given the color
+// setting. By default, color is used on terminals unless NO_COLOR is set.
+func useColor(setting string, terminal bool) bool {
+	switch setting {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return terminal && os.Getenv("NO_COLOR") == ""
+	}
+}
+
+// configEntry is a row of `config list`.
+type configEntry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+func (e configEntry) Headers() []string {
+	return []string{"Key", "Value", "Source"}
+}
+
+func (e configEntry) Fields() []string {
+	return []string{e.Key, e.Value, e.Source}
+}
+
+// configEntries returns the effective value of every setting in the schema.
+func configEntries() []configEntry {
+	entries := []configEntry{}
+	for _, key := range configSchema {
+		value, origin := key.lookup()
+		if origin == originDefault {
+			value = key.defaultValue
+		}
+		entries = append(entries, configEntry{Key: key.name, Value: value, Source: string(origin)})
+	}
+	return entries
+}
+
+// validateConfigFile checks the settings in a config file against the
+// schema, including those held by each profile. Keys outside the schema,
+// such as tokens, are left alone.
+func validateConfigFile(data []byte) error {
+	settings := map[string]any{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+	check := func(key configKey, settings map[string]any, where string) error {
+		raw, ok := settings[key.viperKey]
+		if !ok || raw == nil {
+			return nil
+		}
+		if _, isList := raw.([]any); isList && key.name == "ca-files" {
+			return nil
+		}
+		if err := key.check(fmt.Sprint(raw)); err != nil {
+			return fmt.Errorf("%s%w", where, err)
+		}
+		return nil
+	}
+	for _, key := range configSchema {
+		if err := check(key, settings, ""); err != nil {
+			return err
+		}
+	}
+	profiles, ok := settings["profiles"].(map[any]any)
+	if !ok {
+		return nil
+	}
+	for name, raw := range profiles {
+		profile := map[string]any{}
+		values, ok := raw.(map[any]any)
+		if !ok {
+			return fmt.Errorf("profile %v: expected a mapping", name)
+		}
+		for k, v := range values {
+			profile[fmt.Sprint(k)] = v
+		}
+		for _, key := range configSchema {
+			if !key.perProfile {
+				continue
+			}
+			if err := check(key, profile, fmt.Sprintf("profile %v: ", name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// editorCommand returns the command line of the user's editor.
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(env); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editConfigFile lets the user edit a copy of the config file with edit, and
+// replaces the file once the copy is valid. If it isn't, retry is asked
+// whether to edit it again; otherwise the file is left unchanged.
+func editConfigFile(configFile string, edit func(path string) error, retry func(error) bool) error {
+	original, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(configFile), ".foxgloverc-edit-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		if err := edit(tmp.Name()); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			return nil
+		}
+		if err := validateConfigFile(edited); err != nil {
+			if retry(err) {
+				continue
+			}
+			return fmt.Errorf("%w; the config file was not changed", err)
+		}
+		return os.Rename(tmp.Name(), configFile)
+	}
+}
+
+// runEditor opens path in the user's editor, attached to the terminal. The
+// editor setting may include arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := editorCommand()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", editor+" "+path)
+	} else {
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// askEditAgain reports a validation error and, on a terminal, asks whether
+// to return to the editor.
+func askEditAgain(err error) bool {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprint(os.Stderr, "Edit again? [Y/n] ")
+	answer, readErr := bufio.NewReader(os.Stdin).ReadString('\n')
+	if readErr != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// completeConfigKey completes the key, and for `config set` the value, of a
+// config command.
+func completeConfigKey(withValue bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch {
+		case len(args) == 0:
+			candidates := []string{}
+			for _, key := range configSchema {
+				candidates = append(candidates, key.name+"\t"+key.description)
+			}
+			return candidates, cobra.ShellCompDirectiveNoFileComp
+		case len(args) == 1 && withValue:
+			key, ok := lookupConfigKey(args[0])
+			if ok && key.completeFiles {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return key.values, cobra.ShellCompDirectiveNoFileComp
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+}
+
 func newConfigCommand() *cobra.Command {
+	keys := &strings.Builder{}
+	for _, key := range configSchema {
+		fmt.Fprintf(keys, "\n  - %s: %s", key.name, key.description)
+		if len(key.values) > 0 {
+			fmt.Fprintf(keys, " (%s)", strings.Join(key.values, ", "))
+		}
+		if key.perProfile {
+			keys.WriteString(", held per profile")
+		}
+	}
 	configCmd := &cobra.Command{
-		Use:   "config",
-		Short: "Manage CLI configuration",
-		Long: `Manage CLI configuration values.
-Available configuration keys:
-  - project-id: Default project ID for commands`,
+		Use:         "config",
+		Short:       "Manage CLI configuration",
+		Annotations: map[string]string{noCredentials: "true"},
+		Long:        "Manage CLI configuration values.\nAvailable configuration keys:" + keys.String(),
 	}
 
 	configCmd.AddCommand(newConfigGetCommand())
 	configCmd.AddCommand(newConfigSetCommand())
 	configCmd.AddCommand(newConfigUnsetCommand())
+	configCmd.AddCommand(newConfigListCommand())
+	configCmd.AddCommand(newConfigEditCommand())
 
 	return configCmd
 }
 
+// configKeyArg returns the schema of the key named by the first argument, or
+// exits with a usage error.
+func configKeyArg(args []string) configKey {
+	if len(args) == 0 {
+		exitf(exitUsage, "No key provided. Valid keys are: %s", strings.Join(configKeyNames(), ", "))
+	}
+	key, ok := lookupConfigKey(args[0])
+	if !ok {
+		exitf(exitUsage, "Invalid configuration key '%s'. Valid keys are: %s", args[0], strings.Join(configKeyNames(), ", "))
+	}
+	return key
+}
+
 func newConfigGetCommand() *cobra.Command {
+	var showOrigin bool
 	getCmd := &cobra.Command{
 		Use:   "get [KEY]",
 		Short: "Get a configuration value",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Print the effective value of a setting, which may come from an environment
+variable, a project .foxglove.yaml file or the user config file. Pass
+--show-origin to see which.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeConfigKey(false),
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
-				dief("No key provided. Valid keys are: %s", strings.Join(validConfigKeys, ", "))
-			}
-			key := args[0]
-			if !isValidConfigKey(key) {
-				dief("Invalid configuration key '%s'. Valid keys are: %s", key, strings.Join(validConfigKeys, ", "))
+			key := configKeyArg(args)
+			value, origin := key.lookup()
+			if origin == originDefault {
+				dief("No value set for key '%s'", key.name)
 			}
-
-			viperKey := mapConfigKeyToViperKey(key)
-			value := viper.GetString(viperKey)
-
-			if value == "" {
-				if viper.IsSet(viperKey) {
-					fmt.Println()
-				} else {
-					dief("No value set for key '%s'", key)
-				}
+			if showOrigin {
+				fmt.Printf("%s\t%s\n", origin, value)
 			} else {
 				fmt.Println(value)
 			}
 		},
 	}
+	getCmd.PersistentFlags().BoolVarP(&showOrigin, "show-origin", "", false, "show the file or environment variable the value came from")
 	return getCmd
 }
 
 func newConfigSetCommand() *cobra.Command {
 	setCmd := &cobra.Command{
-		Use:   "set [KEY] [VALUE]",
-		Short: "Set a configuration value",
-		Args:  cobra.MaximumNArgs(2),
+		Use:               "set [KEY] [VALUE]",
+		Short:             "Set a configuration value",
+		Args:              cobra.MaximumNArgs(2),
+		ValidArgsFunction: completeConfigKey(true),
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) < 1 {
-				dief("No key provided. Valid keys are: %s", strings.Join(validConfigKeys, ", "))
-			}
-			key := args[0]
-			if !isValidConfigKey(key) {
-				dief("Invalid configuration key '%s'. Valid keys are: %s", key, strings.Join(validConfigKeys, ", "))
-			}
-
+			key := configKeyArg(args)
 			if len(args) < 2 {
-				dief("No value provided. Please provide a value for: %s", args[0])
+				exitf(exitUsage, "No value provided. Please provide a value for: %s", key.name)
 			}
 			value := args[1]
+			if err := key.check(value); err != nil {
+				exitf(exitUsage, "%s", err)
+			}
 
-			viperKey := mapConfigKeyToViperKey(key)
-
-			viper.Set(viperKey, value)
-
-			err := viper.WriteConfigAs(viper.ConfigFileUsed())
+			err := setConfigKey(key.configuredKey(), value)
 			if err != nil {
 				dief("Failed to write config: %s", err)
 			}
 
-			fmt.Fprintf(os.Stderr, "Configuration updated: %s = %s\n", key, value)
+			fmt.Fprintf(os.Stderr, "Configuration updated: %s = %s\n", key.name, value)
+			stored := configOrigin()
+			if key.perProfile {
+				stored = profileOrigin(activeProfile())
+			}
+			if _, origin := key.lookup(); origin != stored {
+				fmt.Fprintf(os.Stderr, "Note: %s is overridden by the %s\n", key.name, origin)
+			}
 		},
 	}
 	return setCmd
@@ -92,61 +474,56 @@ func newConfigSetCommand() *cobra.Command {
 
 func newConfigUnsetCommand() *cobra.Command {
 	unsetCmd := &cobra.Command{
-		Use:   "unset [KEY]",
-		Short: "Remove a configuration value",
-		Args:  cobra.MaximumNArgs(1),
+		Use:               "unset [KEY]",
+		Short:             "Remove a configuration value",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeConfigKey(false),
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
-				dief("No key provided. Valid keys are: %s", strings.Join(validConfigKeys, ", "))
-			}
-			key := args[0]
-			if !isValidConfigKey(key) {
-				dief("Invalid configuration key '%s'. Valid keys are: %s", key, strings.Join(validConfigKeys, ", "))
-			}
-			viperKey := mapConfigKeyToViperKey(key)
+			key := configKeyArg(args)
+			viperKey := key.configuredKey()
 			if !viper.IsSet(viperKey) {
-				dief("No value set for key '%s'", key)
+				dief("No value set for key '%s'", key.name)
 			}
-			configFile := viper.ConfigFileUsed()
-			settings := viper.AllSettings()
-			delete(settings, viperKey)
-
-			// Clear viper and reload with updated settings
-			viper.Reset()
-			viper.SetConfigType("yaml")
-			viper.SetConfigFile(configFile)
-			for k, v := range settings {
-				viper.Set(k, v)
-			}
-
-			err := viper.WriteConfigAs(configFile)
+			err := unsetConfigKey(viperKey)
 			if err != nil {
 				dief("Failed to write config: %s", err)
 			}
-			fmt.Fprintf(os.Stderr, "Configuration removed: %s\n", key)
+			fmt.Fprintf(os.Stderr, "Configuration removed: %s\n", key.name)
 		},
 	}
 	return unsetCmd
 }
 
-var validConfigKeys = []string{
-	"project-id",
-}
-
-func isValidConfigKey(key string) bool {
-	for _, validKey := range validConfigKeys {
-		if key == validKey {
-			return true
-		}
+func newConfigListCommand() *cobra.Command {
+	var format string
+	var isJsonFormat bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the effective configuration and where each value came from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			format = ResolveFormat(format, isJsonFormat)
+			if err := renderRecords(os.Stdout, configEntries(), format); err != nil {
+				dief("Failed to list configuration: %s", err)
+			}
+		},
 	}
-	return false
+	AddFormatFlag(listCmd, &format)
+	AddJsonFlag(listCmd, &isJsonFormat)
+	return listCmd
 }
 
-func mapConfigKeyToViperKey(key string) string {
-	switch key {
-	case "project-id":
-		return "default_project_id"
-	default:
-		return key
+func newConfigEditCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "Edit the config file in $EDITOR",
+		Long: `Open the config file in $VISUAL or $EDITOR. The file is only replaced once
+the edited settings are valid.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := editConfigFile(viper.ConfigFileUsed(), runEditor, askEditAgain); err != nil {
+				dief("Failed to edit config: %s", err)
+			}
+		},
 	}
 }
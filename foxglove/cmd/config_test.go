@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigKeyCheck(t *testing.T) {
+	cases := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"format", "csv", true},
+		{"format", "yaml", false},
+		{"export-compression", "zstd", true},
+		{"export-compression", "gzip", false},
+		{"retry-max-attempts", "3", true},
+		{"retry-max-attempts", "0", false},
+		{"timeout", "30m", true},
+		{"timeout", "thirty", false},
+		{"keep-alive", "-1s", true},
+		{"connect-timeout", "-1s", false},
+		{"time-zone", "Europe/Berlin", true},
+		{"time-zone", "Mars/Olympus", false},
+		{"proxy-url", "http://proxy:3128", true},
+		{"proxy-url", "proxy:3128", false},
+		{"export-tmpdir", t.TempDir(), true},
+		{"export-tmpdir", filepath.Join(t.TempDir(), "missing"), false},
+		{"project-id", "prj_123", true},
+	}
+	for _, c := range cases {
+		t.Run(c.key+"="+c.value, func(t *testing.T) {
+			key, ok := lookupConfigKey(c.key)
+			assert.True(t, ok)
+			err := key.check(c.value)
+			if c.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigEntries(t *testing.T) {
+	configfile := withTestConfig(t)
+	assert.Nil(t, os.WriteFile(configfile, []byte("format: json\nca_files:\n  - a.pem\n  - b.pem\n"), 0600))
+	assert.Nil(t, viper.ReadInConfig())
+	t.Setenv("FOXGLOVE_DEVICE_ID", "dev_env")
+
+	entries := map[string]configEntry{}
+	for _, entry := range configEntries() {
+		entries[entry.Key] = entry
+	}
+	assert.Equal(t, configEntry{"format", "json", "config file " + configfile}, entries["format"])
+	assert.Equal(t, configEntry{"device-id", "dev_env", "environment variable FOXGLOVE_DEVICE_ID"}, entries["device-id"])
+	assert.Equal(t, configEntry{"export-format", "mcap0", "default"}, entries["export-format"])
+	assert.Equal(t, "a.pem"+string(os.PathListSeparator)+"b.pem", entries["ca-files"].Value)
+	assert.Equal(t, "json", ResolveFormat("", false))
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	t.Run("accepts unknown keys and lists of CA files", func(t *testing.T) {
+		err := validateConfigFile([]byte("bearer_token: abc\nca_files: [a.pem]\nformat: csv\n"))
+		assert.Nil(t, err)
+	})
+	t.Run("rejects invalid top-level values", func(t *testing.T) {
+		err := validateConfigFile([]byte("retry_max_attempts: 0\n"))
+		assert.ErrorContains(t, err, "retry-max-attempts")
+	})
+	t.Run("rejects profiles that aren't mappings", func(t *testing.T) {
+		err := validateConfigFile([]byte("profiles:\n  staging: nope\n"))
+		assert.ErrorContains(t, err, "profile staging")
+	})
+	t.Run("rejects invalid YAML", func(t *testing.T) {
+		err := validateConfigFile([]byte("format: [\n"))
+		assert.ErrorContains(t, err, "invalid YAML")
+	})
+}
+
+func TestEditConfigFile(t *testing.T) {
+	write := func(contents ...string) func(string) error {
+		return func(path string) error {
+			data := contents[0]
+			contents = contents[1:]
+			return os.WriteFile(path, []byte(data), 0600)
+		}
+	}
+	t.Run("saves valid edits", func(t *testing.T) {
+		configfile := filepath.Join(t.TempDir(), ".foxgloverc")
+		assert.Nil(t, os.WriteFile(configfile, []byte("format: json\n"), 0600))
+		err := editConfigFile(configfile, write("format: csv\n"), func(error) bool { return false })
+		assert.Nil(t, err)
+		data, err := os.ReadFile(configfile)
+		assert.Nil(t, err)
+		assert.Equal(t, "format: csv\n", string(data))
+	})
+	t.Run("leaves the file unchanged if the edit is invalid", func(t *testing.T) {
+		configfile := filepath.Join(t.TempDir(), ".foxgloverc")
+		assert.Nil(t, os.WriteFile(configfile, []byte("format: json\n"), 0600))
+		err := editConfigFile(configfile, write("format: yaml\n"), func(error) bool { return false })
+		assert.ErrorContains(t, err, "the config file was not changed")
+		data, err := os.ReadFile(configfile)
+		assert.Nil(t, err)
+		assert.Equal(t, "format: json\n", string(data))
+		entries, err := os.ReadDir(filepath.Dir(configfile))
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1)
+	})
+	t.Run("edits again on request", func(t *testing.T) {
+		configfile := filepath.Join(t.TempDir(), ".foxgloverc")
+		var reported error
+		retry := func(err error) bool {
+			reported = err
+			return true
+		}
+		err := editConfigFile(configfile, write("color: purple\n", "color: never\n"), retry)
+		assert.Nil(t, err)
+		assert.ErrorContains(t, reported, "expected one of auto, always, never")
+		data, err := os.ReadFile(configfile)
+		assert.Nil(t, err)
+		assert.Equal(t, "color: never\n", string(data))
+	})
+	t.Run("reports editor failures", func(t *testing.T) {
+		configfile := filepath.Join(t.TempDir(), ".foxgloverc")
+		fail := func(string) error { return errors.New("exit status 1") }
+		err := editConfigFile(configfile, fail, func(error) bool { return false })
+		assert.ErrorContains(t, err, "editor failed")
+	})
+}
+
+func TestCompleteConfigKey(t *testing.T) {
+	complete := completeConfigKey(true)
+	keys, directive := complete(&cobra.Command{}, []string{}, "")
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+	assert.Contains(t, keys, "format\tDefault output format of list commands")
+
+	values, _ := complete(&cobra.Command{}, []string{"color"}, "")
+	assert.Equal(t, []string{"auto", "always", "never"}, values)
+
+	_, directive = complete(&cobra.Command{}, []string{"client-cert"}, "")
+	assert.Equal(t, cobra.ShellCompDirectiveDefault, directive)
+}
+
+func TestUseColor(t *testing.T) {
+	assert.True(t, useColor("auto", true))
+	assert.False(t, useColor("auto", false))
+	assert.True(t, useColor("always", false))
+	assert.False(t, useColor("never", true))
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, useColor("auto", true))
+}
@@ -11,21 +11,25 @@ func newConfigureAPIKeyCommand() *cobra.Command {
 	var token string
 	var baseURL string
 	configCmd := &cobra.Command{
-		Use:   "configure-api-key",
-		Short: "Configure an API key",
+		Use:         "configure-api-key",
+		Short:       "Configure an API key",
+		Annotations: map[string]string{createsProfile: "true"},
 		Run: func(cmd *cobra.Command, args []string) {
 			if token == "" {
 				prompt := fmt.Sprintf("Enter an API key (will be written to %s):\n", viper.ConfigFileUsed())
+				if helper, err := configuredCredentialHelper(false); err == nil && helper != nil {
+					prompt = fmt.Sprintf("Enter an API key (will be stored with credential helper %s):\n", configSetting("credential_helper"))
+				}
 				token = promptForInput(prompt)
 			}
-			err := configureAuth(token, defaultString(baseURL, defaultBaseURL), TokenApiKey)
+			err := configureAuth(token, defaultString(baseURL, defaultBaseURL), cmd.Flags().Changed("base-url"), TokenApiKey)
 			if err != nil {
 				dief("Configuration failed: %s", err)
 			}
 		},
 	}
 	configCmd.PersistentFlags().StringVarP(&token, "api-key", "", "", "api key (for non-interactive use)")
-	configCmd.PersistentFlags().StringVarP(&baseURL, "base-url", "", defaultBaseURL, "API server")
+	configCmd.PersistentFlags().StringVarP(&baseURL, "base-url", "", defaultString(profileSetting("base_url"), defaultBaseURL), "API server")
 	configCmd.InheritedFlags()
 	return configCmd
 }
@@ -5,7 +5,6 @@ import (
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newListCoverageCommand(params *baseParams) *cobra.Command {
@@ -28,11 +27,12 @@ func newListCoverageCommand(params *baseParams) *cobra.Command {
 			if err := validateSessionKeyRequiresProjectID(sessionKey, projectID); err != nil {
 				dief("%s", err)
 			}
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
+			deviceID, deviceName = resolveDeviceName(cmd.Context(), client, projectID, deviceID, deviceName)
 			// We accept ISO8601, which is a little more lenient than the API. Here
 			// we convert to RFC3339.
 			startTime, err := maybeConvertToRFC3339(start)
@@ -45,6 +45,7 @@ func newListCoverageCommand(params *baseParams) *cobra.Command {
 			}
 			format = ResolveFormat(format, isJsonFormat)
 			err = renderList(
+				cmd.Context(),
 				os.Stdout,
 				&api.CoverageRequest{
 					ProjectID:             projectID,
@@ -67,7 +68,7 @@ func newListCoverageCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	coverageListCmd.InheritedFlags()
-	coverageListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when using --session-key)")
+	coverageListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when using --session-key)")
 	coverageListCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID")
 	coverageListCmd.PersistentFlags().StringVarP(&deviceName, "device-name", "", "", "Device name")
 	coverageListCmd.PersistentFlags().StringVarP(&recordingID, "recording-id", "", "", "Recording ID")
@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// encryptedFileHelperName selects the built-in credential helper, which keeps
+// tokens in a file encrypted with a passphrase.
+const encryptedFileHelperName = "encrypted-file"
+
+// noCredentials annotates commands that never use the stored token, so that
+// the credential helper isn't consulted before they run.
+const noCredentials = "noCredentials"
+
+// credential identifies a token held by a credential helper. Its fields are
+// exchanged with external helpers as key=value lines.
+type credential struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Profile  string `json:"profile"`
+}
+
+// newCredential returns the credential of a profile for the API at baseURL.
+func newCredential(baseURL string, profile string) credential {
+	c := credential{Protocol: "https", Host: baseURL, Profile: profile}
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		c.Protocol = u.Scheme
+		c.Host = u.Host
+	}
+	return c
+}
+
+// credentialHelper stores tokens outside of the config file.
+type credentialHelper interface {
+	// get returns the token for c, or an empty string if there is none.
+	get(c credential) (string, error)
+	store(c credential, token string) error
+	erase(c credential) error
+}
+
+// configuredCredentialHelper returns the helper set by the credential-helper
+// config key, or nil if tokens are stored in the config file. The helper may
+// prompt for input on the terminal if interactive is set.
+func configuredCredentialHelper(interactive bool) (credentialHelper, error) {
+	name := configSetting("credential_helper")
+	switch name {
+	case "":
+		return nil, nil
+	case encryptedFileHelperName:
+		path, err := defaultEncryptedCredentialsFile()
+		if err != nil {
+			return nil, err
+		}
+		return newEncryptedFileHelper(path, passphrasePrompt(interactive)), nil
+	default:
+		return &execHelper{command: name}, nil
+	}
+}
+
+// needsCredentials reports whether cmd may use the stored token.
+func needsCredentials(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[noCredentials] == "true" || c.Annotations[createsProfile] == "true" {
+			return false
+		}
+		// Cobra's built-in commands can't be annotated.
+		if c.Name() == "help" || c.Name() == "completion" {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveToken returns the token of the active profile, reading it from the
+// credential helper unless it is stored in the config file.
+func resolveToken(baseURL string, interactive bool) (string, error) {
+	if token := profileSetting("bearer_token"); token != "" {
+		return token, nil
+	}
+	helper, err := configuredCredentialHelper(interactive)
+	if err != nil || helper == nil {
+		return "", err
+	}
+	return helper.get(newCredential(baseURL, activeProfile()))
+}
+
+// storeToken saves the token of the active profile with the credential
+// helper, if one is configured, and in the config file otherwise.
+func storeToken(token, baseURL string) error {
+	helper, err := configuredCredentialHelper(true)
+	if err != nil {
+		return err
+	}
+	if helper == nil {
+		if err := setConfigKey(profileKey("bearer_token"), token); err != nil {
+			return fmt.Errorf("Failed to write config: %w", err)
+		}
+		return nil
+	}
+	if err := helper.store(newCredential(baseURL, activeProfile()), token); err != nil {
+		return fmt.Errorf("credential helper failed to store token: %w", err)
+	}
+	// Drop any plaintext token left from before the helper was configured.
+	return unsetConfigKey(profileKey("bearer_token"))
+}
+
+// eraseToken removes the token of a profile from the credential helper, if
+// one is configured.
+func eraseToken(baseURL string, profile string) error {
+	helper, err := configuredCredentialHelper(true)
+	if err != nil || helper == nil {
+		return err
+	}
+	if err := helper.erase(newCredential(baseURL, profile)); err != nil {
+		return fmt.Errorf("credential helper failed to erase token: %w", err)
+	}
+	return nil
+}
+
+// execHelper is an external credential helper. Like git's credential
+// helpers, it is run through the shell with an action of get, store or erase
+// as its final argument, and is sent the credential as key=value lines on
+// stdin. For get it responds with a token=... line on stdout, or nothing if it
+// has no token. Anything it writes to stderr is passed through to the user.
+type execHelper struct {
+	command string
+}
+
+func (h *execHelper) run(action string, c credential, token string) (map[string]string, error) {
+	input := &bytes.Buffer{}
+	fmt.Fprintf(input, "protocol=%s\nhost=%s\nprofile=%s\n", c.Protocol, c.Host, c.Profile)
+	if token != "" {
+		if strings.ContainsAny(token, "\r\n") {
+			return nil, fmt.Errorf("token contains a line break")
+		}
+		fmt.Fprintf(input, "token=%s\n", token)
+	}
+	input.WriteString("\n")
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", h.command+" "+action)
+	} else {
+		cmd = exec.Command("sh", "-c", h.command+" "+action)
+	}
+	cmd.Stdin = input
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", h.command, action, err)
+	}
+	return parseCredentialOutput(bytes.NewReader(output))
+}
+
+// parseCredentialOutput reads key=value lines up to the first blank line.
+func parseCredentialOutput(r io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid credential helper output %q: expected key=value", line)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+func (h *execHelper) get(c credential) (string, error) {
+	values, err := h.run("get", c, "")
+	if err != nil {
+		return "", err
+	}
+	return values["token"], nil
+}
+
+func (h *execHelper) store(c credential, token string) error {
+	_, err := h.run("store", c, token)
+	return err
+}
+
+func (h *execHelper) erase(c credential) error {
+	_, err := h.run("erase", c, "")
+	return err
+}
+
+// passphraseFunc returns the passphrase of the encrypted credentials file.
+// When the file is being created, confirm is set and a prompt should ask for
+// the passphrase twice.
+type passphraseFunc func(confirm bool) (string, error)
+
+// passphrasePrompt reads the passphrase from FOXGLOVE_CREDENTIAL_PASSPHRASE or,
+// if interactive, from the terminal.
+func passphrasePrompt(interactive bool) passphraseFunc {
+	return func(confirm bool) (string, error) {
+		if passphrase := os.Getenv("FOXGLOVE_CREDENTIAL_PASSPHRASE"); passphrase != "" {
+			return passphrase, nil
+		}
+		fd := int(os.Stdin.Fd())
+		if !interactive || !term.IsTerminal(fd) {
+			return "", fmt.Errorf("the encrypted credentials file needs a passphrase: set FOXGLOVE_CREDENTIAL_PASSPHRASE")
+		}
+		fmt.Fprint(os.Stderr, "Credentials passphrase: ")
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if len(passphrase) == 0 {
+			return "", fmt.Errorf("passphrase must not be empty")
+		}
+		if confirm {
+			fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+			again, err := term.ReadPassword(fd)
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return "", fmt.Errorf("failed to read passphrase: %w", err)
+			}
+			if !bytes.Equal(passphrase, again) {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+		}
+		return string(passphrase), nil
+	}
+}
+
+// defaultEncryptedCredentialsFile returns the location of the file kept by
+// the encrypted-file helper.
+func defaultEncryptedCredentialsFile() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, appname, "credentials.enc"), nil
+}
+
+// pbkdf2Iterations is the work factor used when deriving a key from a new
+// passphrase. Files record the count they were written with.
+const pbkdf2Iterations = 600_000
+
+// encryptedCredentialsFile is the on-disk form of the encrypted-file helper's
+// store. The plaintext is a JSON list of storedCredential, sealed with
+// AES-256-GCM under a key derived from the passphrase with PBKDF2-SHA256.
+type encryptedCredentialsFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type storedCredential struct {
+	credential
+	Token string `json:"token"`
+}
+
+// encryptedFileHelper is the built-in credential helper.
+type encryptedFileHelper struct {
+	path       string
+	passphrase passphraseFunc
+
+	// The key is derived once per process, so that a store following a get
+	// prompts only once.
+	key        []byte
+	salt       []byte
+	iterations int
+}
+
+func newEncryptedFileHelper(path string, passphrase passphraseFunc) *encryptedFileHelper {
+	return &encryptedFileHelper{path: path, passphrase: passphrase}
+}
+
+func (h *encryptedFileHelper) deriveKey(confirm bool, salt []byte, iterations int) error {
+	passphrase, err := h.passphrase(confirm)
+	if err != nil {
+		return err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return err
+	}
+	h.key, h.salt, h.iterations = key, salt, iterations
+	return nil
+}
+
+func (h *encryptedFileHelper) load() ([]storedCredential, error) {
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file := encryptedCredentialsFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("corrupt credentials file %s: %w", h.path, err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("unsupported credentials file version %d", file.Version)
+	}
+	if h.key == nil || !bytes.Equal(h.salt, file.Salt) || h.iterations != file.Iterations {
+		if err := h.deriveKey(false, file.Salt, file.Iterations); err != nil {
+			return nil, err
+		}
+	}
+	gcm, err := newGCM(h.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: incorrect passphrase or corrupt file", h.path)
+	}
+	credentials := []storedCredential{}
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, fmt.Errorf("corrupt credentials file %s: %w", h.path, err)
+	}
+	return credentials, nil
+}
+
+func (h *encryptedFileHelper) save(credentials []storedCredential) error {
+	if h.key == nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if err := h.deriveKey(true, salt, pbkdf2Iterations); err != nil {
+			return err
+		}
+	}
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(h.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(encryptedCredentialsFile{
+		Version:    1,
+		Iterations: h.iterations,
+		Salt:       h.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), "credentials")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (h *encryptedFileHelper) get(c credential) (string, error) {
+	credentials, err := h.load()
+	if err != nil {
+		return "", err
+	}
+	for _, stored := range credentials {
+		if stored.credential == c {
+			return stored.Token, nil
+		}
+	}
+	return "", nil
+}
+
+func (h *encryptedFileHelper) store(c credential, token string) error {
+	credentials, err := h.load()
+	if err != nil {
+		return err
+	}
+	credentials = removeCredential(credentials, c)
+	return h.save(append(credentials, storedCredential{credential: c, Token: token}))
+}
+
+func (h *encryptedFileHelper) erase(c credential) error {
+	if _, err := os.Stat(h.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	credentials, err := h.load()
+	if err != nil {
+		return err
+	}
+	return h.save(removeCredential(credentials, c))
+}
+
+func removeCredential(credentials []storedCredential, c credential) []storedCredential {
+	kept := []storedCredential{}
+	for _, stored := range credentials {
+		if stored.credential != c {
+			kept = append(kept, stored)
+		}
+	}
+	return kept
+}
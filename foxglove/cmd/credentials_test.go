@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCredentialHelper writes a shell script that records the input of each
+// invocation and answers get requests with token.
+func fakeCredentialHelper(t *testing.T, token string) (command string, log string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script requires sh")
+	}
+	dir := t.TempDir()
+	log = filepath.Join(dir, "log")
+	script := filepath.Join(dir, "helper")
+	body := fmt.Sprintf(`#!/bin/sh
+echo "action=$1" >> %[1]s
+cat >> %[1]s
+if [ "$1" = get ]; then
+	echo "token=%[2]s"
+fi
+`, log, token)
+	assert.Nil(t, os.WriteFile(script, []byte(body), 0700))
+	return script, log
+}
+
+func TestExecCredentialHelper(t *testing.T) {
+	command, log := fakeCredentialHelper(t, "helper-token")
+	helper := &execHelper{command: command}
+	c := newCredential("https://api.example.com", "staging")
+
+	assert.Nil(t, helper.store(c, "new-token"))
+	token, err := helper.get(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "helper-token", token)
+	assert.Nil(t, helper.erase(c))
+
+	data, err := os.ReadFile(log)
+	assert.Nil(t, err)
+	assert.Equal(t, `action=store
+protocol=https
+host=api.example.com
+profile=staging
+token=new-token
+
+action=get
+protocol=https
+host=api.example.com
+profile=staging
+
+action=erase
+protocol=https
+host=api.example.com
+profile=staging
+
+`, string(data))
+
+	t.Run("reports helper failures", func(t *testing.T) {
+		_, err := (&execHelper{command: "exit 1 #"}).get(c)
+		assert.ErrorContains(t, err, "exit status 1")
+	})
+	t.Run("rejects malformed output", func(t *testing.T) {
+		_, err := (&execHelper{command: "echo nonsense #"}).get(c)
+		assert.ErrorContains(t, err, "expected key=value")
+	})
+}
+
+func TestEncryptedFileHelper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	passphrase := func(p string) passphraseFunc {
+		return func(bool) (string, error) { return p, nil }
+	}
+	staging := newCredential("https://api.example.com", "staging")
+	production := newCredential("https://api.example.com", "production")
+
+	helper := newEncryptedFileHelper(path, passphrase("secret"))
+	assert.Nil(t, helper.store(staging, "staging-token"))
+	assert.Nil(t, helper.store(production, "production-token"))
+	assert.Nil(t, helper.store(staging, "staging-token-2"))
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "staging-token")
+
+	helper = newEncryptedFileHelper(path, passphrase("secret"))
+	token, err := helper.get(staging)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging-token-2", token)
+
+	_, err = newEncryptedFileHelper(path, passphrase("wrong")).get(staging)
+	assert.ErrorContains(t, err, "incorrect passphrase")
+
+	assert.Nil(t, helper.erase(staging))
+	token, err = helper.get(staging)
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+	token, err = helper.get(production)
+	assert.Nil(t, err)
+	assert.Equal(t, "production-token", token)
+}
+
+func TestCredentialHelperConfig(t *testing.T) {
+	t.Run("login stores the token with the helper, not in the config", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		t.Setenv("FOXGLOVE_CREDENTIAL_PASSPHRASE", "secret")
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+		contents := "credential_helper: " + encryptedFileHelperName + "\nbearer_token: plaintext-token\n"
+		assert.Nil(t, os.WriteFile(configfile, []byte(contents), 0600))
+		assert.Nil(t, viper.ReadInConfig())
+
+		assert.Nil(t, configureAuth("fox_sk_secret", "https://api.example.com", true, TokenApiKey))
+		data, err := os.ReadFile(configfile)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(data), "plaintext-token")
+		assert.NotContains(t, string(data), "fox_sk_secret")
+		assert.Contains(t, string(data), "https://api.example.com")
+
+		token, err := resolveToken("https://api.example.com", false)
+		assert.Nil(t, err)
+		assert.Equal(t, "fox_sk_secret", token)
+
+		assert.Nil(t, eraseToken("https://api.example.com", defaultProfile))
+		token, err = resolveToken("https://api.example.com", false)
+		assert.Nil(t, err)
+		assert.Equal(t, "", token)
+	})
+	t.Run("a token in the config file takes precedence", func(t *testing.T) {
+		withTestConfig(t)
+		command, log := fakeCredentialHelper(t, "helper-token")
+		viper.Set("credential_helper", command)
+		viper.Set("bearer_token", "plaintext-token")
+		token, err := resolveToken(defaultBaseURL, false)
+		assert.Nil(t, err)
+		assert.Equal(t, "plaintext-token", token)
+		assert.NoFileExists(t, log)
+	})
+	t.Run("commands that don't use credentials skip the helper", func(t *testing.T) {
+		root := &cobra.Command{Use: "foxglove"}
+		config := &cobra.Command{Use: "config", Annotations: map[string]string{noCredentials: "true"}}
+		get := &cobra.Command{Use: "get"}
+		devices := &cobra.Command{Use: "devices"}
+		root.AddCommand(config, devices)
+		config.AddCommand(get)
+		assert.False(t, needsCredentials(get))
+		assert.True(t, needsCredentials(devices))
+	})
+}
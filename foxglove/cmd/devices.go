@@ -1,13 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/foxglove/foxglove-cli/foxglove/util"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newListDevicesCommand(params *baseParams) *cobra.Command {
@@ -18,13 +18,14 @@ func newListDevicesCommand(params *baseParams) *cobra.Command {
 		Use:   "list",
 		Short: "List devices registered to your organization",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			format = ResolveFormat(format, isJsonFormat)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				api.DevicesRequest{
 					ProjectID: projectID,
@@ -38,7 +39,7 @@ func newListDevicesCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	deviceListCmd.InheritedFlags()
-	deviceListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID")
+	deviceListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID")
 	AddFormatFlag(deviceListCmd, &format)
 	AddJsonFlag(deviceListCmd, &isJsonFormat)
 	return deviceListCmd
@@ -53,7 +54,7 @@ func newAddDeviceCommand(params *baseParams) *cobra.Command {
 		Use:   "add",
 		Short: "Add a device for your organization",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
@@ -63,12 +64,12 @@ func newAddDeviceCommand(params *baseParams) *cobra.Command {
 				fmt.Fprintf(os.Stderr, "Warning: serial-number is deprecated and will be removed in the next release\n")
 			}
 
-			properties, err := util.DeviceProperties(propertyPairs, client)
+			properties, err := util.DeviceProperties(cmd.Context(), propertyPairs, client)
 			if err != nil {
 				dief("Failed to create device: %s", err)
 			}
 
-			resp, err := client.CreateDevice(api.CreateDeviceRequest{
+			resp, err := client.CreateDevice(cmd.Context(), api.CreateDeviceRequest{
 				Name:       name,
 				ProjectID:  projectID,
 				Properties: properties,
@@ -81,7 +82,7 @@ func newAddDeviceCommand(params *baseParams) *cobra.Command {
 	}
 	addDeviceCmd.InheritedFlags()
 	addDeviceCmd.PersistentFlags().StringVarP(&name, "name", "", "", "name of the device")
-	addDeviceCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID")
+	addDeviceCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID")
 	addDeviceCmd.PersistentFlags().StringVarP(&serialNumber, "serial-number", "", "", "Deprecated. Value will be ignored.")
 	addDeviceCmd.PersistentFlags().StringArrayVarP(&propertyPairs, "property", "p", []string{}, "Custom property colon-separated key value pair. Multiple may be specified.")
 	return addDeviceCmd
@@ -96,13 +97,13 @@ func newEditDeviceCommand(params *baseParams) *cobra.Command {
 		Short: "Edit a device",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 
-			properties, err := util.DeviceProperties(propertyPairs, client)
+			properties, err := util.DeviceProperties(cmd.Context(), propertyPairs, client)
 			if err != nil {
 				dief("Failed to edit device: %s", err)
 			}
@@ -121,6 +122,7 @@ func newEditDeviceCommand(params *baseParams) *cobra.Command {
 			}
 
 			resp, err := client.EditDevice(
+				cmd.Context(),
 				nameOrId,
 				api.EditDeviceRequestQuery{
 					ProjectID: projectID,
@@ -137,7 +139,37 @@ func newEditDeviceCommand(params *baseParams) *cobra.Command {
 	}
 	editDeviceCmd.InheritedFlags()
 	editDeviceCmd.PersistentFlags().StringVarP(&name, "name", "", "", "New name for the device")
-	editDeviceCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID")
+	editDeviceCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID")
 	editDeviceCmd.PersistentFlags().StringArrayVarP(&propertyPairs, "property", "p", []string{}, "Custom property colon-separated key value pair. Multiple may be specified.")
 	return editDeviceCmd
 }
+
+// resolveDeviceName looks up a --device-name in the cached device list, so
+// that commands given one don't each ask the server to resolve it. It returns
+// the device ID and name to send: the ID of the named device if exactly one
+// matches, and otherwise the name unchanged, for the server to resolve and
+// report on as before. A device ID, if given, is used as is.
+func resolveDeviceName(ctx context.Context, client *api.FoxgloveClient, projectID, deviceID, deviceName string) (string, string) {
+	if deviceID != "" || deviceName == "" {
+		return deviceID, deviceName
+	}
+	devices, err := client.CachedDevices(ctx, api.DevicesRequest{ProjectID: projectID})
+	if err != nil {
+		debugf("failed to look up device %q: %s", deviceName, err)
+		return "", deviceName
+	}
+	var id string
+	for _, device := range devices {
+		if device.Name != deviceName {
+			continue
+		}
+		if id != "" {
+			return "", deviceName
+		}
+		id = device.ID
+	}
+	if id == "" {
+		return "", deviceName
+	}
+	return id, ""
+}
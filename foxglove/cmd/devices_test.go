@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/stretchr/testify/assert"
@@ -15,7 +16,7 @@ func TestAddDeviceCommand(t *testing.T) {
 
 	t.Run("creates a device", func(t *testing.T) {
 		client := api.NewMockAuthedClient(t, sv.BaseURL())
-		dev, err := client.CreateDevice(api.CreateDeviceRequest{
+		dev, err := client.CreateDevice(ctx, api.CreateDeviceRequest{
 			Name:       "new-device",
 			ProjectID:  "prj_1234abcd",
 			Properties: map[string]interface{}{"key": "val"},
@@ -39,6 +40,7 @@ func TestEditDeviceCommand(t *testing.T) {
 	t.Run("edits a device", func(t *testing.T) {
 		client := api.NewMockAuthedClient(t, sv.BaseURL())
 		dev, err := client.EditDevice(
+			ctx,
 			"test-device",
 			api.EditDeviceRequestQuery{
 				ProjectID: "prj_1234abcd",
@@ -53,3 +55,47 @@ func TestEditDeviceCommand(t *testing.T) {
 		assert.Equal(t, dev.Properties, map[string]interface{}{"key": "val"})
 	})
 }
+
+func TestResolveDeviceName(t *testing.T) {
+	ctx := context.Background()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+	opts := clientOptions
+	t.Cleanup(func() {
+		clientOptions = opts
+	})
+	clientOptions.Cache = api.NewFileCache(t.TempDir())
+	clientOptions.CacheTTL = time.Hour
+	token, err := api.NewRemoteFoxgloveClient(sv.BaseURL(), "abc", "", "user-agent").SignIn(ctx, "client-id")
+	assert.Nil(t, err)
+	client := newClient(sv.BaseURL(), "abc", token, "user-agent")
+	for _, project := range []string{"prj_1", "prj_2"} {
+		_, err := client.CreateDevice(ctx, api.CreateDeviceRequest{Name: "shared-name", ProjectID: project})
+		assert.Nil(t, err)
+	}
+	unique, err := client.CreateDevice(ctx, api.CreateDeviceRequest{Name: "unique-name", ProjectID: "prj_1"})
+	assert.Nil(t, err)
+
+	t.Run("resolves a name through the cached device list", func(t *testing.T) {
+		before := sv.RequestCount("/v1/devices")
+		for i := 0; i < 2; i++ {
+			deviceID, deviceName := resolveDeviceName(ctx, client, "", "", "unique-name")
+			assert.Equal(t, unique.ID, deviceID)
+			assert.Equal(t, "", deviceName)
+		}
+		assert.Equal(t, before+1, sv.RequestCount("/v1/devices"))
+	})
+	t.Run("leaves unknown and ambiguous names to the server", func(t *testing.T) {
+		deviceID, deviceName := resolveDeviceName(ctx, client, "", "", "missing")
+		assert.Equal(t, "", deviceID)
+		assert.Equal(t, "missing", deviceName)
+		deviceID, deviceName = resolveDeviceName(ctx, client, "", "", "shared-name")
+		assert.Equal(t, "", deviceID)
+		assert.Equal(t, "shared-name", deviceName)
+	})
+	t.Run("keeps a given device ID", func(t *testing.T) {
+		deviceID, deviceName := resolveDeviceName(ctx, client, "", "dev_1", "unique-name")
+		assert.Equal(t, "dev_1", deviceID)
+		assert.Equal(t, "unique-name", deviceName)
+	})
+}
@@ -14,13 +14,14 @@ func newListEventTypesCommand(params *baseParams) *cobra.Command {
 		Use:   "list",
 		Short: "List event types",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			format = ResolveFormat(format, isJsonFormat)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				&api.EventTypesRequest{},
 				client.EventTypes,
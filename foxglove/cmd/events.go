@@ -19,7 +19,7 @@ func newAddEventCommand(params *baseParams) *cobra.Command {
 		Use:   "add",
 		Short: "Add an event",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
@@ -29,13 +29,12 @@ func newAddEventCommand(params *baseParams) *cobra.Command {
 			for _, kv := range keyvals {
 				key, val, err := util.SplitPair(kv, ':')
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Invalid metadata key/value pair: %s\n", kv)
-					os.Exit(1)
+					dief("Invalid metadata key/value pair: %s", kv)
 				}
 				metadata[key] = val
 			}
 
-			response, err := client.CreateEvent(api.CreateEventRequest{
+			response, err := client.CreateEvent(cmd.Context(), api.CreateEventRequest{
 				DeviceID:    deviceID,
 				Start:       start,
 				End:         end,
@@ -43,13 +42,12 @@ func newAddEventCommand(params *baseParams) *cobra.Command {
 				EventTypeID: eventTypeID,
 			})
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to add event: %s\n", err)
-				os.Exit(1)
+				dief("Failed to add event: %s", err)
 			}
 			fmt.Fprintf(os.Stderr, "Created event: %s\n", response.ID)
 		},
 	}
-	addEventCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID")
+	addEventCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", profileSetting("default_device_id"), "Device ID")
 	addEventCmd.PersistentFlags().StringVarP(&start, "start", "", "", "Start of event, RFC 3339 date-time format")
 	addEventCmd.PersistentFlags().StringVarP(&end, "end", "", "", "End of event (inclusive), RFC 3339 date-time format")
 	addEventCmd.PersistentFlags().StringArrayVarP(&keyvals, "metadata", "m", []string{}, "Metadata colon-separated key value pair. Multiple may be specified.")
@@ -71,6 +69,7 @@ func newListEventsCommand(params *baseParams) *cobra.Command {
 	var eventTypeID string
 	var queryFields []string
 	var isJsonFormat bool
+	var all bool
 	eventsListCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List events",
@@ -80,13 +79,15 @@ func newListEventsCommand(params *baseParams) *cobra.Command {
 					dief("Invalid --query-field value %q: must be \"metadata\" or \"properties\"", qf)
 				}
 			}
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
+			deviceID, deviceName = resolveDeviceName(cmd.Context(), client, "", deviceID, deviceName)
 			format = ResolveFormat(format, isJsonFormat)
-			err := renderList(
+			err := renderPagedList(
+				cmd.Context(),
 				os.Stdout,
 				&api.EventsRequest{
 					DeviceID:    deviceID,
@@ -103,6 +104,7 @@ func newListEventsCommand(params *baseParams) *cobra.Command {
 				},
 				client.Events,
 				format,
+				all,
 			)
 			if err != nil {
 				dief("Failed to list events: %s", err)
@@ -124,5 +126,6 @@ func newListEventsCommand(params *baseParams) *cobra.Command {
 	AddDeviceAutocompletion(eventsListCmd, params)
 	AddFormatFlag(eventsListCmd, &format)
 	AddJsonFlag(eventsListCmd, &isJsonFormat)
+	AddAllFlag(eventsListCmd, &all)
 	return eventsListCmd
 }
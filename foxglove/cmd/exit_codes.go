@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+)
+
+// Exit codes returned by the CLI. These are part of the CLI's interface and
+// are documented in the README; do not renumber them.
+const (
+	exitFailure        = 1   // unclassified failure
+	exitUsage          = 2   // invalid command line
+	exitAuth           = 3   // missing, invalid or insufficient credentials
+	exitNotFound       = 4   // the requested resource does not exist
+	exitInvalid        = 5   // the server rejected the request as invalid
+	exitRateLimited    = 6   // the server is rate limiting requests
+	exitServer         = 7   // the server failed to process the request
+	exitNetwork        = 8   // the server could not be reached
+	exitSessionExpired = 9   // the session token expired or was revoked
+	exitTimeout        = 124 // --timeout elapsed
+	exitInterrupted    = 130 // interrupted by a signal
+)
+
+// commandContext is the context commands run under. It is consulted to tell
+// a timeout from an interrupt, since both surface as context.Canceled.
+var commandContext = context.Background()
+
+// commandAuth is the kind of token commands run with, or Unknown if there is
+// none. It is consulted to tell an expired session from other 401s.
+var commandAuth = Unknown
+
+// exitCode returns the exit code that describes err.
+func exitCode(err error) int {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(context.Cause(commandContext), context.DeadlineExceeded) {
+			return exitTimeout
+		}
+		return exitInterrupted
+	}
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized && commandAuth == TokenSession:
+			return exitSessionExpired
+		case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+			return exitAuth
+		case apiErr.StatusCode == http.StatusNotFound:
+			return exitNotFound
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return exitRateLimited
+		case apiErr.StatusCode >= 500:
+			return exitServer
+		case apiErr.StatusCode >= 400:
+			return exitInvalid
+		}
+		return exitFailure
+	}
+	if errors.Is(err, api.ErrForbidden) || errors.Is(err, api.ErrLoginExpired) || errors.Is(err, api.ErrLoginDenied) {
+		return exitAuth
+	}
+	if errors.Is(err, api.ErrNotFound) {
+		return exitNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+	return exitFailure
+}
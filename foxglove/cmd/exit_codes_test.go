@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		assertion string
+		err       error
+		code      int
+	}{
+		{"unclassified", errors.New("boom"), exitFailure},
+		{"unauthorized", &api.APIError{StatusCode: http.StatusUnauthorized}, exitAuth},
+		{"forbidden", &api.APIError{StatusCode: http.StatusForbidden}, exitAuth},
+		{"not found", &api.APIError{StatusCode: http.StatusNotFound}, exitNotFound},
+		{"validation", &api.APIError{StatusCode: http.StatusUnprocessableEntity}, exitInvalid},
+		{"bad request", &api.APIError{StatusCode: http.StatusBadRequest}, exitInvalid},
+		{"rate limited", &api.APIError{StatusCode: http.StatusTooManyRequests}, exitRateLimited},
+		{"server error", &api.APIError{StatusCode: http.StatusServiceUnavailable}, exitServer},
+		{"wrapped", fmt.Errorf("failed: %w", &api.APIError{StatusCode: http.StatusNotFound}), exitNotFound},
+		{"sentinel", api.ErrForbidden, exitAuth},
+		{"login expired", api.ErrLoginExpired, exitAuth},
+		{"network", &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("connection refused")}, exitNetwork},
+		{"interrupted", fmt.Errorf("request failed: %w", context.Canceled), exitInterrupted},
+		{"timed out", context.DeadlineExceeded, exitTimeout},
+	}
+	for _, c := range cases {
+		t.Run(c.assertion, func(t *testing.T) {
+			assert.Equal(t, c.code, exitCode(c.err))
+		})
+	}
+	t.Run("expired session", func(t *testing.T) {
+		commandAuth = TokenSession
+		t.Cleanup(func() { commandAuth = Unknown })
+		assert.Equal(t, exitSessionExpired, exitCode(fmt.Errorf("failed: %w", &api.APIError{StatusCode: http.StatusUnauthorized})))
+		assert.Equal(t, exitAuth, exitCode(&api.APIError{StatusCode: http.StatusForbidden}))
+	})
+	t.Run("timeout via command context", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(fmt.Errorf("command timed out: %w", context.DeadlineExceeded))
+		commandContext = ctx
+		t.Cleanup(func() { commandContext = context.Background() })
+		assert.Equal(t, exitTimeout, exitCode(ctx.Err()))
+	})
+}
+
+func TestDief(t *testing.T) {
+	code := -1
+	exitFn, offer := exit, offerLogin
+	exit = func(c int) { code = c }
+	commandAuth = TokenSession
+	t.Cleanup(func() {
+		exit, offerLogin = exitFn, offer
+		commandAuth = Unknown
+	})
+	expired := &api.APIError{StatusCode: http.StatusUnauthorized}
+
+	t.Run("reports an expired session if the user doesn't log in", func(t *testing.T) {
+		offerLogin = func() bool { return false }
+		dief("Failed: %s", expired)
+		assert.Equal(t, exitSessionExpired, code)
+	})
+	t.Run("reports a failure once the user has logged in again", func(t *testing.T) {
+		offerLogin = func() bool { return true }
+		dief("Failed: %s", expired)
+		assert.Equal(t, exitFailure, code)
+	})
+	t.Run("does not offer to log in for other errors", func(t *testing.T) {
+		offerLogin = func() bool {
+			t.Error("unexpected login offer")
+			return true
+		}
+		dief("Failed: %s", &api.APIError{StatusCode: http.StatusNotFound})
+		assert.Equal(t, exitNotFound, code)
+	})
+}
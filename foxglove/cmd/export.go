@@ -1,16 +1,22 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
-	"log"
+	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
@@ -83,16 +89,105 @@ type Message struct {
 	Data        json.RawMessage `json:"data"`
 }
 
+// jsonWriter writes MCAP messages as lines of JSON.
+type jsonWriter struct {
+	encoder     *json.Encoder
+	msg         *bytes.Buffer
+	msgReader   *bytes.Reader
+	transcoders map[uint16]*ros1msg.JSONTranscoder
+	descriptors map[uint16]protoreflect.MessageDescriptor
+	target      Message
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{
+		encoder:     json.NewEncoder(w),
+		msg:         &bytes.Buffer{},
+		msgReader:   &bytes.Reader{},
+		transcoders: make(map[uint16]*ros1msg.JSONTranscoder),
+		descriptors: make(map[uint16]protoreflect.MessageDescriptor),
+	}
+}
+
+// reset forgets the schemas seen so far, before messages are written from
+// another MCAP stream, whose schema IDs may differ.
+func (j *jsonWriter) reset() {
+	clear(j.transcoders)
+	clear(j.descriptors)
+}
+
+func (j *jsonWriter) writeMessage(schema *mcap.Schema, channel *mcap.Channel, message *mcap.Message) error {
+	if schema == nil {
+		return fmt.Errorf("JSON output only supported for ros1msg and protobuf schemas")
+	}
+	switch schema.Encoding {
+	case "ros1msg":
+		transcoder, ok := j.transcoders[channel.SchemaID]
+		if !ok {
+			packageName := strings.Split(schema.Name, "/")[0]
+			var err error
+			transcoder, err = ros1msg.NewJSONTranscoder(packageName, schema.Data)
+			if err != nil {
+				return fmt.Errorf("failed to build transcoder for %s: %w", channel.Topic, err)
+			}
+			j.transcoders[channel.SchemaID] = transcoder
+		}
+		j.msgReader.Reset(message.Data)
+		err := transcoder.Transcode(j.msg, j.msgReader)
+		if err != nil {
+			return fmt.Errorf("failed to transcode %s record on %s: %w", schema.Name, channel.Topic, err)
+		}
+	case "protobuf":
+		messageDescriptor, ok := j.descriptors[channel.SchemaID]
+		if !ok {
+			fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+			if err := proto.Unmarshal(schema.Data, fileDescriptorSet); err != nil {
+				return fmt.Errorf("failed to build file descriptor set: %w", err)
+			}
+			files, err := protodesc.FileOptions{}.NewFiles(fileDescriptorSet)
+			if err != nil {
+				return fmt.Errorf("failed to create file descriptor: %w", err)
+			}
+			descriptor, err := files.FindDescriptorByName(protoreflect.FullName(schema.Name))
+			if err != nil {
+				return fmt.Errorf("failed to find descriptor: %w", err)
+			}
+			messageDescriptor = descriptor.(protoreflect.MessageDescriptor)
+			j.descriptors[channel.SchemaID] = messageDescriptor
+		}
+		protoMsg := dynamicpb.NewMessage(messageDescriptor)
+		if err := proto.Unmarshal(message.Data, protoMsg); err != nil {
+			return fmt.Errorf("failed to parse message: %w", err)
+		}
+		bytes, err := protojson.Marshal(protoMsg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		if _, err = j.msg.Write(bytes); err != nil {
+			return fmt.Errorf("failed to write message bytes: %w", err)
+		}
+	default:
+		return fmt.Errorf("JSON output only supported for ros1msg and protobuf schemas")
+	}
+	j.target.Topic = channel.Topic
+	j.target.Sequence = message.Sequence
+	j.target.LogTime = DecimalTime(message.LogTime)
+	j.target.PublishTime = DecimalTime(message.PublishTime)
+	j.target.Data = j.msg.Bytes()
+	err := j.encoder.Encode(j.target)
+	if err != nil {
+		return fmt.Errorf("failed to write encoded message")
+	}
+	j.msg.Reset()
+	return nil
+}
+
 func mcap2JSON(
 	w io.Writer,
 	r io.Reader,
 ) error {
-	msg := &bytes.Buffer{}
-	msgReader := &bytes.Reader{}
 	buf := make([]byte, 1024*1024)
-	transcoders := make(map[uint16]*ros1msg.JSONTranscoder)
-	descriptors := make(map[uint16]protoreflect.MessageDescriptor)
-	encoder := json.NewEncoder(w)
+	writer := newJSONWriter(w)
 	reader, err := mcap.NewReader(r)
 	if err != nil {
 		return fmt.Errorf("failed to create reader: %w", err)
@@ -101,73 +196,17 @@ func mcap2JSON(
 	if err != nil {
 		return fmt.Errorf("failed to build reader: %w", err)
 	}
-	target := Message{}
 	for {
 		schema, channel, message, err := it.Next(buf)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			log.Fatalf("Failed to read next message: %s", err)
+			return fmt.Errorf("failed to read next message: %w", err)
 		}
-		switch schema.Encoding {
-		case "ros1msg":
-			transcoder, ok := transcoders[channel.SchemaID]
-			if !ok {
-				packageName := strings.Split(schema.Name, "/")[0]
-				transcoder, err = ros1msg.NewJSONTranscoder(packageName, schema.Data)
-				if err != nil {
-					return fmt.Errorf("failed to build transcoder for %s: %w", channel.Topic, err)
-				}
-				transcoders[channel.SchemaID] = transcoder
-			}
-			msgReader.Reset(message.Data)
-			err = transcoder.Transcode(msg, msgReader)
-			if err != nil {
-				return fmt.Errorf("failed to transcode %s record on %s: %w", schema.Name, channel.Topic, err)
-			}
-		case "protobuf":
-			messageDescriptor, ok := descriptors[channel.SchemaID]
-			if !ok {
-				fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
-				if err := proto.Unmarshal(schema.Data, fileDescriptorSet); err != nil {
-					return fmt.Errorf("failed to build file descriptor set: %w", err)
-				}
-				files, err := protodesc.FileOptions{}.NewFiles(fileDescriptorSet)
-				if err != nil {
-					return fmt.Errorf("failed to create file descriptor: %w", err)
-				}
-				descriptor, err := files.FindDescriptorByName(protoreflect.FullName(schema.Name))
-				if err != nil {
-					return fmt.Errorf("failed to find descriptor: %w", err)
-				}
-				messageDescriptor = descriptor.(protoreflect.MessageDescriptor)
-				descriptors[channel.SchemaID] = messageDescriptor
-			}
-			protoMsg := dynamicpb.NewMessage(messageDescriptor)
-			if err := proto.Unmarshal(message.Data, protoMsg); err != nil {
-				return fmt.Errorf("failed to parse message: %w", err)
-			}
-			bytes, err := protojson.Marshal(protoMsg)
-			if err != nil {
-				return fmt.Errorf("failed to marshal message: %w", err)
-			}
-			if _, err = msg.Write(bytes); err != nil {
-				return fmt.Errorf("failed to write message bytes: %w", err)
-			}
-		default:
-			return fmt.Errorf("JSON output only supported for ros1msg and protobuf schemas")
-		}
-		target.Topic = channel.Topic
-		target.Sequence = message.Sequence
-		target.LogTime = DecimalTime(message.LogTime)
-		target.PublishTime = DecimalTime(message.PublishTime)
-		target.Data = msg.Bytes()
-		err = encoder.Encode(target)
-		if err != nil {
-			return fmt.Errorf("failed to write encoded message")
+		if err := writer.writeMessage(schema, channel, message); err != nil {
+			return err
 		}
-		msg.Reset()
 	}
 	return nil
 }
@@ -239,11 +278,11 @@ func reindexBagFile(w io.Writer, r io.Reader) error {
 // reindexMCAPFile rewrites an MCAP file to a new output location, and properly
 // closes it. If the input is corrupt, we simply close the output with what was
 // successfully read.
-func reindexMCAPFile(w io.Writer, r io.Reader) error {
+func reindexMCAPFile(w io.Writer, r io.Reader, compression mcap.CompressionFormat) error {
 	writer, err := mcap.NewWriter(w, &mcap.WriterOptions{
 		Chunked:     true,
 		ChunkSize:   1024 * 1024,
-		Compression: mcap.CompressionLZ4,
+		Compression: compression,
 	})
 	if err != nil {
 		return err
@@ -320,7 +359,7 @@ func reindexMCAPFile(w io.Writer, r io.Reader) error {
 
 // reindex a file, staging the reindexed output in tmpdir prior to moving it to
 // the final location (same as the input location) atomically.
-func reindex(tmpdir string, filename string, format string) (bool, *fileInfo, error) {
+func reindex(tmpdir string, filename string, format string, compression mcap.CompressionFormat) (bool, *fileInfo, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return false, nil, err
@@ -402,7 +441,7 @@ func reindex(tmpdir string, filename string, format string) (bool, *fileInfo, er
 		if err != nil {
 			return false, nil, fmt.Errorf("failed to create temporary reindex target: %w", err)
 		}
-		err = reindexMCAPFile(tmpfile, f)
+		err = reindexMCAPFile(tmpfile, f, compression)
 		if err != nil {
 			return false, nil, fmt.Errorf("failed to reindex: %w", err)
 		}
@@ -438,122 +477,465 @@ func reindex(tmpdir string, filename string, format string) (bool, *fileInfo, er
 	}
 }
 
+// exportOptions control how doExport stages and writes its output.
+type exportOptions struct {
+	// tmpdir holds partial downloads until they are combined.
+	tmpdir string
+	// compression is used for MCAP files the CLI writes.
+	compression mcap.CompressionFormat
+	// recompress rewrites a complete MCAP download with compression, rather
+	// than keeping it as received.
+	recompress bool
+	// parallel, if greater than one, is the number of time windows
+	// downloaded concurrently.
+	parallel int
+}
+
+// parseCompression returns the MCAP compression named by the
+// export-compression setting. An empty name selects LZ4 without
+// recompressing complete downloads.
+func parseCompression(name string) (compression mcap.CompressionFormat, recompress bool, err error) {
+	switch name {
+	case "":
+		return mcap.CompressionLZ4, false, nil
+	case "lz4":
+		return mcap.CompressionLZ4, true, nil
+	case "zstd":
+		return mcap.CompressionZSTD, true, nil
+	case "none":
+		return mcap.CompressionNone, true, nil
+	default:
+		return "", false, fmt.Errorf("unrecognized compression: %s", name)
+	}
+}
+
 type partialFile struct {
 	name string
 	rs   io.ReadSeeker
 	info *fileInfo
 }
 
-func doExport(
-	ctx context.Context,
-	outputfile string,
-	baseURL string,
-	clientID string,
-	bearerToken string,
-	userAgent string,
-	request *api.StreamRequest,
-) error {
-	tmpdir, err := os.MkdirTemp(".", "export")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary output directory: %w", err)
+// messageKey identifies a message among those logged at the same time.
+type messageKey struct {
+	topic    string
+	sequence uint32
+	hash     uint64
+}
+
+// stitcher drops the messages a partial file repeats from the files before it.
+// Each follow-up request starts at the log time of the last message received,
+// so consecutive files overlap in the messages logged at that time. These are
+// matched by topic, sequence and a hash of their content, so that messages
+// sharing a timestamp are neither lost nor duplicated.
+type stitcher struct {
+	seed maphash.Seed
+	// boundary is the latest log time written before the current file.
+	boundary uint64
+	// repeats counts the messages written at the boundary that the current
+	// file has yet to repeat.
+	repeats map[messageKey]int
+	// last is the latest log time written, and written counts the messages
+	// written at that time.
+	last    uint64
+	written map[messageKey]int
+}
+
+func newStitcher() *stitcher {
+	return &stitcher{
+		seed:    maphash.MakeSeed(),
+		repeats: make(map[messageKey]int),
+		written: make(map[messageKey]int),
 	}
-	defer os.RemoveAll(tmpdir)
-	zeroMessageDownloadCount := 0
-	repeatRequestCount := 0
-	tmpfiles := []partialFile{}
-	for {
-		tmpfile, err := os.CreateTemp(tmpdir, "export")
-		if err != nil {
-			return err
-		}
-		defer tmpfile.Close()
-		debugf("exporting to %s", tmpfile.Name())
-		err = executeExport(ctx, tmpfile, baseURL, clientID, bearerToken, userAgent, request)
-		if err != nil {
-			fmt.Println("error executing export: ", err)
+}
+
+// nextFile starts the next partial file.
+func (s *stitcher) nextFile() {
+	s.boundary = s.last
+	s.repeats = maps.Clone(s.written)
+}
+
+// keep reports whether a message of the current file is new, rather than a
+// repeat of one already written.
+func (s *stitcher) keep(topic string, logTime uint64, sequence uint32, data []byte) bool {
+	if logTime < s.boundary {
+		return false
+	}
+	key := messageKey{topic: topic, sequence: sequence, hash: maphash.Bytes(s.seed, data)}
+	if logTime == s.boundary && s.repeats[key] > 0 {
+		s.repeats[key]--
+		return false
+	}
+	if logTime > s.last {
+		s.last = logTime
+		clear(s.written)
+	}
+	if logTime == s.last {
+		s.written[key]++
+	}
+	return true
+}
+
+// idMap gives the schemas, channels or connections of partial files their
+// IDs in the combined output. Records with the same key share one ID, which is
+// the ID they first had unless another record took it.
+type idMap[K comparable] struct {
+	ids   map[K]uint32
+	used  map[uint32]bool
+	first uint32
+}
+
+// newIDMap returns an idMap that allocates IDs from first.
+func newIDMap[K comparable](first uint32) *idMap[K] {
+	return &idMap[K]{
+		ids:   make(map[K]uint32),
+		used:  make(map[uint32]bool),
+		first: first,
+	}
+}
+
+// assign returns the output ID of a record with the given key and ID in its
+// partial file, and whether the record is new to the output.
+func (m *idMap[K]) assign(key K, id uint32) (uint32, bool) {
+	if out, ok := m.ids[key]; ok {
+		return out, false
+	}
+	if id < m.first || m.used[id] {
+		id = m.first
+		for m.used[id] {
+			id++
 		}
-		didReindex, info, err := reindex(tmpdir, tmpfile.Name(), request.OutputFormat)
-		if err != nil {
-			return fmt.Errorf("failed to reindex tmpfile %s: %w", tmpfile.Name(), err)
+	}
+	m.ids[key] = id
+	m.used[id] = true
+	return id, true
+}
+
+type schemaKey struct {
+	name     string
+	encoding string
+	data     string
+}
+
+type channelKey struct {
+	topic           string
+	messageEncoding string
+	schemaID        uint16
+	metadata        string
+}
+
+type connectionKey struct {
+	topic  string
+	typ    string
+	md5sum string
+}
+
+// metadataKey returns a string that is equal for equal metadata maps.
+func metadataKey(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%q=%q;", k, metadata[k])
+	}
+	return b.String()
+}
+
+func closePartialFiles(tmpfiles []partialFile) {
+	for _, tmpfile := range tmpfiles {
+		if closer, ok := tmpfile.rs.(io.Closer); ok {
+			closer.Close()
 		}
-		debugf("output %s was complete: %t. Message count %d. Max time %d", tmpfile.Name(), !didReindex, info.messageCount, info.maxTime)
+	}
+}
 
-		// add tmpfile name to structure, with the biggest timestamp to scan _through_
-		tmpfiles = append(tmpfiles, partialFile{tmpfile.Name(), tmpfile, info})
-		if !didReindex {
-			// if we did not need to do any reindexing, the file was already
-			// complete. That means quit looping. This can only happen on an
-			// MCAP export, since MCAP files include the closing magic as an
-			// indicator that the file was closed. Since bag files could get
-			// truncated on a message boundary, we have no way of distinguishing
-			// a complete file from a truncated one. For bags, we always need to
-			// make a followup request.
-			break
+// downloadFormat returns the format in which an export to format is
+// downloaded. JSON is converted from MCAP as it is written.
+func downloadFormat(format string) string {
+	if format == "json" {
+		return "mcap0"
+	}
+	return format
+}
+
+// resumeState decides how a download that was cut short is continued.
+type resumeState struct {
+	ZeroMessageDownloads int    `json:"zeroMessageDownloads"`
+	RepeatRequests       int    `json:"repeatRequests"`
+	SameStartCount       uint64 `json:"sameStartCount"`
+}
+
+// advance moves request on past a download that was cut short, having
+// received the messages described by info. It reports false if the download
+// should be taken as complete instead.
+func (s *resumeState) advance(request *api.StreamRequest, info *fileInfo) bool {
+	// If the message count we got is zero, bail here as well. Since bags
+	// don't contain closing magic, there is no way of distinguishing a
+	// legitimately empty file from one that is truncated with no records. To
+	// account for this, we will bail if we get two successive results with
+	// zero messages included.
+	if info.messageCount == 0 {
+		s.ZeroMessageDownloads++
+		if s.ZeroMessageDownloads > 1 {
+			debugf("got two successive empty downloads. Assuming EOF.")
+			return false
 		}
 
-		// If we reindexed but the message count we got is zero, bail here as well.
-		// Since bags don't contain closing magic, there is no way of
-		// distinguishing a legitimately empty file from one that is truncated
-		// with no records. To account for this, we will bail if we get two
-		// successive results with zero messages included.
-		if info.messageCount == 0 {
-			zeroMessageDownloadCount++
-			if zeroMessageDownloadCount > 1 {
-				debugf("got two successive empty downloads. Assuming EOF.")
-				break
-			}
+		// if the message count for the last export is zero, we need to redo
+		// it with the same parameters.
+		return true
+	}
+	// otherwise, we need to do another request, starting at the end of the
+	// just-completed fetch. The merging process will deal with the overlaps.
 
-			// if the message count for the last export is zero, we need to redo
-			// it with the same parameters.
-			continue
+	// otherwise, do another request. Since we're here we got a nonempty
+	// resultset, so set the empty download count back to zero.
+	s.ZeroMessageDownloads = 0
+
+	// the start time of the new request will be the max time from the
+	// request just received. Specifically, the timestamp of the last message
+	// written to the output file. By starting the request with this time, we
+	// will end up with some messages duplicated between the end of the
+	// previous file and the start of the next one. The merging process at the
+	// end drops these duplicates by message identity.
+	newStart := time.Unix(int64(info.maxTime)/1e9, int64(info.maxTime)%1e9)
+
+	// It is possible that the previous request contained a set of messagges
+	// on the same timestamp, right at the end of the response. In this
+	// instance, the next start time is the same as the previous start time,
+	// and the response holds only messages logged then. Keep requesting as
+	// long as each response gets more of them than the last, and bail if two
+	// in a row make no progress.
+	if request.Start != nil && newStart.Equal(*request.Start) {
+		if info.messageCount <= s.SameStartCount {
+			s.RepeatRequests++
+		} else {
+			s.RepeatRequests = 0
 		}
-		// otherwise, we need to do another request, starting at the end of the
-		// just-completed fetch. The merging process will deal with the overlaps.
+		s.SameStartCount = info.messageCount
+	} else {
+		s.RepeatRequests = 0
+		s.SameStartCount = 0
+	}
+	if s.RepeatRequests > 1 {
+		debugf("got two successive requests with the same start time and no new messages. Assuming EOF.")
+		return false
+	}
+
+	request.Start = &newStart
+	if request.End == nil {
+		end := time.Now()
+		request.End = &end
+	}
+	return true
+}
 
-		// otherwise, do another request. Since we're here we got a nonempty
-		// resultset, so set the empty download count back to zero.
-		zeroMessageDownloadCount = 0
+// exportFunc downloads the data selected by a request to w.
+type exportFunc func(ctx context.Context, w io.Writer, request *api.StreamRequest) error
 
-		// the start time of the new request will be the max time from the
-		// request just received. Specifically, the timestamp of the last
-		// message written to the output file. By starting the request with this
-		// time, we will end up with some messages duplicated between the end of
-		// the previous file and the start of the next one. The merging process
-		// at the end will be in charge of accounting for these duplicates.
-		newStart := time.Unix(int64(info.maxTime)/1e9, int64(info.maxTime)%1e9)
+// downloadWindow downloads the data selected by a window of an export job into
+// files in the job directory. If a download is cut short, the rest is
+// requested from the last timestamp received, until the data is complete. Each
+// file is recorded in the job once received, so that a later run continues
+// after it. The files are returned in time order, and must be closed by the
+// caller.
+func downloadWindow(
+	ctx context.Context,
+	job *exportJob,
+	window *jobWindow,
+	compression mcap.CompressionFormat,
+	export exportFunc,
+) (_ []partialFile, err error) {
+	tmpfiles := []partialFile{}
+	defer func() {
+		if err != nil {
+			closePartialFiles(tmpfiles)
+		}
+	}()
+	// Only this call changes the window, so it is read without the job lock.
+	for _, part := range window.Parts {
+		f, err := os.Open(job.path(part.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to reopen partial download: %w", err)
+		}
+		tmpfiles = append(tmpfiles, partialFile{
+			name: f.Name(),
+			rs:   f,
+			info: &fileInfo{maxTime: part.MaxTime, messageCount: part.MessageCount},
+		})
+	}
+	if window.Done {
+		return tmpfiles, nil
+	}
+	request := window.Request
+	state := window.resumeState
+	pending := window.Pending
 
-		if request.Start != nil && newStart == *request.Start {
-			repeatRequestCount++
+	// record saves a received file and the state of the window in the job.
+	record := func(name string, info *fileInfo, done bool) error {
+		return job.update(func() {
+			window.Parts = append(window.Parts, jobPart{Name: name, MaxTime: info.maxTime, MessageCount: info.messageCount})
+			window.Pending = ""
+			window.Request = request
+			window.Done = done
+			window.resumeState = state
+		})
+	}
+	for {
+		// Bail out before issuing another request if the user has interrupted
+		// the export or the command deadline has passed. The job keeps the
+		// partial files written so far.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var tmpfile *os.File
+		resumed := pending != ""
+		if resumed {
+			// An earlier run stopped partway through this download. Keep
+			// what it received, as if the download had been cut short.
+			debugf("continuing from partial download %s", pending)
+			tmpfile, err = os.OpenFile(job.path(pending), os.O_RDWR, 0)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			pending = ""
+		}
+		if tmpfile != nil {
+			// The file is closed by the caller, once it has been combined.
+			tmpfiles = append(tmpfiles, partialFile{name: tmpfile.Name(), rs: tmpfile})
 		} else {
-			repeatRequestCount = 0
+			tmpfile, err = os.CreateTemp(job.dir, "export")
+			if err != nil {
+				return nil, err
+			}
+			tmpfiles = append(tmpfiles, partialFile{name: tmpfile.Name(), rs: tmpfile})
+			name := filepath.Base(tmpfile.Name())
+			if err := job.update(func() { window.Pending = name }); err != nil {
+				return nil, err
+			}
+			debugf("exporting to %s", tmpfile.Name())
+			// Stream resumes an interrupted download at the byte offset
+			// reached where the signed link allows it. An error here means
+			// that wasn't possible, so fall back to a new request from the
+			// last timestamp received.
+			err = export(ctx, tmpfile, &request)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				fmt.Fprintf(os.Stderr, "error executing export: %s\n", err)
+			}
+		}
+		didReindex, info, err := reindex(job.dir, tmpfile.Name(), request.OutputFormat, compression)
+		if err != nil && resumed {
+			// The earlier run received too little to keep, so download
+			// it again.
+			debugf("discarding partial download %s: %s", tmpfile.Name(), err)
+			tmpfile.Close()
+			os.Remove(tmpfile.Name())
+			tmpfiles = tmpfiles[:len(tmpfiles)-1]
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to reindex tmpfile %s: %w", tmpfile.Name(), err)
 		}
+		debugf("output %s was complete: %t. Message count %d. Max time %d", tmpfile.Name(), !didReindex, info.messageCount, info.maxTime)
 
-		// It is possible that the previous request contained a set of messagges
-		// on the same timestamp, right at the end of the response. In this
-		// instance, the next start time is the same as the previous start time.
-		// We can end up in a loop repeatedly writing the same messages out to
-		// partial files, and rerequesting the same data. To detect this we bail
-		// if we have requested the same data more than twice.
-		if repeatRequestCount > 1 {
-			debugf("got two successive requests with the same start time. Assuming EOF.")
+		tmpfiles[len(tmpfiles)-1].info = info
+		if didReindex {
+			// The reindexed file replaced the one written, so read it afresh.
+			tmpfile.Close()
+			reindexed, err := os.Open(tmpfile.Name())
+			if err != nil {
+				return nil, err
+			}
+			tmpfiles[len(tmpfiles)-1].rs = reindexed
+		}
+		// if we did not need to do any reindexing, the file was already
+		// complete. That means quit looping. This can only happen on an MCAP
+		// export, since MCAP files include the closing magic as an indicator
+		// that the file was closed. Since bag files could get truncated on a
+		// message boundary, we have no way of distinguishing a complete file
+		// from a truncated one. For bags, we always need to make a followup
+		// request.
+		done := !didReindex || !state.advance(&request, info)
+		if err := record(filepath.Base(tmpfile.Name()), info, done); err != nil {
+			return nil, err
+		}
+		if done {
 			break
 		}
+	}
+
+	return tmpfiles, nil
+}
 
-		request.Start = &newStart
-		if request.End == nil {
-			end := time.Now()
-			request.End = &end
+// doExport downloads the data selected by request to outputfile. Its progress
+// is kept in an export job until the output is written, so that an export
+// that stops partway is continued by the next run of the same export.
+func doExport(
+	ctx context.Context,
+	outputfile string,
+	baseURL string,
+	clientID string,
+	bearerToken string,
+	userAgent string,
+	request *api.StreamRequest,
+	opts exportOptions,
+) error {
+	job, resumed, err := openExportJob(outputfile, request, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open export job: %w", err)
+	}
+	if resumed {
+		fmt.Fprintf(os.Stderr, "Resuming export job %s\n", job.ID)
+	}
+	stopKeepAlive := job.keepAlive()
+	defer stopKeepAlive()
+	var tmpfiles []partialFile
+	if opts.parallel > 1 {
+		tmpfiles, err = downloadWindows(ctx, job, baseURL, clientID, bearerToken, userAgent, opts)
+	} else {
+		tmpfiles, err = downloadWindow(ctx, job, job.Windows[0], opts.compression, func(ctx context.Context, w io.Writer, request *api.StreamRequest) error {
+			return executeExport(ctx, w, baseURL, clientID, bearerToken, userAgent, request)
+		})
+	}
+	if err != nil {
+		stopKeepAlive()
+		if job.downloaded() {
+			fmt.Fprintf(os.Stderr, "\nExport job %s keeps the data downloaded so far. Run the same command again, or 'foxglove data export --resume %s', to continue.\n", job.ID, job.dir)
+		} else {
+			job.remove()
 		}
+		return err
 	}
+	if request.OutputFormat == "json" {
+		err = writeJSONOutput(outputfile, job.dir, tmpfiles)
+	} else {
+		err = writeExportOutput(outputfile, request.OutputFormat, tmpfiles, opts)
+	}
+	closePartialFiles(tmpfiles)
+	if err != nil {
+		return err
+	}
+	stopKeepAlive()
+	if err := job.remove(); err != nil {
+		debugf("failed to remove export job %s: %s", job.ID, err)
+	}
+	return nil
+}
 
-	// Now we need to combine the messages from the tmpfiles, handling the
-	// overlaps between them.
-
+// writeExportOutput combines the partial files of an export into outputfile,
+// handling the overlaps between them.
+func writeExportOutput(outputfile string, format string, tmpfiles []partialFile, opts exportOptions) error {
 	// If we have just one file, execute a mv. This will be the typical case
-	// when there is no failure.
-	if len(tmpfiles) == 1 {
+	// when there is no failure. If the MCAP output must be recompressed, the
+	// single file is combined like several would be.
+	if len(tmpfiles) == 1 && !(opts.recompress && format == "mcap0") {
 		debugf("single tmpfile - executing a rename")
-		err := os.Rename(tmpfiles[0].name, outputfile)
+		err := moveFile(tmpfiles[0].name, outputfile)
 		if err != nil {
 			return fmt.Errorf("failed to rename tmpfile: %w", err)
 		}
@@ -567,14 +949,90 @@ func doExport(
 	}
 	defer output.Close()
 
-	switch request.OutputFormat {
+	switch format {
 	case "bag1":
-		return combineBagTmpFiles(output, tmpfiles)
+		err = combineBagTmpFiles(output, tmpfiles)
 	case "mcap0":
-		return combineMCAPTmpFiles(output, tmpfiles)
+		err = combineMCAPTmpFiles(output, tmpfiles, opts.compression)
 	default:
-		return fmt.Errorf("unsupported format for resilient download: %s", request.OutputFormat)
+		err = fmt.Errorf("unsupported format for resilient download: %s", format)
+	}
+	if err != nil {
+		// don't leave a half-written output file behind
+		output.Close()
+		os.Remove(outputfile)
+		return err
+	}
+	return nil
+}
+
+// moveFile renames src to dst. If they are on different filesystems, as when
+// the export tmpdir is on another volume, src is copied and then removed.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	debugf("%s is on another filesystem - copying", dst)
+	return copyFile(src, dst)
+}
+
+// copyFile copies src to dst and removes src. A partially written dst is
+// removed on failure.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
 	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// writeJSONOutput combines the MCAP partial files of a JSON export, staging
+// the result in tmpdir, and converts it to JSON in outputfile.
+func writeJSONOutput(outputfile string, tmpdir string, tmpfiles []partialFile) error {
+	combined, err := os.CreateTemp(tmpdir, "combined")
+	if err != nil {
+		return fmt.Errorf("failed to create combined tmpfile: %w", err)
+	}
+	defer combined.Close()
+	if err := combineMCAPTmpFiles(combined, tmpfiles, mcap.CompressionNone); err != nil {
+		return err
+	}
+	if _, err := combined.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	output, err := os.Create(outputfile)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	w := bufio.NewWriter(output)
+	err = mcap2JSON(w, combined)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// don't leave a half-written output file behind
+		output.Close()
+		os.Remove(outputfile)
+		return fmt.Errorf("JSON conversion error: %w", err)
+	}
+	return output.Close()
 }
 
 func combineBagTmpFiles(w io.Writer, tmpfiles []partialFile) error {
@@ -583,9 +1041,11 @@ func combineBagTmpFiles(w io.Writer, tmpfiles []partialFile) error {
 	if err != nil {
 		return fmt.Errorf("failed to construct output writer: %w", err)
 	}
+	// Connections are matched across the files by topic, type and md5sum.
+	connections := newIDMap[connectionKey](0)
 	connectionsWritten := make(map[uint32]bool)
-	var connIDIncrement, maxObservedConn uint32
-	for i, tmpfile := range tmpfiles {
+	stitcher := newStitcher()
+	for _, tmpfile := range tmpfiles {
 		if tmpfile.info.messageCount == 0 {
 			debugf("omitting empty partial file %s", tmpfile.name)
 			continue
@@ -594,17 +1054,8 @@ func combineBagTmpFiles(w io.Writer, tmpfiles []partialFile) error {
 		if err != nil {
 			return err
 		}
-		var scanThrough uint64
-		if i == len(tmpfiles)-1 {
-			scanThrough = tmpfile.info.maxTime
-		} else {
-			if tmpfile.info.maxTime == 0 {
-				continue
-			}
-			scanThrough = tmpfile.info.maxTime - 1
-		}
-		connIDIncrement = maxObservedConn + 1
-		debugf("combining %s with connID increment %d and maxObservedConn %d", tmpfile.name, connIDIncrement, maxObservedConn)
+		stitcher.nextFile()
+		debugf("combining %s", tmpfile.name)
 
 		reader, err := rosbag.NewReader(tmpfile.rs)
 		if err != nil {
@@ -621,24 +1072,24 @@ func combineBagTmpFiles(w io.Writer, tmpfiles []partialFile) error {
 				return fmt.Errorf("failed to read message: %w", err)
 			}
 
-			if msg.Time > scanThrough {
-				break
-			}
-
-			conn.Conn += connIDIncrement
-			if conn.Conn > maxObservedConn {
-				maxObservedConn = conn.Conn
+			if !stitcher.keep(conn.Topic, msg.Time, 0, msg.Data) {
+				continue
 			}
 
-			if !connectionsWritten[conn.Conn] {
-				err = writer.WriteConnection(conn)
+			connID, _ := connections.assign(connectionKey{conn.Topic, conn.Data.Type, conn.Data.MD5Sum}, conn.Conn)
+			if !connectionsWritten[connID] {
+				// The iterator shares each connection between its messages,
+				// so renumber a copy.
+				outConn := *conn
+				outConn.Conn = connID
+				err = writer.WriteConnection(&outConn)
 				if err != nil {
 					return fmt.Errorf("failed to write channel: %w", err)
 				}
-				connectionsWritten[conn.Conn] = true
+				connectionsWritten[connID] = true
 			}
 
-			msg.Conn += connIDIncrement
+			msg.Conn = connID
 			err = writer.WriteMessage(msg)
 			if err != nil {
 				return fmt.Errorf("failed to write message: %w", err)
@@ -648,22 +1099,92 @@ func combineBagTmpFiles(w io.Writer, tmpfiles []partialFile) error {
 	return writer.Close()
 }
 
-func combineMCAPTmpFiles(w io.Writer, tmpfiles []partialFile) error {
+// mcapCombiner writes the records of consecutive MCAP streams to one MCAP
+// output. Schemas are matched across the streams by their name, encoding and
+// content, and channels by topic, message encoding, schema and metadata.
+type mcapCombiner struct {
+	writer   *mcap.Writer
+	schemas  *idMap[schemaKey]
+	channels *idMap[channelKey]
+	// the output IDs of the current stream's schemas and channels
+	schemaIDs  map[uint16]uint16
+	channelIDs map[uint16]uint16
+}
+
+func newMCAPCombiner(w io.Writer, compression mcap.CompressionFormat) (*mcapCombiner, error) {
 	writer, err := mcap.NewWriter(w, &mcap.WriterOptions{
 		Chunked:     true,
 		ChunkSize:   4 * 1024 * 1024,
-		Compression: mcap.CompressionLZ4,
+		Compression: compression,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to construct output writer: %w", err)
+		return nil, fmt.Errorf("failed to construct output writer: %w", err)
 	}
-
 	if err := writer.WriteHeader(&mcap.Header{}); err != nil {
-		return fmt.Errorf("failed to write output header: %w", err)
+		return nil, fmt.Errorf("failed to write output header: %w", err)
+	}
+	return &mcapCombiner{
+		writer: writer,
+		// Schema ID zero means a channel has no schema.
+		schemas:    newIDMap[schemaKey](1),
+		channels:   newIDMap[channelKey](0),
+		schemaIDs:  make(map[uint16]uint16),
+		channelIDs: make(map[uint16]uint16),
+	}, nil
+}
+
+func (c *mcapCombiner) nextStream() {
+	clear(c.schemaIDs)
+	clear(c.channelIDs)
+}
+
+func (c *mcapCombiner) writeSchema(schema *mcap.Schema) error {
+	id, isNew := c.schemas.assign(schemaKey{schema.Name, schema.Encoding, string(schema.Data)}, uint32(schema.ID))
+	c.schemaIDs[schema.ID] = uint16(id)
+	if !isNew {
+		return nil
+	}
+	schema.ID = uint16(id)
+	return c.writer.WriteSchema(schema)
+}
+
+func (c *mcapCombiner) writeChannel(channel *mcap.Channel) error {
+	schemaID := c.schemaIDs[channel.SchemaID]
+	key := channelKey{channel.Topic, channel.MessageEncoding, schemaID, metadataKey(channel.Metadata)}
+	id, isNew := c.channels.assign(key, uint32(channel.ID))
+	c.channelIDs[channel.ID] = uint16(id)
+	if !isNew {
+		return nil
 	}
+	channel.ID = uint16(id)
+	channel.SchemaID = schemaID
+	return c.writer.WriteChannel(channel)
+}
+
+func (c *mcapCombiner) writeMessage(message *mcap.Message) error {
+	message.ChannelID = c.channelIDs[message.ChannelID]
+	return c.writer.WriteMessage(message)
+}
+
+func (c *mcapCombiner) writeMetadata(metadata *mcap.Metadata) error {
+	return c.writer.WriteMetadata(metadata)
+}
 
-	var schemaIDIncrement, channelIDIncrement, maxObservedSchema, maxObservedChannel uint16
-	for i, tmpfile := range tmpfiles {
+func (c *mcapCombiner) writeAttachment(attachment *mcap.Attachment) error {
+	return c.writer.WriteAttachment(attachment)
+}
+
+func (c *mcapCombiner) close() error {
+	return c.writer.Close()
+}
+
+func combineMCAPTmpFiles(w io.Writer, tmpfiles []partialFile, compression mcap.CompressionFormat) error {
+	combiner, err := newMCAPCombiner(w, compression)
+	if err != nil {
+		return err
+	}
+	stitcher := newStitcher()
+	for _, tmpfile := range tmpfiles {
 		if tmpfile.info.messageCount == 0 {
 			debugf("omitting empty partial file %s", tmpfile.name)
 			continue
@@ -672,94 +1193,11 @@ func combineMCAPTmpFiles(w io.Writer, tmpfiles []partialFile) error {
 		if err != nil {
 			return err
 		}
-		lexer, err := mcap.NewLexer(tmpfile.rs, &mcap.LexerOptions{
-			AttachmentCallback: func(ar *mcap.AttachmentReader) error {
-				return writer.WriteAttachment(&mcap.Attachment{
-					LogTime:    ar.LogTime,
-					CreateTime: ar.CreateTime,
-					Name:       ar.Name,
-					MediaType:  ar.MediaType,
-					DataSize:   ar.DataSize,
-					Data:       ar.Data(),
-				})
-			},
-		})
-		if err != nil {
-			return fmt.Errorf("failed to construct lexer: %w", err)
-		}
-		var scanThrough uint64
-		if i == len(tmpfiles)-1 {
-			scanThrough = tmpfile.info.maxTime
-		} else {
-			if tmpfile.info.maxTime == 0 {
-				continue
-			}
-			scanThrough = tmpfile.info.maxTime - 1
-		}
-		schemaIDIncrement = maxObservedSchema
-		channelIDIncrement = maxObservedChannel + 1
-	Top:
-		for {
-			tokenType, token, err := lexer.Next(nil)
-			if err != nil {
-				return fmt.Errorf("failed to read message: %w", err)
-			}
-			switch tokenType {
-			case mcap.TokenMessage:
-				message, err := mcap.ParseMessage(token)
-				if err != nil {
-					return fmt.Errorf("failed to parse message: %w", err)
-				}
-				if message.LogTime > scanThrough {
-					break Top
-				}
-				message.ChannelID += channelIDIncrement
-				err = writer.WriteMessage(message)
-				if err != nil {
-					return fmt.Errorf("failed to write message: %w", err)
-				}
-			case mcap.TokenChannel:
-				channel, err := mcap.ParseChannel(token)
-				if err != nil {
-					return fmt.Errorf("failed to parse channel: %w", err)
-				}
-				channel.ID += channelIDIncrement
-				channel.SchemaID += schemaIDIncrement
-				if channel.ID > maxObservedChannel {
-					maxObservedChannel = channel.ID
-				}
-				err = writer.WriteChannel(channel)
-				if err != nil {
-					return fmt.Errorf("failed to write channel: %w", err)
-				}
-			case mcap.TokenSchema:
-				schema, err := mcap.ParseSchema(token)
-				if err != nil {
-					return fmt.Errorf("failed to parse schema: %w", err)
-				}
-				schema.ID += schemaIDIncrement
-				if schema.ID > maxObservedSchema {
-					maxObservedSchema = schema.ID
-				}
-				err = writer.WriteSchema(schema)
-				if err != nil {
-					return fmt.Errorf("failed to write schema: %w", err)
-				}
-			case mcap.TokenMetadata:
-				metadata, err := mcap.ParseMetadata(token)
-				if err != nil {
-					return fmt.Errorf("failed to parse metadata: %w", err)
-				}
-				err = writer.WriteMetadata(metadata)
-				if err != nil {
-					return fmt.Errorf("failed to write metadata: %w", err)
-				}
-			case mcap.TokenDataEnd:
-				break Top
-			}
+		if _, err := copyMCAPStream(tmpfile.rs, combiner, stitcher); err != nil {
+			return err
 		}
 	}
-	return writer.Close()
+	return combiner.close()
 }
 
 func executeExport(
@@ -775,12 +1213,15 @@ func executeExport(
 	if !validOutputFormat(request.OutputFormat) {
 		return ErrInvalidFormat
 	}
-	client := api.NewRemoteFoxgloveClient(
+	client := newClient(
 		baseURL,
 		clientID,
 		bearerToken,
 		userAgent,
 	)
+	// The device is resolved here rather than when the request is built, so
+	// that export jobs stay keyed on the request as given.
+	request.DeviceID, request.DeviceName = resolveDeviceName(ctx, client, request.ProjectID, request.DeviceID, request.DeviceName)
 	writer := w
 	if stdoutRedirected() {
 		progressWriter := progressbar.DefaultBytes(-1, "exporting")
@@ -793,26 +1234,22 @@ func executeExport(
 			return fmt.Errorf("failed to create pipe: %w", err)
 		}
 		errs := make(chan error, 1)
-		done := make(chan bool, 1)
 		go func() {
-			err = api.Export(ctx, pipeWriter, client, request)
-			if err != nil {
-				errs <- err
-				return
-			}
-			done <- true
-			pipeWriter.Close()
+			// Closing the write end unblocks the converter if the export fails
+			// or is cancelled partway through.
+			defer pipeWriter.Close()
+			errs <- api.Export(ctx, pipeWriter, client, request)
 		}()
 		err = mcap2JSON(writer, pipeReader)
+		// Closing the read end unblocks the export if conversion fails.
+		pipeReader.Close()
+		if exportErr := <-errs; exportErr != nil {
+			return exportErr
+		}
 		if err != nil {
 			return fmt.Errorf("JSON conversion error: %w", err)
 		}
-		select {
-		case <-done:
-			return nil
-		case err := <-errs:
-			return err
-		}
+		return nil
 	} else {
 		return api.Export(ctx, writer, client, request)
 	}
@@ -887,11 +1324,43 @@ func newExportCommand(params *baseParams) (*cobra.Command, error) {
 	var sessionID string
 	var sessionKey string
 	var projectID string
+	var compression string
+	var tmpdir string
+	var recipe string
+	var parallel int
+	var resume string
+	var resumable bool
 	exportCmd := &cobra.Command{
 		Use:   "export",
 		Short: "Export a data selection from Foxglove Data Platform",
 		Long:  "Export a data selection from Foxglove Data Platform by Recording ID, Import ID, Session ID/Key, or Device and time range",
 		Run: func(cmd *cobra.Command, args []string) {
+			if resume != "" {
+				job, err := findExportJob(defaultString(tmpdir, "."), resume)
+				if err != nil {
+					exitf(exitUsage, "%s", err)
+				}
+				err = doExport(
+					cmd.Context(),
+					job.Output,
+					params.baseURL,
+					*params.clientID,
+					params.token,
+					params.userAgent,
+					&job.Request,
+					job.options(),
+				)
+				if err != nil {
+					dief("Export failed: %s", err)
+				}
+				fmt.Fprint(os.Stderr, "\n")
+				return
+			}
+			if recipe != "" {
+				if err := applyExportRecipe(cmd, recipe); err != nil {
+					exitf(exitUsage, "%s", err)
+				}
+			}
 			startTime, err := maybeConvertToRFC3339(start)
 			if err != nil {
 				dief("failed to parse start time: %s", err)
@@ -921,9 +1390,20 @@ func newExportCommand(params *baseParams) (*cobra.Command, error) {
 				dief("Failed to build request: %s", err)
 			}
 
-			// If there is an output file and the output format is not JSON,
-			// export to that file with resumable downloads.
-			if outputFile != "" && outputFormat != "json" {
+			// If there is an output file, export to that file with resumable
+			// downloads.
+			if parallel > 1 && outputFile == "" {
+				exitf(exitUsage, "--parallel requires --output-file")
+			}
+			if resumable && outputFile == "" && outputFormat == "bag1" {
+				exitf(exitUsage, "--resumable output to stdout must be mcap0 or json")
+			}
+			opts := exportOptions{tmpdir: tmpdir, parallel: parallel}
+			opts.compression, opts.recompress, err = parseCompression(compression)
+			if err != nil {
+				exitf(exitUsage, "%s", err)
+			}
+			if outputFile != "" {
 				err = doExport(
 					cmd.Context(),
 					outputFile,
@@ -932,6 +1412,7 @@ func newExportCommand(params *baseParams) (*cobra.Command, error) {
 					params.token,
 					params.userAgent,
 					request,
+					opts,
 				)
 				if err != nil {
 					dief("Export failed: %s", err)
@@ -947,6 +1428,25 @@ func newExportCommand(params *baseParams) (*cobra.Command, error) {
 			}
 			defer os.Stdout.Close()
 
+			// JSON is converted as it is received, so it can resume where a
+			// download is cut short without changing the output. MCAP is only
+			// resumed when asked for, since it is then written anew.
+			if request.OutputFormat == "json" || resumable {
+				err = streamExport(
+					cmd.Context(),
+					os.Stdout,
+					*request,
+					opts.compression,
+					func(ctx context.Context, w io.Writer, request *api.StreamRequest) error {
+						return executeExport(ctx, w, params.baseURL, *params.clientID, params.token, params.userAgent, request)
+					},
+				)
+				if err != nil {
+					dief("Export failed: %s", err)
+				}
+				return
+			}
+
 			// Do the export, without resumable downloads.
 			err = executeExport(
 				cmd.Context(),
@@ -970,12 +1470,27 @@ func newExportCommand(params *baseParams) (*cobra.Command, error) {
 	exportCmd.PersistentFlags().StringVarP(&importID, "import-id", "", "", "import ID")
 	exportCmd.PersistentFlags().StringVarP(&start, "start", "", "", "start time (ISO8601 timestamp)")
 	exportCmd.PersistentFlags().StringVarP(&end, "end", "", "", "end time (ISO8601 timestamp")
-	exportCmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "", "mcap0", "output format (mcap0, bag1, or json)")
+	exportCmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "", defaultString(configSetting("export_format"), "mcap0"), "output format (mcap0, bag1, or json)")
+	exportCmd.PersistentFlags().StringVarP(&compression, "compression", "", configSetting("export_compression"), "compression of an MCAP --output-file (lz4, zstd, or none). By default, a complete download is kept as received")
+	exportCmd.PersistentFlags().StringVarP(&tmpdir, "tmpdir", "", configSetting("export_tmpdir"), "directory holding the export job, in which partial downloads are kept until the export completes (default: the directory of the output file)")
 	exportCmd.PersistentFlags().StringVarP(&topicList, "topics", "", "", "comma separated list of topics")
 	exportCmd.PersistentFlags().BoolVar(&isJsonOutput, "json", false, "alias for --output-format json")
 	exportCmd.PersistentFlags().StringVarP(&sessionID, "session-id", "", "", "session ID")
 	exportCmd.PersistentFlags().StringVarP(&sessionKey, "session-key", "", "", "Session key")
 	exportCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", "", "Project ID (required when using --session-key)")
+	exportCmd.PersistentFlags().IntVarP(&parallel, "parallel", "", 1, "number of time windows of an --output-file export to download concurrently")
+	exportCmd.PersistentFlags().StringVarP(&resume, "resume", "", "", "continue the export job with this ID or directory, as listed by 'data export jobs list'. An ID is found wherever the job is kept")
+	exportCmd.PersistentFlags().BoolVarP(&resumable, "resumable", "", false, "when writing MCAP to stdout, resume a download that is cut short, writing the file anew rather than as received. JSON output always resumes")
+	exportCmd.PersistentFlags().StringVarP(&recipe, "recipe", "", "", "apply the flags saved as this recipe under export_recipes in the config")
+	exportCmd.AddCommand(newExportJobsCommand())
 	AddDeviceAutocompletion(exportCmd, params)
+	if err := exportCmd.RegisterFlagCompletionFunc(
+		"recipe",
+		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return exportRecipeNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+	); err != nil {
+		return nil, err
+	}
 	return exportCmd, nil
 }
@@ -0,0 +1,580 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/mcap/go/mcap"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// exportJobPrefix starts the name of each export job directory.
+	exportJobPrefix = ".foxglove-export-"
+	// exportJobFile holds the state of a job within its directory.
+	exportJobFile = "job.json"
+	// exportJobHeartbeat is touched every heartbeatInterval while an export
+	// runs the job, so that `jobs clean` leaves the job alone.
+	exportJobHeartbeat = "heartbeat"
+	heartbeatInterval  = 10 * time.Second
+)
+
+// exportJob is the persisted state of an export to an output file. Its
+// directory holds the partial downloads and the state needed to continue the
+// export if the process stops, so that running the same command again, or
+// `data export --resume`, picks up where it left off.
+type exportJob struct {
+	ID          string                 `json:"id"`
+	Output      string                 `json:"output"`
+	Request     api.StreamRequest      `json:"request"`
+	Compression mcap.CompressionFormat `json:"compression"`
+	Recompress  bool                   `json:"recompress"`
+	Parallel    int                    `json:"parallel"`
+	// Windows are the time windows downloaded by the job; a single window
+	// unless the export is parallel. A parallel job has none until the
+	// time range of the export is known.
+	Windows   []*jobWindow `json:"windows"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+
+	dir string
+	// mtx guards the state while windows download concurrently.
+	mtx sync.Mutex
+}
+
+// jobWindow is the progress of downloading one time window of a job.
+type jobWindow struct {
+	// Request is the next request to make for the window. Its start moves
+	// forward as partial downloads are received.
+	Request api.StreamRequest `json:"request"`
+	// Parts are the partial files received, in time order.
+	Parts []jobPart `json:"parts"`
+	// Pending is the partial file being downloaded, if any.
+	Pending string `json:"pending,omitempty"`
+	Done    bool   `json:"done"`
+	resumeState
+}
+
+// jobPart is a partial file of a window and its fileInfo.
+type jobPart struct {
+	Name         string `json:"name"`
+	MaxTime      uint64 `json:"maxTime"`
+	MessageCount uint64 `json:"messageCount"`
+}
+
+// exportJobID identifies the job of an export, so that the same command
+// finds the job it started before.
+func exportJobID(output string, request *api.StreamRequest, opts exportOptions) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%t\x00%d", output, data, opts.compression, opts.recompress, opts.parallel)
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// exportJobsDir returns the directory holding the jobs of exports to output:
+// tmpdir if set, or else the directory of the output file.
+func exportJobsDir(tmpdir string, output string) string {
+	if tmpdir != "" {
+		return tmpdir
+	}
+	return filepath.Dir(output)
+}
+
+// exportJobIndexDir returns the directory in which the user's jobs are
+// indexed by ID, so that --resume and the jobs commands find a job wherever
+// its export put it.
+func exportJobIndexDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "foxglove-cli", "export-jobs"), nil
+}
+
+// index records the directory of the job in the index. A job that can't be
+// indexed can still be resumed by running the same command, or by the path
+// of its directory.
+func (j *exportJob) index() {
+	indexDir, err := exportJobIndexDir()
+	if err == nil {
+		err = os.MkdirAll(indexDir, 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(indexDir, j.ID), []byte(j.dir), 0600)
+	}
+	if err != nil {
+		debugf("failed to index export job %s: %s", j.ID, err)
+	}
+}
+
+// unindex removes the job from the index, if it is the job indexed under
+// its ID.
+func (j *exportJob) unindex() {
+	indexDir, err := exportJobIndexDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(indexDir, j.ID)
+	if dir, err := os.ReadFile(path); err == nil && string(dir) == j.dir {
+		os.Remove(path)
+	}
+}
+
+// indexedExportJob returns the job indexed under id.
+func indexedExportJob(id string) (*exportJob, error) {
+	indexDir, err := exportJobIndexDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.ReadFile(filepath.Join(indexDir, filepath.Base(id)))
+	if err != nil {
+		return nil, err
+	}
+	return loadExportJob(string(dir))
+}
+
+// indexedExportJobs returns the jobs in the index. Entries for jobs that no
+// longer exist, e.g. because their directory was deleted, are dropped.
+func indexedExportJobs() ([]*exportJob, error) {
+	indexDir, err := exportJobIndexDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(indexDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*exportJob{}
+	for _, entry := range entries {
+		job, err := indexedExportJob(entry.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			os.Remove(filepath.Join(indexDir, entry.Name()))
+			continue
+		}
+		if err != nil {
+			debugf("skipping indexed export job %s: %s", entry.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// openExportJob returns the job of an export, continuing the job of an
+// earlier run of the same export if there is one. It reports whether the job
+// was continued. It refuses to continue a job that another export is running,
+// since both would write the same partial files.
+func openExportJob(output string, request *api.StreamRequest, opts exportOptions) (*exportJob, bool, error) {
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return nil, false, err
+	}
+	id, err := exportJobID(output, request, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	dir := filepath.Join(exportJobsDir(opts.tmpdir, output), exportJobPrefix+id)
+	job, err := loadExportJob(dir)
+	if err == nil {
+		if job.running() {
+			return nil, false, fmt.Errorf("export job %s is in progress in another process (%s)", job.ID, job.dir)
+		}
+		job.index()
+		return job, true, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		// Treat a corrupt job as absent, dropping anything it downloaded.
+		debugf("discarding export job %s: %s", id, err)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create export job directory: %w", err)
+	}
+	now := time.Now()
+	job = &exportJob{
+		ID:          id,
+		Output:      output,
+		Request:     *request,
+		Compression: opts.compression,
+		Recompress:  opts.recompress,
+		Parallel:    opts.parallel,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		dir:         dir,
+	}
+	if opts.parallel <= 1 {
+		window := &jobWindow{Request: *request}
+		window.Request.OutputFormat = downloadFormat(request.OutputFormat)
+		job.Windows = []*jobWindow{window}
+	}
+	if err := job.save(); err != nil {
+		return nil, false, err
+	}
+	job.index()
+	return job, false, nil
+}
+
+// loadExportJob reads the job in dir.
+func loadExportJob(dir string) (*exportJob, error) {
+	data, err := os.ReadFile(filepath.Join(dir, exportJobFile))
+	if err != nil {
+		return nil, err
+	}
+	job := &exportJob{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("invalid export job: %w", err)
+	}
+	job.dir = dir
+	return job, nil
+}
+
+// findExportJob returns the job given to --resume or `jobs clean`, either as
+// the path of its directory, or as an ID in dir or in the index.
+func findExportJob(dir string, name string) (*exportJob, error) {
+	job, err := loadExportJob(name)
+	if err == nil {
+		return job, nil
+	}
+	job, err = loadExportJob(filepath.Join(dir, exportJobPrefix+name))
+	if !errors.Is(err, fs.ErrNotExist) {
+		return job, err
+	}
+	job, err = indexedExportJob(name)
+	if err != nil {
+		return nil, fmt.Errorf("no export job %s in %s or among known jobs; pass the path of its directory instead", name, dir)
+	}
+	return job, nil
+}
+
+// options returns the options the job was started with.
+func (j *exportJob) options() exportOptions {
+	return exportOptions{
+		tmpdir:      filepath.Dir(j.dir),
+		compression: j.Compression,
+		recompress:  j.Recompress,
+		parallel:    j.Parallel,
+	}
+}
+
+// save writes the state of the job. The caller must hold mtx if windows are
+// downloading.
+func (j *exportJob) save() error {
+	j.UpdatedAt = time.Now()
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	// Write through a temporary file so an interruption can't leave a
+	// truncated job file behind.
+	path := filepath.Join(j.dir, exportJobFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// update changes the state of the job and saves it.
+func (j *exportJob) update(f func()) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	f()
+	if err := j.save(); err != nil {
+		return fmt.Errorf("failed to save export job: %w", err)
+	}
+	return nil
+}
+
+// path returns the path of a file in the job directory.
+func (j *exportJob) path(name string) string {
+	return filepath.Join(j.dir, name)
+}
+
+// downloaded reports whether the job has received any data worth keeping.
+func (j *exportJob) downloaded() bool {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	for _, window := range j.Windows {
+		if len(window.Parts) > 0 {
+			return true
+		}
+		if window.Pending != "" {
+			if info, err := os.Stat(j.path(window.Pending)); err == nil && info.Size() > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keepAlive marks the job as being run by this process until the returned
+// function is called.
+func (j *exportJob) keepAlive() func() {
+	path := j.path(exportJobHeartbeat)
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		debugf("failed to mark export job %s as running: %s", j.ID, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			os.Remove(path)
+		})
+	}
+}
+
+// running reports whether an export, possibly in another process, is running
+// the job.
+func (j *exportJob) running() bool {
+	info, err := os.Stat(j.path(exportJobHeartbeat))
+	return err == nil && time.Since(info.ModTime()) < 3*heartbeatInterval
+}
+
+// remove deletes the job and its partial files.
+func (j *exportJob) remove() error {
+	j.unindex()
+	return os.RemoveAll(j.dir)
+}
+
+// exportJobRecord is a row of `data export jobs list`.
+type exportJobRecord struct {
+	ID      string `json:"id"`
+	Output  string `json:"output"`
+	Windows string `json:"windows"`
+	Parts   int    `json:"parts"`
+	Size    string `json:"size"`
+	Updated string `json:"updated"`
+	Dir     string `json:"dir"`
+}
+
+func (r exportJobRecord) Headers() []string {
+	return []string{"ID", "Output", "Windows", "Parts", "Size", "Updated", "Directory"}
+}
+
+func (r exportJobRecord) Fields() []string {
+	return []string{r.ID, r.Output, r.Windows, fmt.Sprint(r.Parts), r.Size, r.Updated, r.Dir}
+}
+
+// listExportJobs returns the jobs in dir, skipping directories that hold no
+// valid job. If dir is empty, the jobs in the index and in the current
+// directory are returned.
+func listExportJobs(dir string) ([]*exportJob, error) {
+	if dir == "" {
+		indexed, err := indexedExportJobs()
+		if err != nil {
+			return nil, err
+		}
+		local, err := listExportJobs(".")
+		if err != nil {
+			return nil, err
+		}
+		jobs := indexed
+		for _, job := range local {
+			if !slices.ContainsFunc(indexed, func(other *exportJob) bool { return sameDir(job.dir, other.dir) }) {
+				jobs = append(jobs, job)
+			}
+		}
+		return jobs, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*exportJob{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), exportJobPrefix) {
+			continue
+		}
+		job, err := loadExportJob(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			debugf("skipping %s: %s", entry.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// sameDir reports whether a and b name the same directory.
+func sameDir(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// record summarizes the job for `data export jobs list`.
+func (j *exportJob) record() exportJobRecord {
+	done, parts := 0, 0
+	for _, window := range j.Windows {
+		if window.Done {
+			done++
+		}
+		parts += len(window.Parts)
+	}
+	var size int64
+	filepath.WalkDir(j.dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	windows := fmt.Sprintf("%d/%d done", done, len(j.Windows))
+	if len(j.Windows) == 0 {
+		windows = "-"
+	}
+	return exportJobRecord{
+		ID:      j.ID,
+		Output:  j.Output,
+		Windows: windows,
+		Parts:   parts,
+		Size:    api.HumanReadableBytes(size),
+		Updated: j.UpdatedAt.Format(time.RFC3339),
+		Dir:     j.dir,
+	}
+}
+
+// cleanExportJobs removes the jobs in dir, or as listed by listExportJobs if
+// dir is empty, that were last updated before cutoff, and returns their IDs.
+// Jobs that an export is running are skipped.
+func cleanExportJobs(dir string, cutoff time.Time) ([]string, error) {
+	jobs, err := listExportJobs(dir)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, job := range jobs {
+		if !job.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if job.running() {
+			fmt.Fprintf(os.Stderr, "Skipping export job %s, which is in progress\n", job.ID)
+			continue
+		}
+		if err := job.remove(); err != nil {
+			return removed, err
+		}
+		removed = append(removed, job.ID)
+	}
+	return removed, nil
+}
+
+func newExportJobsCommand() *cobra.Command {
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List and clean up unfinished export jobs",
+		Long: `An export to an --output-file keeps its partial downloads in a job directory
+next to the output file, or in --tmpdir, until it completes. If an export stops
+partway, running the same command again or 'data export --resume JOB'
+continues it. These commands manage the jobs left behind. Jobs are known by ID
+wherever they are kept; --dir limits a command to the jobs in one directory.`,
+	}
+	jobsCmd.AddCommand(newListExportJobsCommand(), newCleanExportJobsCommand())
+	return jobsCmd
+}
+
+func newListExportJobsCommand() *cobra.Command {
+	var dir string
+	var format string
+	var isJsonFormat bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List unfinished export jobs",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			jobs, err := listExportJobs(dir)
+			if err != nil {
+				dief("Failed to list export jobs: %s", err)
+			}
+			records := make([]exportJobRecord, len(jobs))
+			for i, job := range jobs {
+				records[i] = job.record()
+			}
+			if err := renderRecords(os.Stdout, records, ResolveFormat(format, isJsonFormat)); err != nil {
+				dief("Failed to render export jobs: %s", err)
+			}
+		},
+	}
+	listCmd.Flags().StringVarP(&dir, "dir", "", configSetting("export_tmpdir"), "list only the jobs in this directory (default: all known jobs, and those in the current directory)")
+	AddFormatFlag(listCmd, &format)
+	AddJsonFlag(listCmd, &isJsonFormat)
+	return listCmd
+}
+
+func newCleanExportJobsCommand() *cobra.Command {
+	var dir string
+	var olderThan time.Duration
+	var all bool
+	cleanCmd := &cobra.Command{
+		Use:   "clean [JOB...]",
+		Short: "Remove export jobs and their partial downloads",
+		Long: `Remove the given export jobs, by ID or directory. Without arguments, remove the
+known jobs, or those in --dir, that have not been updated for --older-than, or
+all of them with --all. Jobs that an export is running are never removed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 {
+				for _, name := range args {
+					job, err := findExportJob(defaultString(dir, "."), name)
+					if err != nil {
+						dief("%s", err)
+					}
+					if job.running() {
+						dief("Export job %s is in progress", job.ID)
+					}
+					if err := job.remove(); err != nil {
+						dief("Failed to remove export job %s: %s", job.ID, err)
+					}
+					fmt.Fprintf(os.Stderr, "Removed export job %s\n", job.ID)
+				}
+				return
+			}
+			if all {
+				olderThan = 0
+			}
+			removed, err := cleanExportJobs(dir, time.Now().Add(-olderThan))
+			for _, id := range removed {
+				fmt.Fprintf(os.Stderr, "Removed export job %s\n", id)
+			}
+			if err != nil {
+				dief("Failed to clean export jobs: %s", err)
+			}
+		},
+	}
+	cleanCmd.Flags().StringVarP(&dir, "dir", "", configSetting("export_tmpdir"), "clean only the jobs in this directory (default: all known jobs, and those in the current directory)")
+	cleanCmd.Flags().DurationVarP(&olderThan, "older-than", "", 24*time.Hour, "remove jobs not updated for this long")
+	cleanCmd.Flags().BoolVarP(&all, "all", "", false, "remove all jobs, regardless of --older-than")
+	return cleanCmd
+}
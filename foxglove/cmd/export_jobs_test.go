@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeExportJob(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	messages := stitchTestMessages()
+	full := len(writeStitchTestMCAP(t, messages))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sv, err := api.NewMockServer(ctx)
+	require.NoError(t, err)
+	exportCtx, stop := context.WithCancel(ctx)
+	starts := []uint64{}
+	sv.StreamData = func(req api.StreamRequest) []byte {
+		start := uint64(0)
+		if req.Start != nil {
+			start = uint64(req.Start.UnixNano())
+		}
+		starts = append(starts, start)
+		if len(starts) == 2 {
+			// The first run stops while making its second request.
+			stop()
+		}
+		selected := []stitchedMessage{}
+		for _, m := range messages {
+			if m.logTime >= start {
+				selected = append(selected, m)
+			}
+		}
+		return writeStitchTestMCAP(t, selected)
+	}
+	sv.InjectFault(api.Fault{PathPrefix: "/storage/", Count: 1, TruncateAfter: full / 2})
+	client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+	token, err := client.SignIn(ctx, "client-id")
+	require.NoError(t, err)
+	tmpdir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "output.mcap")
+	export := func(ctx context.Context) error {
+		return doExport(
+			ctx,
+			output,
+			sv.BaseURL(),
+			"abc",
+			token,
+			"user-agent",
+			&api.StreamRequest{DeviceID: "test-device", OutputFormat: "mcap0"},
+			exportOptions{tmpdir: tmpdir},
+		)
+	}
+
+	assert.ErrorIs(t, export(exportCtx), context.Canceled)
+	assert.NoFileExists(t, output)
+	jobs, err := listExportJobs(tmpdir)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Len(t, jobs[0].Windows[0].Parts, 1)
+	resumeFrom := jobs[0].Windows[0].Parts[0].MaxTime
+	assert.NotZero(t, resumeFrom)
+
+	require.NoError(t, export(ctx))
+	require.Len(t, starts, 3)
+	assert.Equal(t, resumeFrom, starts[2])
+	exported, err := os.Open(output)
+	require.NoError(t, err)
+	defer exported.Close()
+	assert.Equal(t, messages, readStitchTestMCAP(t, exported))
+	jobs, err = listExportJobs(tmpdir)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestResumeExportJobByID(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	messages := stitchTestMessages()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sv, err := api.NewMockServer(ctx)
+	require.NoError(t, err)
+	sv.StreamData = func(api.StreamRequest) []byte {
+		return writeStitchTestMCAP(t, messages)
+	}
+	client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+	token, err := client.SignIn(ctx, "client-id")
+	require.NoError(t, err)
+
+	// The job is kept next to the output file, as the export left it.
+	output := filepath.Join(t.TempDir(), "output.mcap")
+	request := &api.StreamRequest{DeviceID: "test-device", OutputFormat: "mcap0"}
+	job, _, err := openExportJob(output, request, exportOptions{})
+	require.NoError(t, err)
+
+	t.Chdir(t.TempDir())
+	resumed, err := findExportJob(".", job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job.dir, resumed.dir)
+	jobs, err := listExportJobs("")
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, job.ID, jobs[0].ID)
+
+	err = doExport(ctx, resumed.Output, sv.BaseURL(), "abc", token, "user-agent", &resumed.Request, resumed.options())
+	require.NoError(t, err)
+	exported, err := os.Open(output)
+	require.NoError(t, err)
+	defer exported.Close()
+	assert.Equal(t, messages, readStitchTestMCAP(t, exported))
+	_, err = findExportJob(".", job.ID)
+	assert.ErrorContains(t, err, "pass the path of its directory")
+}
+
+func TestExportJobs(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output.mcap")
+	first, resumed, err := openExportJob(output, &api.StreamRequest{DeviceID: "a", OutputFormat: "mcap0"}, exportOptions{})
+	require.NoError(t, err)
+	assert.False(t, resumed)
+	second, _, err := openExportJob(output, &api.StreamRequest{DeviceID: "b", OutputFormat: "mcap0"}, exportOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	again, resumed, err := openExportJob(output, &api.StreamRequest{DeviceID: "a", OutputFormat: "mcap0"}, exportOptions{})
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, first.ID, again.ID)
+
+	t.Run("refuses to continue a job in progress", func(t *testing.T) {
+		stop := first.keepAlive()
+		defer stop()
+		_, _, err := openExportJob(output, &api.StreamRequest{DeviceID: "a", OutputFormat: "mcap0"}, exportOptions{})
+		assert.ErrorContains(t, err, "export job "+first.ID+" is in progress")
+	})
+	t.Run("lists the jobs in a directory", func(t *testing.T) {
+		jobs, err := listExportJobs(dir)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+			assert.Equal(t, output, job.Output)
+		}
+		assert.ElementsMatch(t, []string{first.ID, second.ID}, ids)
+		record := first.record()
+		assert.Equal(t, "0/1 done", record.Windows)
+	})
+	t.Run("finds a job by ID or directory", func(t *testing.T) {
+		job, err := findExportJob(dir, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "a", job.Request.DeviceID)
+		job, err = findExportJob(t.TempDir(), second.dir)
+		require.NoError(t, err)
+		assert.Equal(t, "b", job.Request.DeviceID)
+		_, err = findExportJob(dir, "missing")
+		assert.ErrorContains(t, err, "no export job missing")
+	})
+	t.Run("cleans jobs not updated since a cutoff", func(t *testing.T) {
+		removed, err := cleanExportJobs(dir, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+		// A job that an export is running is left alone.
+		stop := first.keepAlive()
+		assert.True(t, first.running())
+		removed, err = cleanExportJobs(dir, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, []string{second.ID}, removed)
+		stop()
+		assert.False(t, first.running())
+		removed, err = cleanExportJobs(dir, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, []string{first.ID}, removed)
+		assert.NoDirExists(t, first.dir)
+		assert.NoDirExists(t, second.dir)
+	})
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exportRecipeFlags are the `data export` flags an export recipe may set,
+// keyed by their names in the config file.
+var exportRecipeFlags = map[string]string{
+	"device_id":     "device-id",
+	"device_name":   "device-name",
+	"topics":        "topics",
+	"output_format": "output-format",
+	"compression":   "compression",
+}
+
+// lookupExportRecipe returns the export recipe with the given name, preferring
+// one in the project config file, and its origin.
+func lookupExportRecipe(name string) (map[string]any, settingOrigin, bool) {
+	key := "export_recipes." + name
+	if projectConfig.IsSet(key) {
+		return projectConfig.GetStringMap(key), projectOrigin(), true
+	}
+	if viper.IsSet(key) {
+		return viper.GetStringMap(key), configOrigin(), true
+	}
+	return nil, originDefault, false
+}
+
+// exportRecipeNames returns the names of the export recipes in the project and
+// user config files.
+func exportRecipeNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, config := range []*viper.Viper{projectConfig, viper.GetViper()} {
+		for name := range config.GetStringMap("export_recipes") {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyExportRecipe sets the flags of cmd that the named recipe configures,
+// unless they were given on the command line.
+func applyExportRecipe(cmd *cobra.Command, name string) error {
+	recipe, origin, ok := lookupExportRecipe(name)
+	if !ok {
+		return fmt.Errorf("no export recipe named %q. Recipes: %s", name, strings.Join(exportRecipeNames(), ", "))
+	}
+	for key, value := range recipe {
+		flag, ok := exportRecipeFlags[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %s in export recipe %q in the %s", key, name, origin)
+		}
+		if cmd.Flags().Changed(flag) {
+			continue
+		}
+		// Topics may be given as a list.
+		if list, ok := value.([]any); ok {
+			topics := []string{}
+			for _, topic := range list {
+				topics = append(topics, fmt.Sprint(topic))
+			}
+			value = strings.Join(topics, ",")
+		}
+		if err := cmd.Flags().Set(flag, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("invalid %s in export recipe %q: %w", key, name, err)
+		}
+	}
+	return nil
+}
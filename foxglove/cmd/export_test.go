@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/foxglove/foxglove-cli/foxglove/util"
 	"github.com/foxglove/go-rosbag"
 	"github.com/foxglove/mcap/go/mcap"
+	"github.com/foxglove/mcap/go/mcap/readopts"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -101,15 +104,130 @@ func TestCombineMCAPTmpFiles(t *testing.T) {
 		})
 	}
 
-	assert.Nil(t, combineMCAPTmpFiles(output, tmpfiles))
+	assert.Nil(t, combineMCAPTmpFiles(output, tmpfiles, mcap.CompressionLZ4))
 
 	reader, err := mcap.NewReader(bytes.NewReader(output.Bytes()))
 	assert.Nil(t, err)
 	info, err := reader.Info()
 	assert.Nil(t, err)
 	assert.Equal(t, 3000, int(info.Statistics.MessageCount))
-	assert.Equal(t, 6, int(info.Statistics.ChannelCount))
-	assert.Equal(t, 6, int(info.Statistics.SchemaCount))
+	// The channels and schemas repeated by each part are written once.
+	assert.Equal(t, 2, int(info.Statistics.ChannelCount))
+	assert.Equal(t, 2, int(info.Statistics.SchemaCount))
+}
+
+func TestCombineMatchesChannelsAcrossParts(t *testing.T) {
+	t.Run("mcap", func(t *testing.T) {
+		// The second part numbers its schemas and channels differently, and
+		// reuses the ID of /a for a new topic.
+		writePart := func(schemas []*mcap.Schema, channels []*mcap.Channel, logTime uint64) []byte {
+			buf := &bytes.Buffer{}
+			writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{Chunked: true})
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteHeader(&mcap.Header{}))
+			for _, schema := range schemas {
+				require.NoError(t, writer.WriteSchema(schema))
+			}
+			for _, channel := range channels {
+				require.NoError(t, writer.WriteChannel(channel))
+				require.NoError(t, writer.WriteMessage(&mcap.Message{ChannelID: channel.ID, LogTime: logTime, Data: []byte(channel.Topic)}))
+			}
+			require.NoError(t, writer.Close())
+			return buf.Bytes()
+		}
+		imu := &mcap.Schema{ID: 1, Name: "Imu", Encoding: "ros1msg", Data: []byte("imu")}
+		image := &mcap.Schema{ID: 2, Name: "Image", Encoding: "ros1msg", Data: []byte("image")}
+		parts := [][]byte{
+			writePart([]*mcap.Schema{imu, image}, []*mcap.Channel{
+				{ID: 0, SchemaID: 1, Topic: "/a", MessageEncoding: "ros1"},
+				{ID: 1, SchemaID: 2, Topic: "/camera", MessageEncoding: "ros1"},
+			}, 1),
+			writePart([]*mcap.Schema{
+				{ID: 1, Name: "Image", Encoding: "ros1msg", Data: []byte("image")},
+				{ID: 2, Name: "Imu", Encoding: "ros1msg", Data: []byte("imu")},
+			}, []*mcap.Channel{
+				{ID: 0, SchemaID: 2, Topic: "/b", MessageEncoding: "ros1"},
+				{ID: 1, SchemaID: 2, Topic: "/a", MessageEncoding: "ros1"},
+				{ID: 2, SchemaID: 1, Topic: "/camera", MessageEncoding: "ros1"},
+			}, 2),
+		}
+		tmpfiles := []partialFile{}
+		for _, part := range parts {
+			tmpfiles = append(tmpfiles, partialFile{rs: bytes.NewReader(part), info: &fileInfo{messageCount: 2}})
+		}
+		output := &bytes.Buffer{}
+		require.NoError(t, combineMCAPTmpFiles(output, tmpfiles, mcap.CompressionLZ4))
+
+		reader, err := mcap.NewReader(bytes.NewReader(output.Bytes()))
+		require.NoError(t, err)
+		info, err := reader.Info()
+		require.NoError(t, err)
+		assert.Equal(t, 5, int(info.Statistics.MessageCount))
+		assert.Len(t, info.Schemas, 2)
+		topics := map[string]string{}
+		for _, channel := range info.Channels {
+			topics[channel.Topic] = info.Schemas[channel.SchemaID].Name
+		}
+		assert.Equal(t, map[string]string{"/a": "Imu", "/b": "Imu", "/camera": "Image"}, topics)
+		// Where the IDs are free, they are kept.
+		assert.Equal(t, "/a", info.Channels[0].Topic)
+		assert.Equal(t, "/camera", info.Channels[1].Topic)
+		assert.Equal(t, "/b", info.Channels[2].Topic)
+		it, err := reader.Messages(readopts.UsingIndex(false))
+		require.NoError(t, err)
+		for {
+			_, channel, message, err := it.Next(nil)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			assert.Equal(t, channel.Topic, string(message.Data))
+		}
+	})
+	t.Run("bag", func(t *testing.T) {
+		connection := func(id uint32, topic, typ string) *rosbag.Connection {
+			return &rosbag.Connection{
+				Conn:  id,
+				Topic: topic,
+				Data:  rosbag.ConnectionHeader{Topic: topic, Type: typ, MD5Sum: typ, MessageDefinition: []byte{}},
+			}
+		}
+		writePart := func(connections []*rosbag.Connection, logTime uint64) []byte {
+			buf := &bytes.Buffer{}
+			writer, err := rosbag.NewWriter(buf)
+			require.NoError(t, err)
+			for _, conn := range connections {
+				require.NoError(t, writer.WriteConnection(conn))
+				require.NoError(t, writer.WriteMessage(&rosbag.Message{Conn: conn.Conn, Time: logTime, Data: []byte(conn.Topic)}))
+			}
+			require.NoError(t, writer.Close())
+			return buf.Bytes()
+		}
+		parts := [][]byte{
+			writePart([]*rosbag.Connection{connection(0, "/a", "sensor_msgs/Imu"), connection(1, "/camera", "sensor_msgs/Image")}, 1),
+			writePart([]*rosbag.Connection{connection(0, "/camera", "sensor_msgs/Image"), connection(1, "/a", "sensor_msgs/Imu")}, 2),
+		}
+		tmpfiles := []partialFile{}
+		for _, part := range parts {
+			tmpfiles = append(tmpfiles, partialFile{rs: bytes.NewReader(part), info: &fileInfo{messageCount: 2}})
+		}
+		output := util.NewBufWriteSeeker()
+		require.NoError(t, combineBagTmpFiles(output, tmpfiles))
+
+		reader, err := rosbag.NewReader(bytes.NewReader(output.Bytes()))
+		require.NoError(t, err)
+		info, err := reader.Info()
+		require.NoError(t, err)
+		assert.Equal(t, 4, int(info.MessageCount))
+		assert.Len(t, info.Connections, 2)
+		it, err := reader.Messages()
+		require.NoError(t, err)
+		for it.More() {
+			conn, msg, err := it.Next()
+			require.NoError(t, err)
+			assert.Equal(t, conn.Topic, string(msg.Data))
+		}
+	})
 }
 
 func TestCombineBagTempfiles(t *testing.T) {
@@ -184,7 +302,7 @@ func TestDoExport(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
-		token, err := client.SignIn("client-id")
+		token, err := client.SignIn(ctx, "client-id")
 		assert.Nil(t, err)
 		start, err := time.Parse(time.RFC3339, "2001-01-01T00:00:00Z")
 		assert.Nil(t, err)
@@ -192,6 +310,7 @@ func TestDoExport(t *testing.T) {
 		deviceID := "test-device"
 		projectID := "prj_1234abcd"
 		err = executeImport(
+			ctx,
 			sv.BaseURL(),
 			clientID,
 			projectID,
@@ -201,6 +320,7 @@ func TestDoExport(t *testing.T) {
 			"",
 			"",
 			"../testdata/gps.mcap",
+			"",
 			token,
 			"user-agent",
 		)
@@ -224,11 +344,342 @@ func TestDoExport(t *testing.T) {
 				OutputFormat: "mcap0",
 				Topics:       []string{"/diagnostics"},
 			},
+			exportOptions{},
+		)
+		assert.Nil(t, err)
+	})
+
+	t.Run("resumes an interrupted download without restarting the request", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := api.NewMockServer(ctx)
+		assert.Nil(t, err)
+		sv.RangeDownloads = true
+		buf := &bytes.Buffer{}
+		writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{Chunked: true, ChunkSize: 1024})
+		assert.Nil(t, err)
+		assert.Nil(t, writer.WriteHeader(&mcap.Header{}))
+		assert.Nil(t, writer.WriteSchema(&mcap.Schema{ID: 1, Name: "s", Encoding: "ros1msg"}))
+		assert.Nil(t, writer.WriteChannel(&mcap.Channel{ID: 0, SchemaID: 1, Topic: "/t"}))
+		for i := 0; i < 1000; i++ {
+			assert.Nil(t, writer.WriteMessage(&mcap.Message{LogTime: uint64(i), Data: make([]byte, 64)}))
+		}
+		assert.Nil(t, writer.Close())
+		sv.Uploads["device_id=test-device/data.mcap"] = buf.Bytes()
+		sv.InjectFault(api.Fault{PathPrefix: "/storage/", Count: 1, TruncateAfter: buf.Len() / 2})
+		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+		token, err := client.SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		output := filepath.Join(t.TempDir(), "output.mcap")
+		err = doExport(
+			ctx,
+			output,
+			sv.BaseURL(),
+			"abc",
+			token,
+			"user-agent",
+			&api.StreamRequest{
+				DeviceID:     "test-device",
+				OutputFormat: "mcap0",
+			},
+			exportOptions{},
+		)
+		assert.Nil(t, err)
+		exported, err := os.ReadFile(output)
+		assert.Nil(t, err)
+		assert.Equal(t, buf.Bytes(), exported)
+		assert.Equal(t, 1, sv.RequestCount("/v1/data/stream"))
+	})
+
+	t.Run("recompresses a complete download in the configured tmpdir", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := api.NewMockServer(ctx)
+		assert.Nil(t, err)
+		buf := &bytes.Buffer{}
+		writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{Chunked: true, ChunkSize: 1024})
+		assert.Nil(t, err)
+		assert.Nil(t, writer.WriteHeader(&mcap.Header{}))
+		assert.Nil(t, writer.WriteSchema(&mcap.Schema{ID: 1, Name: "s", Encoding: "ros1msg"}))
+		assert.Nil(t, writer.WriteChannel(&mcap.Channel{ID: 0, SchemaID: 1, Topic: "/t"}))
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, writer.WriteMessage(&mcap.Message{LogTime: uint64(i), Data: make([]byte, 64)}))
+		}
+		assert.Nil(t, writer.Close())
+		sv.Uploads["device_id=test-device/data.mcap"] = buf.Bytes()
+		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+		token, err := client.SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		tmpdir := t.TempDir()
+		output := filepath.Join(t.TempDir(), "output.mcap")
+		err = doExport(
+			ctx,
+			output,
+			sv.BaseURL(),
+			"abc",
+			token,
+			"user-agent",
+			&api.StreamRequest{
+				DeviceID:     "test-device",
+				OutputFormat: "mcap0",
+			},
+			exportOptions{tmpdir: tmpdir, compression: mcap.CompressionZSTD, recompress: true},
+		)
+		assert.Nil(t, err)
+		f, err := os.Open(output)
+		assert.Nil(t, err)
+		defer f.Close()
+		reader, err := mcap.NewReader(f)
+		assert.Nil(t, err)
+		info, err := reader.Info()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), info.Statistics.MessageCount)
+		assert.NotEmpty(t, info.ChunkIndexes)
+		for _, index := range info.ChunkIndexes {
+			assert.Equal(t, mcap.CompressionZSTD, index.Compression)
+		}
+		staged, err := os.ReadDir(tmpdir)
+		assert.Nil(t, err)
+		assert.Empty(t, staged)
+	})
+
+	t.Run("cancellation removes partial output", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := api.NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+		token, err := client.SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		start, err := time.Parse(time.RFC3339, "2001-01-01T00:00:00Z")
+		assert.Nil(t, err)
+		before, err := filepath.Glob("export*")
+		assert.Nil(t, err)
+
+		exportCtx, exportCancel := context.WithCancel(ctx)
+		exportCancel()
+		err = doExport(
+			exportCtx,
+			"cancelled.mcap",
+			sv.BaseURL(),
+			"abc",
+			token,
+			"user-agent",
+			&api.StreamRequest{
+				DeviceID:     "test-device",
+				Start:        &start,
+				End:          &end,
+				OutputFormat: "mcap0",
+			},
+			exportOptions{},
 		)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoFileExists(t, "cancelled.mcap")
+		after, err := filepath.Glob("export*")
 		assert.Nil(t, err)
+		assert.Equal(t, before, after)
 	})
 }
 
+// stitchedMessage is the identity and content of an exported message.
+type stitchedMessage struct {
+	topic   string
+	logTime uint64
+	data    string
+}
+
+// stitchTestMessages are logged in bursts that share a timestamp, on two
+// topics, with some messages repeated exactly within a burst.
+func stitchTestMessages() []stitchedMessage {
+	base := uint64(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	messages := []stitchedMessage{}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 12; j++ {
+			messages = append(messages, stitchedMessage{
+				topic:   []string{"/a", "/b"}[j%2],
+				logTime: base + uint64(i)*1e6,
+				data:    strings.Repeat(fmt.Sprintf("message %d;", j/4), 8),
+			})
+		}
+	}
+	return messages
+}
+
+// truncatedDownloads splits messages as a download would be, were it cut off
+// after each of counts messages and resumed from the last log time received.
+func truncatedDownloads(messages []stitchedMessage, counts []int) [][]stitchedMessage {
+	downloads := [][]stitchedMessage{}
+	remaining := messages
+	for _, count := range counts {
+		download := remaining[:min(count, len(remaining))]
+		downloads = append(downloads, download)
+		if len(download) == 0 {
+			continue
+		}
+		last := download[len(download)-1].logTime
+		for len(remaining) > 0 && remaining[0].logTime < last {
+			remaining = remaining[1:]
+		}
+	}
+	return append(downloads, remaining)
+}
+
+func writeStitchTestMCAP(t *testing.T, messages []stitchedMessage) []byte {
+	// Without chunks, a truncated stream keeps every complete message.
+	buf := &bytes.Buffer{}
+	writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader(&mcap.Header{}))
+	require.NoError(t, writer.WriteSchema(&mcap.Schema{ID: 1, Name: "s", Encoding: "ros1msg"}))
+	require.NoError(t, writer.WriteChannel(&mcap.Channel{ID: 0, SchemaID: 1, Topic: "/a"}))
+	require.NoError(t, writer.WriteChannel(&mcap.Channel{ID: 1, SchemaID: 1, Topic: "/b"}))
+	for _, m := range messages {
+		channelID := uint16(0)
+		if m.topic == "/b" {
+			channelID = 1
+		}
+		require.NoError(t, writer.WriteMessage(&mcap.Message{ChannelID: channelID, LogTime: m.logTime, Data: []byte(m.data)}))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func readStitchTestMCAP(t *testing.T, r io.Reader) []stitchedMessage {
+	reader, err := mcap.NewReader(r)
+	require.NoError(t, err)
+	it, err := reader.Messages(readopts.UsingIndex(false))
+	require.NoError(t, err)
+	messages := []stitchedMessage{}
+	for {
+		_, channel, message, err := it.Next(nil)
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		require.NoError(t, err)
+		messages = append(messages, stitchedMessage{channel.Topic, message.LogTime, string(message.Data)})
+	}
+}
+
+func TestCombineStitchesBoundaries(t *testing.T) {
+	messages := stitchTestMessages()
+	cases := [][]int{
+		{5},        // within the first burst
+		{12},       // at the end of a burst
+		{13, 1},    // one message into a burst, then no further
+		{30, 4, 4}, // repeatedly within the same burst
+		{0, 50, 20, 7},
+	}
+	for _, counts := range cases {
+		downloads := truncatedDownloads(messages, counts)
+		t.Run(fmt.Sprintf("mcap cut after %v messages", counts), func(t *testing.T) {
+			tmpfiles := []partialFile{}
+			for _, download := range downloads {
+				tmpfiles = append(tmpfiles, partialFile{
+					rs:   bytes.NewReader(writeStitchTestMCAP(t, download)),
+					info: &fileInfo{messageCount: uint64(len(download))},
+				})
+			}
+			output := &bytes.Buffer{}
+			require.NoError(t, combineMCAPTmpFiles(output, tmpfiles, mcap.CompressionLZ4))
+			assert.Equal(t, messages, readStitchTestMCAP(t, bytes.NewReader(output.Bytes())))
+		})
+		t.Run(fmt.Sprintf("bag cut after %v messages", counts), func(t *testing.T) {
+			tmpfiles := []partialFile{}
+			for _, download := range downloads {
+				buf := &bytes.Buffer{}
+				writer, err := rosbag.NewWriter(buf)
+				require.NoError(t, err)
+				for i, topic := range []string{"/a", "/b"} {
+					require.NoError(t, writer.WriteConnection(&rosbag.Connection{
+						Conn:  uint32(i),
+						Topic: topic,
+						Data:  rosbag.ConnectionHeader{Topic: topic, Type: "std_msgs/String", MD5Sum: "abc", MessageDefinition: []byte{}},
+					}))
+				}
+				for _, m := range download {
+					conn := uint32(0)
+					if m.topic == "/b" {
+						conn = 1
+					}
+					require.NoError(t, writer.WriteMessage(&rosbag.Message{Conn: conn, Time: m.logTime, Data: []byte(m.data)}))
+				}
+				require.NoError(t, writer.Close())
+				tmpfiles = append(tmpfiles, partialFile{
+					rs:   bytes.NewReader(buf.Bytes()),
+					info: &fileInfo{messageCount: uint64(len(download))},
+				})
+			}
+			output := util.NewBufWriteSeeker()
+			require.NoError(t, combineBagTmpFiles(output, tmpfiles))
+			reader, err := rosbag.NewReader(bytes.NewReader(output.Bytes()))
+			require.NoError(t, err)
+			it, err := reader.Messages()
+			require.NoError(t, err)
+			combined := []stitchedMessage{}
+			for it.More() {
+				conn, msg, err := it.Next()
+				require.NoError(t, err)
+				combined = append(combined, stitchedMessage{conn.Topic, msg.Time, string(msg.Data)})
+			}
+			assert.Equal(t, messages, combined)
+		})
+	}
+}
+
+func TestStitchedExport(t *testing.T) {
+	messages := stitchTestMessages()
+	full := len(writeStitchTestMCAP(t, messages))
+	// Cut the streams at points spread across the data, which land within
+	// bursts of messages sharing a timestamp.
+	for k := 1; k < 12; k++ {
+		truncateAfter := full * k / 12
+		t.Run(fmt.Sprintf("truncated after %d bytes", truncateAfter), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			sv, err := api.NewMockServer(ctx)
+			require.NoError(t, err)
+			sv.StreamData = func(req api.StreamRequest) []byte {
+				selected := []stitchedMessage{}
+				for _, m := range messages {
+					if req.Start == nil || m.logTime >= uint64(req.Start.UnixNano()) {
+						selected = append(selected, m)
+					}
+				}
+				return writeStitchTestMCAP(t, selected)
+			}
+			sv.InjectFault(api.Fault{PathPrefix: "/storage/", Count: 3, TruncateAfter: truncateAfter})
+			client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+			token, err := client.SignIn(ctx, "client-id")
+			require.NoError(t, err)
+			output := filepath.Join(t.TempDir(), "output.mcap")
+			err = doExport(
+				ctx,
+				output,
+				sv.BaseURL(),
+				"abc",
+				token,
+				"user-agent",
+				&api.StreamRequest{DeviceID: "test-device", OutputFormat: "mcap0"},
+				exportOptions{tmpdir: t.TempDir()},
+			)
+			require.NoError(t, err)
+			assert.Greater(t, sv.RequestCount("/v1/data/stream"), 1)
+			exported, err := os.Open(output)
+			require.NoError(t, err)
+			defer exported.Close()
+			assert.Equal(t, messages, readStitchTestMCAP(t, exported))
+			_, err = exported.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+			reader, err := mcap.NewReader(exported)
+			require.NoError(t, err)
+			info, err := reader.Info()
+			require.NoError(t, err)
+			assert.Len(t, info.Schemas, 1)
+			assert.Len(t, info.Channels, 2)
+		})
+	}
+}
+
 func TestExportCommand(t *testing.T) {
 	ctx := context.Background()
 	start, err := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
@@ -289,7 +740,7 @@ func TestExportCommand(t *testing.T) {
 			sv, err := api.NewMockServer(ctx)
 			assert.Nil(t, err)
 			client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
-			token, err := client.SignIn("client-id")
+			token, err := client.SignIn(ctx, "client-id")
 			assert.Nil(t, err)
 			err = executeExport(
 				ctx,
@@ -318,12 +769,13 @@ func TestExportCommand(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
-		token, err := client.SignIn("client-id")
+		token, err := client.SignIn(ctx, "client-id")
 		assert.Nil(t, err)
 		clientID := "client-id"
 		deviceID := "test-device"
 		projectID := "prj_1234abcd"
 		err = executeImport(
+			ctx,
 			sv.BaseURL(),
 			clientID,
 			projectID,
@@ -333,6 +785,7 @@ func TestExportCommand(t *testing.T) {
 			"",
 			"",
 			"../testdata/gps.bag",
+			"",
 			token,
 			"user-agent",
 		)
@@ -367,12 +820,13 @@ func TestExportCommand(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
-		token, err := client.SignIn("client-id")
+		token, err := client.SignIn(ctx, "client-id")
 		assert.Nil(t, err)
 		clientID := "client-id"
 		deviceID := "test-device"
 		projectID := "prj_1234abcd"
 		err = executeImport(
+			ctx,
 			sv.BaseURL(),
 			clientID,
 			projectID,
@@ -382,6 +836,7 @@ func TestExportCommand(t *testing.T) {
 			"",
 			"",
 			"../testdata/gps.mcap",
+			"",
 			token,
 			"user-agent",
 		)
@@ -437,10 +892,26 @@ func copyTo(t *testing.T, from, to string) {
 	require.NoError(t, err)
 }
 
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "partial")
+	dst := filepath.Join(dir, "output.mcap")
+	assert.Nil(t, os.WriteFile(src, []byte("exported data"), 0600))
+	assert.Nil(t, copyFile(src, dst))
+	data, err := os.ReadFile(dst)
+	assert.Nil(t, err)
+	assert.Equal(t, "exported data", string(data))
+	_, err = os.Stat(src)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = copyFile(src, filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
 func TestReindexBag(t *testing.T) {
 	workingPath := filepath.Join(t.TempDir(), "gps.bag.active")
 	copyTo(t, "../testdata/gps.bag.active", workingPath)
-	didReindex, info, err := reindex(t.TempDir(), workingPath, "bag1")
+	didReindex, info, err := reindex(t.TempDir(), workingPath, "bag1", mcap.CompressionLZ4)
 	require.NoError(t, err)
 	require.True(t, didReindex)
 	require.Equal(t, 30445, int(info.messageCount))
@@ -9,13 +9,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func executeExtensionUpload(client *api.FoxgloveClient, filename string) error {
-	ctx := context.Background()
-	return api.UploadExtensionFile(ctx, client, filename)
+func executeExtensionUpload(ctx context.Context, client *api.FoxgloveClient, filename string) error {
+	progress, finish := newProgressBar("uploading")
+	defer finish()
+	return api.UploadExtensionFile(ctx, client, filename, api.WithProgress(progress))
 }
 
-func executeExtensionDelete(client *api.FoxgloveClient, extensionId string) error {
-	return client.DeleteExtension(extensionId)
+func executeExtensionDelete(ctx context.Context, client *api.FoxgloveClient, extensionId string) error {
+	return client.DeleteExtension(ctx, extensionId)
 }
 
 func newPublishExtensionCommand(params *baseParams) *cobra.Command {
@@ -25,13 +26,14 @@ func newPublishExtensionCommand(params *baseParams) *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			filename := args[0] // guaranteed length 1 due to Args setting above
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL,
 				*params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			err := executeExtensionUpload(
+				cmd.Context(),
 				client,
 				filename,
 			)
@@ -52,13 +54,14 @@ func newListExtensionsCommand(params *baseParams) *cobra.Command {
 		Use:   "list",
 		Short: "List Studio extensions created for your organization",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			format = ResolveFormat(format, isJsonFormat)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				api.ExtensionsRequest{},
 				client.Extensions,
@@ -82,12 +85,12 @@ func newUnpublishExtensionCommand(params *baseParams) *cobra.Command {
 		Short: "Delete and unpublish a Studio extension from your organization",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
-			err := executeExtensionDelete(client, args[0])
+			err := executeExtensionDelete(cmd.Context(), client, args[0])
 			if err != nil {
 				dief("Failed to delete extension: %s", err)
 			}
@@ -22,7 +22,7 @@ func TestPublishExtensionCommand(t *testing.T) {
 			"token",
 			"user-agent",
 		)
-		err = executeExtensionUpload(client, "../testdata/fg.mock-0.0.0.foxe")
+		err = executeExtensionUpload(ctx, client, "../testdata/fg.mock-0.0.0.foxe")
 		assert.ErrorIs(t, err, api.ErrForbidden)
 	})
 	t.Run("returns friendly error for unexpected file extension", func(t *testing.T) {
@@ -36,7 +36,7 @@ func TestPublishExtensionCommand(t *testing.T) {
 			"token",
 			"user-agent",
 		)
-		err = executeExtensionUpload(client, "../testdata/gps.bag")
+		err = executeExtensionUpload(ctx, client, "../testdata/gps.bag")
 		assert.EqualError(t, err, "file should have a '.foxe' extension")
 	})
 }
@@ -48,19 +48,22 @@ func TestUnpublishExtensionCommand(t *testing.T) {
 		assert.Nil(t, err)
 		client := api.NewMockAuthedClient(t, sv.BaseURL())
 		err = executeExtensionDelete(
+			ctx,
 			client,
 			sv.ValidExtensionId(),
 		)
 		assert.Nil(t, err)
 	})
-	t.Run("does not error if extension not found", func(t *testing.T) {
+	t.Run("returns not found if extension does not exist", func(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		client := api.NewMockAuthedClient(t, sv.BaseURL())
 		err = executeExtensionDelete(
+			ctx,
 			client,
 			"nonexistent-extension-id",
 		)
-		assert.Nil(t, err)
+		assert.ErrorIs(t, err, api.ErrNotFound)
+		assert.Equal(t, exitNotFound, exitCode(err))
 	})
 }
@@ -1,17 +1,48 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
-func executeImport(baseURL, clientID, projectID, deviceID, deviceName, key, sessionID, sessionKey, filename, token, userAgent string) error {
-	ctx := context.Background()
+// stdin is the source of imports from "-". It is a variable for testing.
+var stdin io.Reader = os.Stdin
+
+// executeImport imports the named file, or standard input if filename is
+// "-". The recording is given the supplied name, which is required for
+// standard input and defaults to the file's base name otherwise.
+func executeImport(ctx context.Context, baseURL, clientID, projectID, deviceID, deviceName, key, sessionID, sessionKey, filename, name, token, userAgent string) error {
+	client := newClient(baseURL, clientID, token, userAgent)
+	deviceID, deviceName = resolveDeviceName(ctx, client, projectID, deviceID, deviceName)
+	req := api.UploadRequest{
+		Filename:   name,
+		Key:        key,
+		ProjectID:  projectID,
+		DeviceID:   deviceID,
+		DeviceName: deviceName,
+		SessionID:  sessionID,
+		SessionKey: sessionKey,
+	}
+	progress, finish := newProgressBar("uploading")
+	defer finish()
+	if filename == "-" {
+		if name == "" {
+			return fmt.Errorf("--filename is required when importing from stdin")
+		}
+		reader := bufio.NewReader(stdin)
+		err := validateImportStreamLooksLegal(reader)
+		if err != nil {
+			return err
+		}
+		return api.ImportReader(ctx, client, reader, -1, req, api.WithProgress(progress))
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -21,8 +52,15 @@ func executeImport(baseURL, clientID, projectID, deviceID, deviceName, key, sess
 	if err != nil {
 		return err
 	}
-	client := api.NewRemoteFoxgloveClient(baseURL, clientID, token, userAgent)
-	err = api.Import(ctx, client, projectID, deviceID, deviceName, key, sessionID, sessionKey, filename)
+	opts := []api.TransferOption{api.WithProgress(progress)}
+	// Progress is kept so that an interrupted import resumes when rerun. If
+	// it can't be kept, the import still proceeds.
+	if dir, err := api.DefaultUploadStateDir(); err == nil {
+		if state, err := api.NewFileUploadState(dir, filename, req); err == nil {
+			opts = append(opts, api.WithUploadState(state))
+		}
+	}
+	err = api.ImportFile(ctx, client, filename, req, opts...)
 	if err != nil {
 		return err
 	}
@@ -30,13 +68,13 @@ func executeImport(baseURL, clientID, projectID, deviceID, deviceName, key, sess
 	return nil
 }
 
-func importFromEdge(baseURL, clientID, token, userAgent, edgeRecordingID string) error {
-	client := api.NewRemoteFoxgloveClient(
+func importFromEdge(ctx context.Context, baseURL, clientID, token, userAgent, edgeRecordingID string) error {
+	client := newClient(
 		baseURL, clientID,
 		token,
 		userAgent,
 	)
-	_, err := client.ImportFromEdge(api.ImportFromEdgeRequest{}, edgeRecordingID)
+	_, err := client.ImportFromEdge(ctx, api.ImportFromEdgeRequest{}, edgeRecordingID)
 	if err != nil {
 		return err
 	}
@@ -52,18 +90,25 @@ func newImportCommand(params *baseParams, commandName string, deprecated *string
 	var key string
 	var sessionID string
 	var sessionKey string
+	var name string
 	var deprecatedMsg string
 	if deprecated != nil {
 		deprecatedMsg = *deprecated
 	}
 	importCmd := &cobra.Command{
-		Use:        fmt.Sprintf("%s [FILE]", commandName),
-		Short:      "Import a data file to Foxglove Data Platform",
+		Use:   fmt.Sprintf("%s [FILE]", commandName),
+		Short: "Import a data file to Foxglove Data Platform",
+		Long: `Import a ROS 1 bag or MCAP file to Foxglove Data Platform.
+
+Pass - as the file to read from standard input, naming the recording with
+--filename:
+
+  ssh robot cat log.mcap | foxglove data import - --filename log.mcap --device-name robot`,
 		Args:       cobra.ExactArgs(1),
 		Deprecated: deprecatedMsg,
 		Run: func(cmd *cobra.Command, args []string) {
 			if edgeRecordingID != "" {
-				err := importFromEdge(params.baseURL, *params.clientID, params.token, params.userAgent, edgeRecordingID)
+				err := importFromEdge(cmd.Context(), params.baseURL, *params.clientID, params.token, params.userAgent, edgeRecordingID)
 				if err != nil {
 					dief("Failed to import edge recording: %s", err)
 				}
@@ -74,8 +119,14 @@ func newImportCommand(params *baseParams, commandName string, deprecated *string
 				dief("%s", err)
 			}
 
+			// The default device only applies if the device wasn't named.
+			if deviceID == "" && deviceName == "" {
+				deviceID = profileSetting("default_device_id")
+			}
+
 			filename := args[0]
 			err := executeImport(
+				cmd.Context(),
 				params.baseURL,
 				*params.clientID,
 				projectID,
@@ -85,7 +136,8 @@ func newImportCommand(params *baseParams, commandName string, deprecated *string
 				sessionID,
 				sessionKey,
 				filename,
-				viper.GetString("bearer_token"),
+				name,
+				params.token,
 				params.userAgent,
 			)
 			if err != nil {
@@ -94,13 +146,14 @@ func newImportCommand(params *baseParams, commandName string, deprecated *string
 		},
 	}
 	importCmd.InheritedFlags()
-	importCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when using --session-key)")
-	importCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID")
+	importCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when using --session-key)")
+	importCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID (default: the default_device_id config key, unless --device-name is given)")
 	importCmd.PersistentFlags().StringVarP(&deviceName, "device-name", "", "", "Device name")
 	importCmd.PersistentFlags().StringVarP(&key, "key", "", "", "Recording key")
 	importCmd.PersistentFlags().StringVarP(&sessionID, "session-id", "", "", "Session ID")
 	importCmd.PersistentFlags().StringVarP(&sessionKey, "session-key", "", "", "Session key")
 	importCmd.PersistentFlags().StringVarP(&edgeRecordingID, "edge-recording-id", "", "", "Edge recording ID")
+	importCmd.PersistentFlags().StringVarP(&name, "filename", "", "", "Name to give the imported recording (required when importing from stdin)")
 	AddDeviceAutocompletion(importCmd, params)
 	return importCmd, nil
 }
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/mcap/go/mcap"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,6 +20,7 @@ func TestImportCommand(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		err = executeImport(
+			ctx,
 			sv.BaseURL(),
 			"abc",
 			"prj_1234abcd",
@@ -27,6 +31,7 @@ func TestImportCommand(t *testing.T) {
 			"",
 			"../testdata/gps.bag",
 			"",
+			"",
 			"user-agent",
 		)
 		assert.ErrorIs(t, err, api.ErrForbidden)
@@ -37,9 +42,10 @@ func TestImportCommand(t *testing.T) {
 		sv, err := api.NewMockServer(ctx)
 		assert.Nil(t, err)
 		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
-		token, err := client.SignIn("client-id")
+		token, err := client.SignIn(ctx, "client-id")
 		assert.Nil(t, err)
 		err = executeImport(
+			ctx,
 			sv.BaseURL(),
 			"abc",
 			"prj_1234abcd",
@@ -49,9 +55,63 @@ func TestImportCommand(t *testing.T) {
 			"",
 			"",
 			"../testdata/gps.bag",
+			"",
 			token,
 			"user-agent",
 		)
 		assert.Equal(t, "Device not registered with this organization", err.Error())
 	})
+	t.Run("imports from stdin", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		sv, err := api.NewMockServer(ctx)
+		assert.Nil(t, err)
+		client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+		token, err := client.SignIn(ctx, "client-id")
+		assert.Nil(t, err)
+		buf := &bytes.Buffer{}
+		w, err := mcap.NewWriter(buf, &mcap.WriterOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, w.Close())
+		data := buf.Bytes()
+		stdin = bytes.NewReader(data)
+		t.Cleanup(func() { stdin = os.Stdin })
+		err = executeImport(
+			ctx,
+			sv.BaseURL(),
+			"abc",
+			"prj_1234abcd",
+			"test-device",
+			"",
+			"",
+			"",
+			"",
+			"-",
+			"piped.mcap",
+			token,
+			"user-agent",
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, data, sv.Uploads["device_id=test-device/piped.mcap"])
+	})
+	t.Run("requires a filename when importing from stdin", func(t *testing.T) {
+		stdin = bytes.NewReader(mcap.Magic)
+		t.Cleanup(func() { stdin = os.Stdin })
+		err := executeImport(
+			ctx,
+			"http://localhost",
+			"abc",
+			"prj_1234abcd",
+			"test-device",
+			"",
+			"",
+			"",
+			"",
+			"-",
+			"",
+			"",
+			"user-agent",
+		)
+		assert.ErrorContains(t, err, "--filename is required")
+	})
 }
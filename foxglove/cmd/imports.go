@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
@@ -22,7 +21,7 @@ func newListImportsCommand(params *baseParams) *cobra.Command {
 		Short:      "List imports for a device",
 		Deprecated: "use 'recordings list' instead.",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
@@ -45,6 +44,7 @@ func newListImportsCommand(params *baseParams) *cobra.Command {
 			}
 			format = ResolveFormat(format, isJsonFormat)
 			err = renderList(
+				cmd.Context(),
 				os.Stdout,
 				&api.ImportsRequest{
 					DeviceID:       deviceID,
@@ -58,8 +58,7 @@ func newListImportsCommand(params *baseParams) *cobra.Command {
 				format,
 			)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list imports: %s\n", err)
-				os.Exit(1)
+				dief("Failed to list imports: %s", err)
 			}
 		},
 	}
@@ -1,25 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 
-	"github.com/foxglove/foxglove-cli/foxglove/api"
 	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
 
 	"github.com/spf13/cobra"
 )
 
-func executeInfo(baseURL, clientID, token, userAgent string) error {
+func executeInfo(ctx context.Context, baseURL, clientID, token, userAgent string) error {
+	fmt.Printf("Profile: %s\n", activeProfile())
 	isUsingApiKey := TokenIsApiKey(token)
 	if isUsingApiKey {
 		fmt.Println("Authenticated with API key")
 		return nil
 	}
 
-	client := api.NewRemoteFoxgloveClient(baseURL, clientID, token, userAgent)
-	me, err := client.Me()
+	client := newClient(baseURL, clientID, token, userAgent)
+	me, err := client.Me(ctx)
 	if err != nil {
 		return err
 	}
@@ -50,10 +51,10 @@ func newInfoCommand(params *baseParams) *cobra.Command {
 		Use:   "info",
 		Short: "Display information about the currently authenticated user",
 		Run: func(cmd *cobra.Command, args []string) {
-			if !IsAuthenticated() {
+			if !IsAuthenticated(params.token) {
 				dief("Not signed in. Run `foxglove auth login` or `foxglove auth configure-api-key` to continue.")
 			}
-			err := executeInfo(params.baseURL, *params.clientID, params.token, params.userAgent)
+			err := executeInfo(cmd.Context(), params.baseURL, *params.clientID, params.token, params.userAgent)
 			if err != nil {
 				dief("Info command failed: %s", err)
 			}
@@ -1,41 +1,165 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/foxglove-cli/foxglove/util/qrcode"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
-func executeLogin(baseURL, clientID, userAgent string, authDelegate api.AuthDelegate) error {
-	ctx := context.Background()
-	client := api.NewRemoteFoxgloveClient(baseURL, clientID, "", userAgent)
-	bearerToken, err := api.Login(ctx, client, authDelegate)
+// executeLogin logs in to the API at baseURL and stores the token in the
+// active profile; see configureAuth for overwriteBaseURL.
+func executeLogin(ctx context.Context, baseURL string, overwriteBaseURL bool, clientID, userAgent string, authDelegate api.AuthDelegate, opts ...api.LoginOption) error {
+	client := newClient(baseURL, clientID, "", userAgent)
+	bearerToken, err := api.Login(ctx, client, authDelegate, opts...)
 	if err != nil {
 		return err
 	}
-	err = configureAuth(bearerToken, baseURL, TokenSession)
+	err = configureAuth(bearerToken, baseURL, overwriteBaseURL, TokenSession)
 	if err != nil {
 		return fmt.Errorf("Failed to configure auth: %w", err)
 	}
 	return nil
 }
 
+// sessionLogin logs in to the active profile again once its session has
+// expired. It is set once the config has been read.
+var sessionLogin func() error
+
+// offerLogin offers to log in again after a command failed because the
+// session expired, and reports whether the user did. Only users at a
+// terminal are asked; scripts get exitSessionExpired straight away.
+var offerLogin = func() bool {
+	if sessionLogin == nil || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	return reauthenticate(os.Stdin, os.Stderr, sessionLogin)
+}
+
+// reauthenticate asks on out whether to log in again, reading the answer
+// from in, and calls login if the user agrees. It reports whether the user
+// is now logged in.
+func reauthenticate(in io.Reader, out io.Writer, login func() error) bool {
+	fmt.Fprint(out, "Your session has expired. Log in again? [Y/n] ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+	default:
+		return false
+	}
+	if err := login(); err != nil {
+		fmt.Fprintf(out, "Login failed: %s\n", err)
+		return false
+	}
+	fmt.Fprintln(out, "Logged in. Run the command again to retry it.")
+	return true
+}
+
+// loginPromptJSON is the form of the login prompt printed with --json.
+type loginPromptJSON struct {
+	VerificationURI         string `json:"verificationUri"`
+	VerificationURIComplete string `json:"verificationUriComplete"`
+	UserCode                string `json:"userCode"`
+	ExpiresAt               string `json:"expiresAt,omitempty"`
+}
+
+// printLoginPromptJSON writes the login prompt to w as a line of JSON, so
+// that a script can relay it to the user.
+func printLoginPromptJSON(w io.Writer) func(api.LoginPrompt) {
+	return func(prompt api.LoginPrompt) {
+		out := loginPromptJSON{
+			VerificationURI:         prompt.VerificationURI,
+			VerificationURIComplete: prompt.VerificationURIComplete,
+			UserCode:                prompt.UserCode,
+		}
+		if !prompt.ExpiresAt.IsZero() {
+			out.ExpiresAt = prompt.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+// printLoginPrompt writes the login prompt to w. If no browser was opened,
+// e.g. on a remote machine, the link is also shown as a QR code to scan with
+// a phone.
+func printLoginPrompt(w io.Writer) func(api.LoginPrompt) {
+	return func(prompt api.LoginPrompt) {
+		if prompt.UserCode == "" {
+			fmt.Fprintln(w, "Complete the login in your browser. If no window opens, open the following link:")
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, prompt.VerificationURIComplete)
+			return
+		}
+		if prompt.BrowserOpened {
+			fmt.Fprintln(w, "If no window opens, copy/paste the following link into your browser:")
+		} else {
+			fmt.Fprintln(w, "Open the following link in a browser, or scan the QR code:")
+			if code, err := qrcode.Encode(prompt.VerificationURIComplete); err == nil {
+				fmt.Fprintln(w)
+				fmt.Fprint(w, code)
+			}
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, prompt.VerificationURIComplete)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Verify this code and click 'Authorize' to complete login: %s\n", prompt.UserCode)
+		if !prompt.ExpiresAt.IsZero() {
+			fmt.Fprintf(w, "The code expires in %s.\n", time.Until(prompt.ExpiresAt).Round(time.Minute))
+		}
+	}
+}
+
 func newLoginCommand(params *baseParams) *cobra.Command {
 	var baseURL string
+	var noBrowser bool
+	var deviceCode bool
+	var isJsonOutput bool
 	loginCmd := &cobra.Command{
 		Use:   "login",
 		Short: "Log in to Foxglove Data Platform",
+		Long: `Log in through the browser. If a browser is available, the login page is
+opened in it and the CLI receives the result directly. Otherwise, if the
+login isn't completed in the browser within a few minutes, or with
+--device-code, a link and code are printed to confirm on any device, e.g. by
+scanning the QR code with a phone. Pass --no-browser to never open a browser.`,
+		Annotations: map[string]string{createsProfile: "true"},
 		Run: func(cmd *cobra.Command, args []string) {
-			err := executeLogin(baseURL, *params.clientID, params.userAgent, &api.PlatformAuthDelegate{})
+			opts := []api.LoginOption{api.WithLoginPrompt(printLoginPrompt(os.Stderr))}
+			if isJsonOutput {
+				// A script relaying the prompt needs a code the user can
+				// confirm elsewhere.
+				opts = []api.LoginOption{api.WithLoginPrompt(printLoginPromptJSON(os.Stdout)), api.WithDeviceCode()}
+			}
+			if deviceCode {
+				opts = append(opts, api.WithDeviceCode())
+			}
+			if noBrowser {
+				opts = append(opts, api.WithoutBrowser())
+			}
+			err := executeLogin(cmd.Context(), baseURL, cmd.Flags().Changed("base-url"), *params.clientID, params.userAgent, &api.PlatformAuthDelegate{}, opts...)
 			if err != nil {
 				dief("Login failed: %s", err)
 			}
 		},
 	}
 	loginCmd.InheritedFlags()
-	loginCmd.PersistentFlags().StringVarP(&baseURL, "base-url", "", defaultBaseURL, "API server")
+	loginCmd.PersistentFlags().StringVarP(&baseURL, "base-url", "", defaultString(profileSetting("base_url"), defaultBaseURL), "API server")
+	loginCmd.PersistentFlags().BoolVarP(&noBrowser, "no-browser", "", false, "don't open the login page in a browser. Implies --device-code")
+	loginCmd.PersistentFlags().BoolVarP(&deviceCode, "device-code", "", false, "log in by confirming a code, which may be done on another device")
+	loginCmd.PersistentFlags().BoolVarP(&isJsonOutput, "json", "", false, "print the login link and code to stdout as JSON, for automation. Implies --device-code")
 	return loginCmd
 }
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +21,7 @@ func TestLoginCommand(t *testing.T) {
 	configfile := "./test-config.yaml"
 	err = initConfig(&configfile)
 	assert.Nil(t, err)
-	err = executeLogin(sv.BaseURL(), "client-id", "test-app", &api.MockAuthDelegate{})
+	err = executeLogin(ctx, sv.BaseURL(), true, "client-id", "test-app", &api.MockAuthDelegate{})
 	assert.Nil(t, err)
 	assert.NotEmpty(t, sv.BearerTokens)
 	m := make(map[string]string)
@@ -29,3 +33,69 @@ func TestLoginCommand(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotEmpty(t, m["bearer_token"])
 }
+
+func TestLoginPrompt(t *testing.T) {
+	prompt := api.LoginPrompt{
+		VerificationURI:         "https://app.foxglove.dev/activate",
+		VerificationURIComplete: "https://app.foxglove.dev/activate?user_code=ABCD-EFGH",
+		UserCode:                "ABCD-EFGH",
+		ExpiresAt:               time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	t.Run("prints json for automation", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		printLoginPromptJSON(buf)(prompt)
+		assert.JSONEq(t, `{
+			"verificationUri": "https://app.foxglove.dev/activate",
+			"verificationUriComplete": "https://app.foxglove.dev/activate?user_code=ABCD-EFGH",
+			"userCode": "ABCD-EFGH",
+			"expiresAt": "2024-01-02T03:04:05Z"
+		}`, buf.String())
+	})
+	t.Run("shows a qr code if no browser was opened", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		printLoginPrompt(buf)(prompt)
+		assert.Contains(t, buf.String(), "▀")
+		assert.Contains(t, buf.String(), prompt.VerificationURIComplete)
+
+		buf.Reset()
+		prompt.BrowserOpened = true
+		printLoginPrompt(buf)(prompt)
+		assert.NotContains(t, buf.String(), "▀")
+		assert.Contains(t, buf.String(), "ABCD-EFGH")
+	})
+	t.Run("asks only for the login in the browser if there is no code", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		printLoginPrompt(buf)(api.LoginPrompt{VerificationURIComplete: "http://localhost/authorize", BrowserOpened: true})
+		assert.Contains(t, buf.String(), "Complete the login in your browser")
+		assert.NotContains(t, buf.String(), "Verify this code")
+	})
+}
+
+func TestReauthenticate(t *testing.T) {
+	cases := []struct {
+		assertion string
+		answer    string
+		loginErr  error
+		loggedIn  bool
+		calls     int
+	}{
+		{"logs in by default", "\n", nil, true, 1},
+		{"logs in if the user agrees", "yes\n", nil, true, 1},
+		{"declines", "n\n", nil, false, 0},
+		{"declines without input", "", nil, false, 0},
+		{"reports login failures", "y\n", errors.New("denied"), false, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.assertion, func(t *testing.T) {
+			calls := 0
+			out := &bytes.Buffer{}
+			loggedIn := reauthenticate(strings.NewReader(c.answer), out, func() error {
+				calls++
+				return c.loginErr
+			})
+			assert.Equal(t, c.loggedIn, loggedIn)
+			assert.Equal(t, c.calls, calls)
+			assert.Contains(t, out.String(), "Your session has expired")
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/cobra"
+)
+
+// executeLogout removes the active profile's token from the config file and
+// the credential helper. A session token is first revoked on the server, so
+// that no copy of it remains usable; API keys are only forgotten, since they
+// are managed in the Foxglove app and may be in use elsewhere. Failing to
+// revoke the session is reported to w but does not stop the logout.
+func executeLogout(ctx context.Context, baseURL, clientID, token, userAgent string, w io.Writer) error {
+	var revokeErr error
+	if token != "" && !TokenIsApiKey(token) {
+		client := newClient(baseURL, clientID, token, userAgent)
+		revokeErr = client.SignOut(ctx)
+		// The server may not support revocation, or the session may already
+		// have ended.
+		if errors.Is(revokeErr, api.ErrNotFound) || errors.Is(revokeErr, api.ErrUnauthorized) {
+			revokeErr = nil
+		}
+	}
+	if err := eraseToken(baseURL, activeProfile()); err != nil {
+		return err
+	}
+	for _, key := range []string{"bearer_token", "auth_type"} {
+		if err := unsetConfigKey(profileKey(key)); err != nil {
+			return fmt.Errorf("Failed to write config: %w", err)
+		}
+	}
+	if revokeErr != nil {
+		fmt.Fprintf(w, "Warning: the session could not be revoked on the server: %s\n", revokeErr)
+	}
+	if token == "" {
+		fmt.Fprintln(w, "Not logged in.")
+	} else {
+		fmt.Fprintln(w, "Logged out.")
+	}
+	return nil
+}
+
+func newLogoutCommand(params *baseParams) *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Log out and remove the stored credentials",
+		Long: `Remove the token of the active profile from the config file or credential
+helper. A session created by 'auth login' is also revoked on the server. The
+profile's other settings, such as its base URL, are kept.`,
+		// The token is resolved here, so that the credentials are removed
+		// even if the helper can't read them.
+		Annotations: map[string]string{noCredentials: "true"},
+		Run: func(cmd *cobra.Command, args []string) {
+			token, err := resolveToken(params.baseURL, true)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read credentials: %s\n", err)
+			}
+			err = executeLogout(cmd.Context(), params.baseURL, *params.clientID, token, params.userAgent, os.Stderr)
+			if err != nil {
+				dief("Logout failed: %s", err)
+			}
+		},
+	}
+}
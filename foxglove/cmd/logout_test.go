@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogoutCommand(t *testing.T) {
+	ctx := context.Background()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+
+	t.Run("revokes the session and removes the token", func(t *testing.T) {
+		withTestConfig(t)
+		assert.Nil(t, executeLogin(ctx, sv.BaseURL(), true, "client-id", "test-app", &api.MockAuthDelegate{}))
+		token := profileSetting("bearer_token")
+		assert.Contains(t, sv.BearerTokens, token)
+
+		out := &bytes.Buffer{}
+		assert.Nil(t, executeLogout(ctx, sv.BaseURL(), "client-id", token, "test-app", out))
+		assert.Equal(t, "Logged out.\n", out.String())
+		assert.NotContains(t, sv.BearerTokens, token)
+		assert.Empty(t, profileSetting("bearer_token"))
+		assert.False(t, viper.IsSet("auth_type"))
+		assert.Equal(t, sv.BaseURL(), profileSetting("base_url"))
+	})
+	t.Run("forgets API keys without revoking them", func(t *testing.T) {
+		withTestConfig(t)
+		assert.Nil(t, configureAuth("fox_sk_secret", sv.BaseURL(), true, TokenApiKey))
+		before := sv.RequestCount("/v1/signout")
+
+		out := &bytes.Buffer{}
+		assert.Nil(t, executeLogout(ctx, sv.BaseURL(), "client-id", "fox_sk_secret", "test-app", out))
+		assert.Equal(t, before, sv.RequestCount("/v1/signout"))
+		assert.Empty(t, profileSetting("bearer_token"))
+	})
+	t.Run("removes the token even if it can't be revoked", func(t *testing.T) {
+		withTestConfig(t)
+		assert.Nil(t, configureAuth("session-token", "http://127.0.0.1:1", true, TokenSession))
+
+		out := &bytes.Buffer{}
+		assert.Nil(t, executeLogout(ctx, "http://127.0.0.1:1", "client-id", "session-token", "test-app", out))
+		assert.Contains(t, out.String(), "could not be revoked")
+		assert.Empty(t, profileSetting("bearer_token"))
+	})
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+)
+
+// exportWindow is a time range downloaded by one request of a parallel export.
+// Its start and end are inclusive.
+type exportWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// splitExportWindows divides [start, end] into n windows of equal length. The
+// end of each window is the start of the next; messages logged exactly then
+// are downloaded by both, and the stitcher keeps the earlier copy.
+func splitExportWindows(start, end time.Time, n int) []exportWindow {
+	step := end.Sub(start) / time.Duration(n)
+	if n < 2 || step <= 0 {
+		return []exportWindow{{start, end}}
+	}
+	windows := make([]exportWindow, n)
+	for i := range windows {
+		windows[i].start = start.Add(time.Duration(i) * step)
+		windows[i].end = start.Add(time.Duration(i+1) * step)
+	}
+	windows[n-1].end = end
+	return windows
+}
+
+// exportBounds returns the time range of an export. Where the request has no
+// start or end, it is taken from the recording or session being exported.
+func exportBounds(ctx context.Context, client *api.FoxgloveClient, request *api.StreamRequest) (time.Time, time.Time, error) {
+	if request.Start != nil && request.End != nil {
+		return *request.Start, *request.End, nil
+	}
+	var ranges []exportWindow
+	switch {
+	case request.RecordingID != "":
+		recording, err := client.Recording(ctx, request.RecordingID)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to look up recording: %w", err)
+		}
+		ranges = appendRange(ranges, recording.Start, recording.End)
+	case request.SessionID != "" || request.SessionKey != "":
+		session, err := client.GetSession(ctx, defaultString(request.SessionID, request.SessionKey), request.ProjectID)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to look up session: %w", err)
+		}
+		for _, recording := range session.Recordings {
+			ranges = appendRange(ranges, recording.Start, recording.End)
+		}
+	default:
+		return time.Time{}, time.Time{}, errors.New("--parallel needs --start and --end, unless a recording ID or session is exported")
+	}
+	if len(ranges) == 0 {
+		return time.Time{}, time.Time{}, errors.New("the time range of the export is unknown. Use --start and --end with --parallel")
+	}
+	bounds := ranges[0]
+	for _, r := range ranges[1:] {
+		if r.start.Before(bounds.start) {
+			bounds.start = r.start
+		}
+		if r.end.After(bounds.end) {
+			bounds.end = r.end
+		}
+	}
+	if request.Start != nil {
+		bounds.start = *request.Start
+	}
+	if request.End != nil {
+		bounds.end = *request.End
+	}
+	return bounds.start, bounds.end, nil
+}
+
+// appendRange appends the range of a recording to ranges, unless its start or
+// end is unknown.
+func appendRange(ranges []exportWindow, start, end string) []exportWindow {
+	s, err := time.Parse(time.RFC3339Nano, start)
+	if err != nil {
+		return ranges
+	}
+	e, err := time.Parse(time.RFC3339Nano, end)
+	if err != nil {
+		return ranges
+	}
+	return append(ranges, exportWindow{s, e})
+}
+
+// downloadWindows splits the export of a job into time windows and downloads
+// them concurrently, each as downloadWindow would. A job that was started
+// before keeps its windows. The files are returned in time order.
+func downloadWindows(
+	ctx context.Context,
+	job *exportJob,
+	baseURL string,
+	clientID string,
+	bearerToken string,
+	userAgent string,
+	opts exportOptions,
+) ([]partialFile, error) {
+	if !validOutputFormat(job.Request.OutputFormat) {
+		return nil, ErrInvalidFormat
+	}
+	client := newClient(baseURL, clientID, bearerToken, userAgent)
+	if len(job.Windows) == 0 {
+		start, end, err := exportBounds(ctx, client, &job.Request)
+		if err != nil {
+			return nil, err
+		}
+		err = job.update(func() {
+			for _, window := range splitExportWindows(start, end, opts.parallel) {
+				request := job.Request
+				request.Start, request.End = &window.start, &window.end
+				request.OutputFormat = downloadFormat(request.OutputFormat)
+				job.Windows = append(job.Windows, &jobWindow{Request: request})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	windows := job.Windows
+	debugf("exporting %d windows", len(windows))
+	deviceID, deviceName := resolveDeviceName(ctx, client, job.Request.ProjectID, job.Request.DeviceID, job.Request.DeviceName)
+
+	// The first window to fail cancels the others.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	progress := newWindowProgress(os.Stderr, len(windows))
+	results := make([][]partialFile, len(windows))
+	var wg sync.WaitGroup
+	for i, window := range windows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tmpfiles, err := downloadWindow(ctx, job, window, opts.compression, func(ctx context.Context, w io.Writer, req *api.StreamRequest) error {
+				req.DeviceID, req.DeviceName = deviceID, deviceName
+				debugf("exporting window %d with request: %+v", i+1, req)
+				return api.Export(ctx, w, client, req, api.WithProgress(progress.report(i)))
+			})
+			if err != nil {
+				cancel(err)
+				return
+			}
+			results[i] = tmpfiles
+			progress.finish(i)
+		}()
+	}
+	wg.Wait()
+	progress.close()
+
+	tmpfiles := []partialFile{}
+	for _, files := range results {
+		tmpfiles = append(tmpfiles, files...)
+	}
+	if err := context.Cause(ctx); err != nil {
+		closePartialFiles(tmpfiles)
+		return nil, err
+	}
+	return tmpfiles, nil
+}
+
+// windowProgress shows the bytes downloaded for each window of a parallel
+// export on a single line.
+type windowProgress struct {
+	mtx     sync.Mutex
+	w       io.Writer
+	bytes   []int64
+	done    []bool
+	drawn   time.Time
+	lineLen int
+}
+
+func newWindowProgress(w io.Writer, n int) *windowProgress {
+	return &windowProgress{
+		w:     w,
+		bytes: make([]int64, n),
+		done:  make([]bool, n),
+	}
+}
+
+// report returns a ProgressFunc for one request of window i. A window may take
+// several requests, whose bytes are added up.
+func (p *windowProgress) report(i int) api.ProgressFunc {
+	var last int64
+	return func(transferred, _ int64) {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		p.bytes[i] += transferred - last
+		last = transferred
+		if time.Since(p.drawn) > 100*time.Millisecond {
+			p.draw()
+		}
+	}
+}
+
+// finish marks window i as downloaded.
+func (p *windowProgress) finish(i int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.done[i] = true
+	p.draw()
+}
+
+// close draws the final progress and ends the line.
+func (p *windowProgress) close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+func (p *windowProgress) draw() {
+	parts := make([]string, len(p.bytes))
+	for i, n := range p.bytes {
+		parts[i] = fmt.Sprintf("%d/%d %s", i+1, len(p.bytes), api.HumanReadableBytes(n))
+		if p.done[i] {
+			parts[i] += " done"
+		}
+	}
+	line := "exporting: " + strings.Join(parts, ", ")
+	// Pad over the remains of a longer previous line.
+	padding := ""
+	if n := p.lineLen - len(line); n > 0 {
+		padding = strings.Repeat(" ", n)
+	}
+	fmt.Fprintf(p.w, "\r%s%s", line, padding)
+	p.lineLen = len(line)
+	p.drawn = time.Now()
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/mcap/go/mcap"
+	"github.com/foxglove/mcap/go/mcap/readopts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitExportWindows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Second)
+	windows := splitExportWindows(start, end, 3)
+	assert.Len(t, windows, 3)
+	assert.Equal(t, start, windows[0].start)
+	for i := 1; i < len(windows); i++ {
+		assert.Equal(t, windows[i-1].end, windows[i].start)
+	}
+	assert.Equal(t, end, windows[2].end)
+
+	assert.Equal(t, []exportWindow{{start, end}}, splitExportWindows(start, end, 1))
+	assert.Equal(t, []exportWindow{{start, start}}, splitExportWindows(start, start, 4))
+}
+
+func TestExportBounds(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+	token, err := client.SignIn(ctx, "client-id")
+	assert.Nil(t, err)
+	client = api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", token, "test-app")
+	recordingStart := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	start, end, err := exportBounds(ctx, client, &api.StreamRequest{RecordingID: "rec_0001"})
+	assert.Nil(t, err)
+	assert.True(t, recordingStart.Equal(start))
+	assert.True(t, recordingStart.Add(30*time.Minute).Equal(end))
+
+	later := recordingStart.Add(10 * time.Minute)
+	start, _, err = exportBounds(ctx, client, &api.StreamRequest{RecordingID: "rec_0001", Start: &later})
+	assert.Nil(t, err)
+	assert.Equal(t, later, start)
+
+	_, _, err = exportBounds(ctx, client, &api.StreamRequest{DeviceID: "test-device", Start: &later})
+	assert.ErrorContains(t, err, "--parallel needs --start and --end")
+}
+
+func TestParallelExport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+	token, err := client.SignIn(ctx, "client-id")
+	assert.Nil(t, err)
+
+	// Messages are logged every 100ms, including exactly at the edges of the
+	// windows, which are served to both windows that share them.
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logTime := func(i int) uint64 {
+		return uint64(base.Add(time.Duration(i) * 100 * time.Millisecond).UnixNano())
+	}
+	sv.StreamData = func(req api.StreamRequest) []byte {
+		buf := &bytes.Buffer{}
+		writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{Chunked: true, ChunkSize: 1024})
+		assert.Nil(t, err)
+		assert.Nil(t, writer.WriteHeader(&mcap.Header{}))
+		assert.Nil(t, writer.WriteSchema(&mcap.Schema{ID: 1, Name: "s", Encoding: "ros1msg"}))
+		assert.Nil(t, writer.WriteChannel(&mcap.Channel{ID: 0, SchemaID: 1, Topic: "/t"}))
+		for i := 0; i < 100; i++ {
+			if lt := logTime(i); lt >= uint64(req.Start.UnixNano()) && lt <= uint64(req.End.UnixNano()) {
+				assert.Nil(t, writer.WriteMessage(&mcap.Message{LogTime: lt, Data: []byte{byte(i)}}))
+			}
+		}
+		assert.Nil(t, writer.Close())
+		return buf.Bytes()
+	}
+
+	start := base
+	end := base.Add(9900 * time.Millisecond)
+	output := filepath.Join(t.TempDir(), "output.mcap")
+	err = doExport(
+		ctx,
+		output,
+		sv.BaseURL(),
+		"abc",
+		token,
+		"user-agent",
+		&api.StreamRequest{
+			DeviceID:     "test-device",
+			Start:        &start,
+			End:          &end,
+			OutputFormat: "mcap0",
+		},
+		exportOptions{tmpdir: t.TempDir(), compression: mcap.CompressionLZ4, parallel: 3},
+	)
+	assert.Nil(t, err)
+
+	f, err := os.Open(output)
+	assert.Nil(t, err)
+	defer f.Close()
+	reader, err := mcap.NewReader(f)
+	assert.Nil(t, err)
+	it, err := reader.Messages(readopts.UsingIndex(false))
+	assert.Nil(t, err)
+	logTimes := []uint64{}
+	for {
+		_, _, message, err := it.Next(nil)
+		if err != nil {
+			break
+		}
+		logTimes = append(logTimes, message.LogTime)
+	}
+	expected := []uint64{}
+	for i := 0; i < 100; i++ {
+		expected = append(expected, logTime(i))
+	}
+	assert.Equal(t, expected, logTimes)
+}
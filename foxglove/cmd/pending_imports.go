@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newPendingImportsCommand(params *baseParams) *cobra.Command {
@@ -33,15 +31,14 @@ func newPendingImportsCommand(params *baseParams) *cobra.Command {
 			if err := validateSessionKeyRequiresProjectID(sessionKey, projectID); err != nil {
 				dief("%s", err)
 			}
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			parsedUpdatedSince, err := maybeConvertToRFC3339(updatedSince)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to parse value of --updated-since: %s\n", err)
-				os.Exit(1)
+				dief("Failed to parse value of --updated-since: %s", err)
 			}
 			var hasProjectID string
 			if withoutProject {
@@ -49,6 +46,7 @@ func newPendingImportsCommand(params *baseParams) *cobra.Command {
 			}
 			format = ResolveFormat(format, isJsonFormat)
 			err = renderList(
+				cmd.Context(),
 				os.Stdout,
 				api.PendingImportsRequest{
 					RequestId:       requestId,
@@ -70,13 +68,12 @@ func newPendingImportsCommand(params *baseParams) *cobra.Command {
 				format,
 			)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list pending imports: %s\n", err)
-				os.Exit(1)
+				dief("Failed to list pending imports: %s", err)
 			}
 		},
 	}
 	pendingImportsCmd.InheritedFlags()
-	pendingImportsCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when using --session-key)")
+	pendingImportsCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when using --session-key)")
 	pendingImportsCmd.PersistentFlags().BoolVarP(&withoutProject, "without-project", "", false, "Filter to pending imports without a project")
 	pendingImportsCmd.PersistentFlags().StringVarP(&requestId, "request-id", "", "", "Request ID")
 	pendingImportsCmd.PersistentFlags().StringVarP(&key, "key", "", "", "Key")
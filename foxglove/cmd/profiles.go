@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultProfile names the credentials stored at the top level of the config
+// file, which predate profiles.
+const defaultProfile = "default"
+
+// profileKeys are the config keys held separately by each profile.
+var profileKeys = []string{"base_url", "bearer_token", "auth_type", "default_project_id", "default_device_id"}
+
+// createsProfile annotates commands that store credentials in the active
+// profile, creating it if necessary.
+const createsProfile = "createsProfile"
+
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// profileFlag holds the value of --profile. It is read from the command line
+// before cobra parses it, since flag defaults are drawn from the profile.
+var profileFlag string
+
+// profileFromArgs returns the value of a --profile flag among args.
+func profileFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--profile="); ok {
+			return value
+		}
+		if arg == "--profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// activeProfile returns the profile selected by --profile, FOXGLOVE_PROFILE or
+// `auth profiles switch`, in that order of precedence.
+func activeProfile() string {
+	name, _ := lookupActiveProfile()
+	return name
+}
+
+func profilePrefix(name string) string {
+	if name == defaultProfile {
+		return ""
+	}
+	return "profiles." + name + "."
+}
+
+// profileKey returns the viper key of a setting in the active profile if it
+// is held per profile, and the key unchanged otherwise.
+func profileKey(key string) string {
+	for _, k := range profileKeys {
+		if k == key {
+			return profilePrefix(activeProfile()) + key
+		}
+	}
+	return key
+}
+
+// profileSetting returns a setting from the active profile, or from the
+// environment variable that overrides it.
+func profileSetting(key string) string {
+	value, _ := lookupProfileSetting(key)
+	return value
+}
+
+// profileExists reports whether a profile has been configured.
+func profileExists(name string) bool {
+	if name == defaultProfile {
+		return true
+	}
+	return viper.IsSet("profiles." + name)
+}
+
+// checkProfile verifies that the active profile exists before cmd runs,
+// unless cmd is one that stores credentials and so creates it.
+func checkProfile(cmd *cobra.Command) error {
+	name := activeProfile()
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+	}
+	if profileExists(name) || cmd.Annotations[createsProfile] == "true" {
+		return nil
+	}
+	return fmt.Errorf("unknown profile %q. Run `foxglove auth login --profile %s` to create it", name, name)
+}
+
+// profileNames returns the configured profiles, default first.
+func profileNames() []string {
+	names := []string{}
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{defaultProfile}, names...)
+}
+
+// updateConfigFile applies update to the settings read from the config file,
+// writes them back and reloads the config. Only the file's own settings are
+// written, so that values from the environment or another config file aren't
+// saved as if the user had set them.
+func updateConfigFile(update func(settings map[string]any)) error {
+	configFile := viper.ConfigFileUsed()
+	file := viper.New()
+	file.SetConfigType("yaml")
+	file.SetConfigFile(configFile)
+	if err := file.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	settings := file.AllSettings()
+	update(settings)
+
+	updated := viper.New()
+	updated.SetConfigType("yaml")
+	if err := updated.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	if err := updated.WriteConfigAs(configFile); err != nil {
+		return err
+	}
+	return viper.ReadInConfig()
+}
+
+// setConfigKey sets a possibly nested key in the config file.
+func setConfigKey(key string, value any) error {
+	return updateConfigFile(func(settings map[string]any) {
+		path := strings.Split(key, ".")
+		parent := settings
+		for _, part := range path[:len(path)-1] {
+			child, ok := parent[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				parent[part] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = value
+	})
+}
+
+// unsetConfigKey removes a possibly nested key from the config file.
+func unsetConfigKey(key string) error {
+	return updateConfigFile(func(settings map[string]any) {
+		path := strings.Split(key, ".")
+		parent := settings
+		for _, part := range path[:len(path)-1] {
+			child, ok := parent[part].(map[string]any)
+			if !ok {
+				return
+			}
+			parent = child
+		}
+		delete(parent, path[len(path)-1])
+	})
+}
+
+func authTypeName(authType AuthType) string {
+	switch authType {
+	case TokenSession:
+		return "session"
+	case TokenApiKey:
+		return "api key"
+	default:
+		return ""
+	}
+}
+
+func newProfilesCommand() *cobra.Command {
+	profilesCmd := &cobra.Command{
+		Use:         "profiles",
+		Short:       "Manage named authentication profiles",
+		Annotations: map[string]string{noCredentials: "true"},
+		Long: `Profiles hold separate credentials, API base URL and default project, e.g.
+for staging and production organizations. Create one by logging in with
+--profile NAME, and select it for a command with --profile NAME or the
+FOXGLOVE_PROFILE environment variable. The credentials stored without a
+profile are known as the "default" profile.`,
+	}
+	profilesCmd.AddCommand(
+		newListProfilesCommand(),
+		newSwitchProfileCommand(),
+		newRemoveProfileCommand(),
+	)
+	return profilesCmd
+}
+
+func newListProfilesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List authentication profiles",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			active := activeProfile()
+			headers := []string{"", "Name", "Base URL", "Auth type", "Project ID"}
+			data := [][]string{}
+			for _, name := range profileNames() {
+				marker := ""
+				if name == active {
+					marker = "*"
+				}
+				prefix := profilePrefix(name)
+				data = append(data, []string{
+					marker,
+					name,
+					defaultString(viper.GetString(prefix+"base_url"), defaultBaseURL),
+					authTypeName(AuthType(viper.GetInt(prefix + "auth_type"))),
+					viper.GetString(prefix + "default_project_id"),
+				})
+			}
+			tw.PrintTable(os.Stdout, headers, data)
+		},
+	}
+}
+
+func newSwitchProfileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:         "switch NAME",
+		Short:       "Make a profile the default for subsequent commands",
+		Annotations: map[string]string{noCredentials: "true"},
+		Args:        cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return profileNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+			if !profileExists(name) {
+				exitf(exitUsage, "Unknown profile %q. Run `foxglove auth login --profile %s` to create it.", name, name)
+			}
+			var err error
+			if name == defaultProfile {
+				err = unsetConfigKey("current_profile")
+			} else {
+				err = setConfigKey("current_profile", name)
+			}
+			if err != nil {
+				dief("Failed to write config: %s", err)
+			}
+			fmt.Fprintf(os.Stderr, "Switched to profile %s\n", name)
+		},
+	}
+}
+
+func newRemoveProfileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a profile and its credentials",
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return profileNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			name := args[0]
+			if !profileExists(name) {
+				exitf(exitUsage, "Unknown profile %q", name)
+			}
+			baseURL := defaultString(viper.GetString(profilePrefix(name)+"base_url"), defaultBaseURL)
+			if err := eraseToken(baseURL, name); err != nil {
+				dief("Failed to remove credentials: %s", err)
+			}
+			if name == defaultProfile {
+				for _, key := range profileKeys {
+					if err := unsetConfigKey(key); err != nil {
+						dief("Failed to write config: %s", err)
+					}
+				}
+			} else {
+				if err := unsetConfigKey("profiles." + name); err != nil {
+					dief("Failed to write config: %s", err)
+				}
+				if viper.GetString("current_profile") == name {
+					if err := unsetConfigKey("current_profile"); err != nil {
+						dief("Failed to write config: %s", err)
+					}
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Removed profile %s\n", name)
+		},
+	}
+}
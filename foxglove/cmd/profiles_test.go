@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+// withTestConfig points viper at an empty config file for the duration of a
+// test, and clears any profile selection.
+func withTestConfig(t *testing.T) string {
+	configfile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(configfile, []byte{}, 0600))
+	viper.Reset()
+	assert.Nil(t, initConfig(&configfile))
+	t.Setenv("FOXGLOVE_PROFILE", "")
+	profileFlag = ""
+	projectConfig, projectConfigFile = viper.New(), ""
+	t.Cleanup(func() {
+		viper.Reset()
+		profileFlag = ""
+		projectConfig, projectConfigFile = viper.New(), ""
+	})
+	return configfile
+}
+
+func TestProfileFromArgs(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"devices", "list"}, ""},
+		{[]string{"devices", "list", "--profile", "staging"}, "staging"},
+		{[]string{"--profile=staging", "devices", "list"}, "staging"},
+		{[]string{"devices", "list", "--", "--profile", "staging"}, ""},
+		{[]string{"devices", "list", "--profile"}, ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, profileFromArgs(c.args), c.args)
+	}
+}
+
+func TestActiveProfile(t *testing.T) {
+	withTestConfig(t)
+	assert.Equal(t, defaultProfile, activeProfile())
+	viper.Set("current_profile", "switched")
+	assert.Equal(t, "switched", activeProfile())
+	t.Setenv("FOXGLOVE_PROFILE", "env")
+	assert.Equal(t, "env", activeProfile())
+	profileFlag = "flag"
+	assert.Equal(t, "flag", activeProfile())
+}
+
+func TestProfiles(t *testing.T) {
+	ctx := context.Background()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+
+	t.Run("login stores credentials in the selected profile", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		contents := "bearer_token: default-token\ndefault_project_id: prj_default\n"
+		assert.Nil(t, os.WriteFile(configfile, []byte(contents), 0600))
+		assert.Nil(t, viper.ReadInConfig())
+		profileFlag = "staging"
+		err := executeLogin(ctx, sv.BaseURL(), true, "client-id", "test-app", &api.MockAuthDelegate{})
+		assert.Nil(t, err)
+
+		config := map[string]any{}
+		data, err := os.ReadFile(configfile)
+		assert.Nil(t, err)
+		assert.Nil(t, yaml.Unmarshal(data, &config))
+		assert.Equal(t, "default-token", config["bearer_token"])
+		staging := config["profiles"].(map[any]any)["staging"].(map[any]any)
+		assert.NotEmpty(t, staging["bearer_token"])
+		assert.Equal(t, sv.BaseURL(), staging["base_url"])
+
+		assert.Equal(t, staging["bearer_token"], profileSetting("bearer_token"))
+		assert.Equal(t, "", profileSetting("default_project_id"))
+		profileFlag = ""
+		assert.Equal(t, "default-token", profileSetting("bearer_token"))
+		assert.Equal(t, "prj_default", profileSetting("default_project_id"))
+	})
+	t.Run("logging in again keeps the profile's base URL", func(t *testing.T) {
+		withTestConfig(t)
+		profileFlag = "staging"
+		configure := func(args ...string) {
+			cmd := newConfigureAPIKeyCommand()
+			cmd.SetArgs(args)
+			assert.Nil(t, cmd.Execute())
+		}
+		configure("--base-url", sv.BaseURL(), "--api-key", "fox_sk_aaa")
+		configure("--api-key", "fox_sk_bbb")
+		assert.Equal(t, sv.BaseURL(), viper.GetString("profiles.staging.base_url"))
+		assert.Equal(t, "fox_sk_bbb", profileSetting("bearer_token"))
+
+		err := executeLogin(ctx, profileSetting("base_url"), false, "client-id", "test-app", &api.MockAuthDelegate{})
+		assert.Nil(t, err)
+		assert.Equal(t, sv.BaseURL(), viper.GetString("profiles.staging.base_url"))
+
+		// An environment override is used to log in, but isn't saved over
+		// the profile's own base URL.
+		t.Setenv("FOXGLOVE_BASE_URL", sv.BaseURL()+"/")
+		configure("--api-key", "fox_sk_ccc")
+		assert.Equal(t, sv.BaseURL(), viper.GetString("profiles.staging.base_url"))
+	})
+	t.Run("lists configured profiles", func(t *testing.T) {
+		withTestConfig(t)
+		viper.Set("profiles.staging.base_url", "https://staging.example.com")
+		viper.Set("profiles.production.bearer_token", "token")
+		assert.Equal(t, []string{"default", "production", "staging"}, profileNames())
+	})
+	t.Run("removing a profile removes only its settings", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		contents := "bearer_token: default-token\nprofiles:\n  staging:\n    bearer_token: staging-token\n  production:\n    bearer_token: production-token\n"
+		assert.Nil(t, os.WriteFile(configfile, []byte(contents), 0600))
+		assert.Nil(t, viper.ReadInConfig())
+		assert.Nil(t, unsetConfigKey("profiles.staging"))
+		assert.Equal(t, []string{"default", "production"}, profileNames())
+		assert.Equal(t, "default-token", viper.GetString("bearer_token"))
+		assert.Equal(t, "production-token", viper.GetString("profiles.production.bearer_token"))
+	})
+	t.Run("removing a key keeps the config file in use", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		assert.Nil(t, os.WriteFile(configfile, []byte("current_profile: staging\nformat: json\n"), 0600))
+		assert.Nil(t, viper.ReadInConfig())
+		assert.Nil(t, unsetConfigKey("current_profile"))
+		assert.Equal(t, configfile, viper.ConfigFileUsed())
+		assert.False(t, viper.IsSet("current_profile"))
+		assert.Equal(t, "json", viper.GetString("format"))
+	})
+	t.Run("rejects unknown profiles except when creating them", func(t *testing.T) {
+		withTestConfig(t)
+		profileFlag = "missing"
+		assert.ErrorContains(t, checkProfile(&cobra.Command{}), `unknown profile "missing"`)
+		login := &cobra.Command{Annotations: map[string]string{createsProfile: "true"}}
+		assert.Nil(t, checkProfile(login))
+		profileFlag = "not/valid"
+		assert.ErrorContains(t, checkProfile(login), "invalid profile name")
+	})
+}
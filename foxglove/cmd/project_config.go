@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// projectConfigName is the name of the project config file, which is found by
+// walking up from the working directory, like git finds .git.
+const projectConfigName = ".foxglove.yaml"
+
+// projectKeys are the settings a project config file may hold. Credentials,
+// and the settings that decide where and how they are sent, are only read
+// from the user config, so that a checked-out repository can't redirect them.
+var projectKeys = []string{
+	"default_project_id",
+	"default_device_id",
+	"format",
+	"export_format",
+	"export_compression",
+	"export_tmpdir",
+	"export_recipes",
+	"timeout",
+	"retry_max_attempts",
+	"time_zone",
+	"color",
+}
+
+// projectConfig holds the settings of the project config file, or nothing if
+// none was found. Its settings are merged over the user config.
+var projectConfig = viper.New()
+
+// projectConfigFile is the path of the project config file, if one was found.
+var projectConfigFile string
+
+func isProjectKey(key string) bool {
+	for _, k := range projectKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func projectOrigin() settingOrigin {
+	return settingOrigin("project file " + projectConfigFile)
+}
+
+// findProjectConfig returns the path of the nearest project config file in dir
+// or its parents.
+func findProjectConfig(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig reads the project config file at path. Settings that may
+// not be held in a project file, or whose values are invalid, are reported to
+// w and ignored.
+func loadProjectConfig(path string, w io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	settings := map[string]any{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	keys := []string{}
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := settings[key]
+		if !isProjectKey(key) {
+			fmt.Fprintf(w, "Warning: ignoring %s in %s: it can only be set in the user config\n", key, path)
+			delete(settings, key)
+			continue
+		}
+		if key == "export_tmpdir" {
+			// The directory must lie within the project, since jobs clean
+			// removes what it finds there.
+			dir := fmt.Sprint(value)
+			if !filepath.IsLocal(dir) {
+				fmt.Fprintf(w, "Warning: ignoring %s in %s: %s is not a relative path within the project\n", key, path, dir)
+				delete(settings, key)
+				continue
+			}
+			value = filepath.Join(filepath.Dir(path), dir)
+			settings[key] = value
+		}
+		for _, schema := range configSchema {
+			if schema.viperKey != key {
+				continue
+			}
+			if err := schema.check(fmt.Sprint(value)); err != nil {
+				fmt.Fprintf(w, "Warning: ignoring %s in %s: %s\n", key, path, err)
+				delete(settings, key)
+			}
+		}
+	}
+	projectConfig = viper.New()
+	if err := projectConfig.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	projectConfigFile = path
+	return nil
+}
+
+// lookupProjectSetting returns a setting from the project config file, if it
+// may be held there and is set.
+func lookupProjectSetting(key string) (string, settingOrigin, bool) {
+	if !isProjectKey(key) || !projectConfig.IsSet(key) {
+		return "", originDefault, false
+	}
+	return projectConfig.GetString(key), projectOrigin(), true
+}
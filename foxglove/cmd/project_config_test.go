@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	assert.Nil(t, os.MkdirAll(nested, 0755))
+
+	_, ok := findProjectConfig(nested)
+	assert.False(t, ok)
+
+	path := filepath.Join(root, projectConfigName)
+	assert.Nil(t, os.WriteFile(path, []byte("format: csv\n"), 0600))
+	found, ok := findProjectConfig(nested)
+	assert.True(t, ok)
+	assert.Equal(t, path, found)
+
+	// A directory of the same name is not a config file.
+	assert.Nil(t, os.Mkdir(filepath.Join(nested, projectConfigName), 0755))
+	found, ok = findProjectConfig(nested)
+	assert.True(t, ok)
+	assert.Equal(t, path, found)
+}
+
+func TestProjectConfig(t *testing.T) {
+	t.Run("is merged over the user config", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		assert.Nil(t, os.WriteFile(configfile, []byte("default_project_id: prj_user\nformat: json\ncolor: never\n"), 0600))
+		assert.Nil(t, viper.ReadInConfig())
+		path := filepath.Join(t.TempDir(), projectConfigName)
+		assert.Nil(t, os.WriteFile(path, []byte("default_project_id: prj_repo\nformat: csv\n"), 0600))
+		assert.Nil(t, loadProjectConfig(path, &bytes.Buffer{}))
+
+		value, origin := lookupProfileSetting("default_project_id")
+		assert.Equal(t, "prj_repo", value)
+		assert.Equal(t, settingOrigin("project file "+path), origin)
+		value, origin = lookupConfigSetting("format")
+		assert.Equal(t, "csv", value)
+		assert.Equal(t, settingOrigin("project file "+path), origin)
+		value, origin = lookupConfigSetting("color")
+		assert.Equal(t, "never", value)
+		assert.Equal(t, settingOrigin("config file "+configfile), origin)
+
+		t.Setenv("FOXGLOVE_PROJECT_ID", "prj_env")
+		assert.Equal(t, "prj_env", profileSetting("default_project_id"))
+	})
+	t.Run("ignores secrets and invalid values", func(t *testing.T) {
+		withTestConfig(t)
+		viper.Set("base_url", "https://api.example.com")
+		path := filepath.Join(t.TempDir(), projectConfigName)
+		contents := "bearer_token: stolen\nbase_url: https://evil.example.com\ncredential_helper: /tmp/x\nformat: yaml\ntime_zone: UTC\n"
+		assert.Nil(t, os.WriteFile(path, []byte(contents), 0600))
+		warnings := &bytes.Buffer{}
+		assert.Nil(t, loadProjectConfig(path, warnings))
+
+		assert.Contains(t, warnings.String(), "ignoring base_url in "+path+": it can only be set in the user config")
+		assert.Contains(t, warnings.String(), "ignoring bearer_token")
+		assert.Contains(t, warnings.String(), "ignoring credential_helper")
+		assert.Contains(t, warnings.String(), "ignoring format")
+		assert.Equal(t, "https://api.example.com", profileSetting("base_url"))
+		assert.Equal(t, "", profileSetting("bearer_token"))
+		assert.Equal(t, "", configSetting("format"))
+		assert.Equal(t, "UTC", configSetting("time_zone"))
+	})
+	t.Run("resolves the tmpdir relative to the project", func(t *testing.T) {
+		withTestConfig(t)
+		dir := t.TempDir()
+		assert.Nil(t, os.Mkdir(filepath.Join(dir, "tmp"), 0755))
+		path := filepath.Join(dir, projectConfigName)
+		assert.Nil(t, os.WriteFile(path, []byte("export_tmpdir: tmp\n"), 0600))
+		assert.Nil(t, loadProjectConfig(path, &bytes.Buffer{}))
+		assert.Equal(t, filepath.Join(dir, "tmp"), configSetting("export_tmpdir"))
+	})
+	t.Run("ignores a tmpdir outside the project", func(t *testing.T) {
+		for _, tmpdir := range []string{t.TempDir(), "../tmp"} {
+			withTestConfig(t)
+			viper.Set("export_tmpdir", "user-tmp")
+			path := filepath.Join(t.TempDir(), projectConfigName)
+			assert.Nil(t, os.WriteFile(path, []byte("export_tmpdir: "+tmpdir+"\n"), 0600))
+			warnings := &bytes.Buffer{}
+			assert.Nil(t, loadProjectConfig(path, warnings))
+			assert.Contains(t, warnings.String(), "ignoring export_tmpdir in "+path)
+			assert.Equal(t, "user-tmp", configSetting("export_tmpdir"))
+		}
+	})
+}
+
+func TestApplyExportRecipe(t *testing.T) {
+	newCommand := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		for _, flag := range exportRecipeFlags {
+			cmd.Flags().String(flag, "", "")
+		}
+		return cmd
+	}
+	configfile := withTestConfig(t)
+	user := "export_recipes:\n  diagnostics:\n    topics: /rosout\n    device_name: user-robot\n  bad:\n    start: yesterday\n"
+	assert.Nil(t, os.WriteFile(configfile, []byte(user), 0600))
+	assert.Nil(t, viper.ReadInConfig())
+
+	cmd := newCommand()
+	assert.Nil(t, applyExportRecipe(cmd, "diagnostics"))
+	assert.Equal(t, "/rosout", cmd.Flag("topics").Value.String())
+	assert.Equal(t, "user-robot", cmd.Flag("device-name").Value.String())
+
+	path := filepath.Join(t.TempDir(), projectConfigName)
+	project := "export_recipes:\n  diagnostics:\n    topics: [/diagnostics, /rosout]\n    output_format: bag1\n"
+	assert.Nil(t, os.WriteFile(path, []byte(project), 0600))
+	assert.Nil(t, loadProjectConfig(path, &bytes.Buffer{}))
+
+	cmd = newCommand()
+	assert.Nil(t, cmd.Flags().Set("output-format", "mcap0"))
+	assert.Nil(t, applyExportRecipe(cmd, "diagnostics"))
+	assert.Equal(t, "/diagnostics,/rosout", cmd.Flag("topics").Value.String())
+	assert.Equal(t, "mcap0", cmd.Flag("output-format").Value.String())
+	assert.Equal(t, "", cmd.Flag("device-name").Value.String())
+
+	assert.ErrorContains(t, applyExportRecipe(newCommand(), "bad"), "unknown setting start")
+	assert.ErrorContains(t, applyExportRecipe(newCommand(), "missing"), "Recipes: bad, diagnostics")
+}
@@ -15,13 +15,14 @@ func newListProjectsCommand(params *baseParams) *cobra.Command {
 		Use:   "list",
 		Short: "List projects",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			format = ResolveFormat(format, isJsonFormat)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				api.ProjectsRequest{},
 				client.Projects,
@@ -15,7 +15,7 @@ func TestListProjectsCommand(t *testing.T) {
 
 	t.Run("lists projects", func(t *testing.T) {
 		client := api.NewMockAuthedClient(t, sv.BaseURL())
-		projects, err := client.Projects(api.ProjectsRequest{})
+		projects, err := client.Projects(ctx, api.ProjectsRequest{})
 		assert.Nil(t, err)
 
 		assert.Contains(t, projects, api.ProjectsResponse{
@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newListRecordingsCommand(params *baseParams) *cobra.Command {
@@ -27,6 +25,7 @@ func newListRecordingsCommand(params *baseParams) *cobra.Command {
 	var sessionID string
 	var sessionKey string
 	var isJsonFormat bool
+	var all bool
 	recordingsListCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List recordings",
@@ -34,11 +33,12 @@ func newListRecordingsCommand(params *baseParams) *cobra.Command {
 			if err := validateSessionKeyRequiresProjectID(sessionKey, projectID); err != nil {
 				dief("%s", err)
 			}
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
+			deviceID, deviceName = resolveDeviceName(cmd.Context(), client, projectID, deviceID, deviceName)
 			startTime, err := maybeConvertToRFC3339(start)
 			if err != nil {
 				dief("failed to parse start time: %s", err)
@@ -48,7 +48,8 @@ func newListRecordingsCommand(params *baseParams) *cobra.Command {
 				dief("failed to parse end time: %s", err)
 			}
 			format = ResolveFormat(format, isJsonFormat)
-			err = renderList(
+			err = renderPagedList(
+				cmd.Context(),
 				os.Stdout,
 				&api.RecordingsRequest{
 					DeviceID:     deviceID,
@@ -69,15 +70,15 @@ func newListRecordingsCommand(params *baseParams) *cobra.Command {
 				},
 				client.Recordings,
 				format,
+				all,
 			)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list recordings: %s\n", err)
-				os.Exit(1)
+				dief("Failed to list recordings: %s", err)
 			}
 		},
 	}
 	recordingsListCmd.InheritedFlags()
-	recordingsListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when using --session-key)")
+	recordingsListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when using --session-key)")
 	recordingsListCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID")
 	recordingsListCmd.PersistentFlags().StringVarP(&deviceName, "device-name", "", "", "Device name")
 	recordingsListCmd.PersistentFlags().StringVarP(&start, "start", "", "", "Start of data range (ISO8601 format)")
@@ -95,6 +96,7 @@ func newListRecordingsCommand(params *baseParams) *cobra.Command {
 	AddFormatFlag(recordingsListCmd, &format)
 	AddDeviceAutocompletion(recordingsListCmd, params)
 	AddJsonFlag(recordingsListCmd, &isJsonFormat)
+	AddAllFlag(recordingsListCmd, &all)
 	return recordingsListCmd
 }
 
@@ -104,8 +106,8 @@ func newDeleteRecordingCommand(params *baseParams) *cobra.Command {
 		Short: "Delete a recording from your organization",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(params.baseURL, *params.clientID, params.token, params.userAgent)
-			if err := client.DeleteRecording(args[0]); err != nil {
+			client := newClient(params.baseURL, *params.clientID, params.token, params.userAgent)
+			if err := client.DeleteRecording(cmd.Context(), args[0]); err != nil {
 				dief("Failed to delete recording: %s", err)
 			}
 		},
@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/foxglove/foxglove-cli/foxglove/api"
+	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/spf13/viper"
 )
@@ -14,7 +21,7 @@ import (
 const (
 	foxgloveClientID = "d51173be08ed4cf7a734aed9ac30afd0"
 	appname          = "foxglove-cli"
-	defaultBaseURL   = "https://api.foxglove.dev"
+	defaultBaseURL   = api.DefaultBaseURL
 )
 
 func configfile() (string, error) {
@@ -33,26 +40,91 @@ const (
 	TokenApiKey
 )
 
-func configureAuth(token, baseURL string, authType AuthType) error {
-	viper.Set("bearer_token", token)
-	viper.Set("base_url", baseURL)
-	viper.Set("auth_type", authType)
-	err := viper.WriteConfigAs(viper.ConfigFileUsed())
-	if err != nil {
+// configureAuth stores credentials in the active profile. The token is held
+// by the credential helper if one is configured. The profile's base URL is
+// recorded if it has none, and only replaced if overwriteBaseURL is set, so
+// that logging in again without --base-url never redirects its credentials.
+func configureAuth(token, baseURL string, overwriteBaseURL bool, authType AuthType) error {
+	if overwriteBaseURL || !viper.IsSet(profileKey("base_url")) {
+		if err := setConfigKey(profileKey("base_url"), baseURL); err != nil {
+			return fmt.Errorf("Failed to write config: %w", err)
+		}
+	}
+	if err := setConfigKey(profileKey("auth_type"), authType); err != nil {
 		return fmt.Errorf("Failed to write config: %w", err)
 	}
-	return nil
+	return storeToken(token, baseURL)
 }
 
-var logDebug bool
+// clientOptions holds the options shared by the clients commands construct:
+// the retry policy set by --retry-max-attempts, the trace level set by
+// --debug, and the transport and cache set up from the config.
+var clientOptions = api.ClientOptions{RetryPolicy: api.DefaultRetryPolicy()}
+
+// newClient returns a client for the API at baseURL that retries, traces and
+// caches requests as configured.
+func newClient(baseURL, clientID, token, userAgent string) *api.FoxgloveClient {
+	opts := clientOptions
+	opts.BaseURL = baseURL
+	opts.ClientID = clientID
+	opts.Token = token
+	opts.UserAgent = userAgent
+	return api.NewClient(opts)
+}
+
+// debugFlag holds the value of --debug: empty when debugging is off, "http"
+// to additionally dump HTTP headers and bodies.
+var debugFlag string
 
 func debugMode() bool {
-	return logDebug
+	return debugFlag != "" && debugFlag != "false"
 }
 
+// traceLevel returns the HTTP trace level selected by --debug.
+func traceLevel(debug string) (api.TraceLevel, error) {
+	switch debug {
+	case "", "false":
+		return api.TraceOff, nil
+	case "true":
+		return api.TraceRequests, nil
+	case "http":
+		return api.TraceBodies, nil
+	default:
+		return api.TraceOff, fmt.Errorf("invalid --debug value %q: expected \"true\" or \"http\"", debug)
+	}
+}
+
+// exit ends the process with a code. Tests replace it to observe the code.
+var exit = os.Exit
+
+// dief prints a message to stderr and exits. The exit code is derived from
+// the first error among args, if any; see exitCode. If the error shows that
+// the session has expired, the user is offered to log in again first. If
+// they do, the command has still failed, but the session is no longer
+// expired, so the exit code is exitFailure.
 func dief(s string, args ...any) {
+	code := exitFailure
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			code = exitCode(err)
+			break
+		}
+	}
+	if code == exitSessionExpired {
+		fmt.Fprintf(os.Stderr, s+"\n", args...)
+		if offerLogin() {
+			code = exitFailure
+		}
+		exit(code)
+		return
+	}
+	exitf(code, s, args...)
+}
+
+// exitf prints a message to stderr and exits with the supplied code.
+func exitf(code int, s string, args ...any) {
 	fmt.Fprintf(os.Stderr, s+"\n", args...)
-	os.Exit(1)
+	exit(code)
 }
 
 type baseParams struct {
@@ -63,15 +135,14 @@ type baseParams struct {
 	token     string
 }
 
+// listDevicesAutocompletionFunc reads params when invoked, since the token
+// may only be resolved once the command line has been parsed.
 func listDevicesAutocompletionFunc(
-	baseURL string,
-	clientID string,
-	token string,
-	userAgent string,
+	params *baseParams,
 ) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		client := api.NewRemoteFoxgloveClient(baseURL, clientID, token, userAgent)
-		devices, err := client.Devices(api.DevicesRequest{})
+		client := newClient(params.baseURL, *params.clientID, params.token, params.userAgent)
+		devices, err := client.CachedDevices(cmd.Context(), api.DevicesRequest{})
 		if err != nil {
 			return []string{}, cobra.ShellCompDirectiveDefault
 		}
@@ -84,14 +155,11 @@ func listDevicesAutocompletionFunc(
 }
 
 func listDevicesByNameAutocompletionFunc(
-	baseURL string,
-	clientID string,
-	token string,
-	userAgent string,
+	params *baseParams,
 ) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		client := api.NewRemoteFoxgloveClient(baseURL, clientID, token, userAgent)
-		devices, err := client.Devices(api.DevicesRequest{})
+		client := newClient(params.baseURL, *params.clientID, params.token, params.userAgent)
+		devices, err := client.CachedDevices(cmd.Context(), api.DevicesRequest{})
 		if err != nil {
 			return []string{}, cobra.ShellCompDirectiveDefault
 		}
@@ -174,9 +242,80 @@ func Execute(version string) {
 	configCmd := newConfigCommand()
 
 	var clientID, cfgFile string
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "", "", "config file (default is $HOME/.foxglove.yaml)")
+	var timeout time.Duration
+	var noCache bool
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "", "", "config file (default is $HOME/.foxgloverc)")
+	profileFlag = profileFromArgs(os.Args[1:])
+	rootCmd.PersistentFlags().StringVarP(&profileFlag, "profile", "", profileFlag, "authentication profile to use (default: FOXGLOVE_PROFILE, or as set by `auth profiles switch`)")
 	rootCmd.PersistentFlags().StringVarP(&clientID, "client-id", "", foxgloveClientID, "foxglove client ID")
-	rootCmd.PersistentFlags().BoolVarP(&logDebug, "debug", "", false, "enable debug logging")
+	rootCmd.PersistentFlags().StringVarP(&debugFlag, "debug", "", "", "enable debug logging, including a trace of HTTP requests. Use --debug=http to also dump headers and bodies")
+	rootCmd.PersistentFlags().Lookup("debug").NoOptDefVal = "true"
+	rootCmd.PersistentFlags().BoolVarP(&noCache, "no-cache", "", false, "bypass the local cache of devices and custom properties used by completion and validation")
+	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "", 0, "abort the command if it has not completed within this duration (e.g. 30s, 5m). Zero means no limit (default: the timeout config key)")
+
+	// Interrupts cancel the command context, which aborts any in-flight HTTP
+	// requests. The timeout is applied once flags have been parsed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	commandContext = ctx
+	// params is populated once the config has been read.
+	var params *baseParams
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := checkProfile(cmd); err != nil {
+			exitf(exitUsage, "%s", err)
+		}
+		trace, err := traceLevel(debugFlag)
+		if err != nil {
+			exitf(exitUsage, "%s", err)
+		}
+		clientOptions.TraceLevel = trace
+		transportConfig, err := loadTransportConfig()
+		if err != nil {
+			dief("Invalid transport configuration: %s", err)
+		}
+		transport, err := api.NewTransport(transportConfig)
+		if err != nil {
+			dief("Invalid transport configuration: %s", err)
+		}
+		clientOptions.Transport = transport
+		if err := configureCache(noCache); err != nil {
+			dief("Invalid cache configuration: %s", err)
+		}
+		if name := configSetting("time_zone"); name != "" {
+			timeZone, err = time.LoadLocation(name)
+			if err != nil {
+				exitf(exitUsage, "Invalid time_zone: %s", err)
+			}
+		}
+		tw.Color = useColor(configSetting("color"), term.IsTerminal(int(os.Stdout.Fd())))
+		if !cmd.Flags().Changed("timeout") {
+			if timeout, err = durationSetting("timeout"); err != nil {
+				exitf(exitUsage, "%s", err)
+			}
+		}
+		if needsCredentials(cmd) {
+			// Completion must not prompt, and ignores helper failures.
+			completing := cmd.Name() == cobra.ShellCompRequestCmd
+			token, err := resolveToken(params.baseURL, !completing)
+			if err != nil && !completing {
+				exitf(exitAuth, "Failed to read credentials: %s", err)
+			}
+			params.token = token
+			if token != "" {
+				commandAuth = TokenSession
+				if TokenIsApiKey(token) {
+					commandAuth = TokenApiKey
+				}
+			}
+		}
+		if timeout > 0 {
+			time.AfterFunc(timeout, func() {
+				cancel(fmt.Errorf("command timed out after %s: %w", timeout, context.DeadlineExceeded))
+			})
+		}
+	}
 
 	var err error
 	if cfgFile == "" {
@@ -192,13 +331,24 @@ func Execute(version string) {
 		return
 	}
 
+	// The retry flag is registered after the config is read so that its
+	// default reflects the configured value.
+	if attempts, err := strconv.Atoi(configSetting("retry_max_attempts")); err == nil {
+		clientOptions.RetryPolicy.MaxAttempts = attempts
+	}
+	rootCmd.PersistentFlags().IntVarP(&clientOptions.RetryPolicy.MaxAttempts, "retry-max-attempts", "", clientOptions.RetryPolicy.MaxAttempts, "number of attempts made for requests that fail with transient errors. 1 disables retries")
+
 	useragent := fmt.Sprintf("%s/%s", appname, version)
-	params := &baseParams{
+	params = &baseParams{
 		userAgent: useragent,
 		cfgFile:   &cfgFile,
 		clientID:  &clientID,
-		token:     viper.GetString("bearer_token"),
-		baseURL:   defaultString(viper.GetString("base_url"), defaultBaseURL),
+		token:     profileSetting("bearer_token"),
+		baseURL:   defaultString(profileSetting("base_url"), defaultBaseURL),
+	}
+
+	sessionLogin = func() error {
+		return executeLogin(commandContext, params.baseURL, false, clientID, useragent, &api.PlatformAuthDelegate{}, api.WithLoginPrompt(printLoginPrompt(os.Stderr)))
 	}
 
 	deprecatedMsg := "use 'data import' instead."
@@ -221,8 +371,12 @@ func Execute(version string) {
 	infoCmd := newInfoCommand(params)
 	configureAPIKey := newConfigureAPIKeyCommand()
 	authCmd.AddCommand(loginCmd)
+	authCmd.AddCommand(newLogoutCommand(params))
 	authCmd.AddCommand(configureAPIKey)
 	authCmd.AddCommand(infoCmd)
+	authCmd.AddCommand(newStatusCommand(params))
+	authCmd.AddCommand(newProfilesCommand())
+	authCmd.AddCommand(newSwitchProfileCommand())
 	recordingsCmd.AddCommand(newListRecordingsCommand(params))
 	recordingsCmd.AddCommand(newDeleteRecordingCommand(params))
 	importsCmd.AddCommand(newListImportsCommand(params), addImportCmd)
@@ -265,12 +419,19 @@ func Execute(version string) {
 		pendingImportsCmd,
 		projectsCmd,
 		configCmd,
+		newCacheCommand(),
+		newAPIKeysCommand(params),
 	)
 
-	cobra.CheckErr(rootCmd.Execute())
+	// Commands report their own failures, so any error here is a usage error
+	// that cobra has already printed.
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(exitUsage)
+	}
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in the config file. Environment variables are read as
+// settings are looked up; see settings.go.
 func initConfig(cfgFile *string) error {
 	if *cfgFile != "" {
 		// Use config file from the flag.
@@ -287,9 +448,16 @@ func initConfig(cfgFile *string) error {
 		viper.SetConfigName(".foxgloverc")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
-
 	// If a config file is found, read it in.
 	_ = viper.ReadInConfig()
+
+	// A project config file is merged over it.
+	if dir, err := os.Getwd(); err == nil {
+		if path, ok := findProjectConfig(dir); ok {
+			if err := loadProjectConfig(path, os.Stderr); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring project config: %s\n", err)
+			}
+		}
+	}
 	return nil
 }
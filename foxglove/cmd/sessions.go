@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -8,7 +9,6 @@ import (
 	"github.com/foxglove/foxglove-cli/foxglove/api"
 	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func newListSessionsCommand(params *baseParams) *cobra.Command {
@@ -21,13 +21,15 @@ func newListSessionsCommand(params *baseParams) *cobra.Command {
 		Use:   "list",
 		Short: "List sessions in your organization",
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
+			deviceID, deviceName = resolveDeviceName(cmd.Context(), client, projectID, deviceID, deviceName)
 			format = ResolveFormat(format, isJsonFormat)
 			err := renderList(
+				cmd.Context(),
 				os.Stdout,
 				api.SessionsRequest{
 					ProjectID:  projectID,
@@ -38,13 +40,12 @@ func newListSessionsCommand(params *baseParams) *cobra.Command {
 				format,
 			)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to list sessions: %s\n", err)
-				os.Exit(1)
+				dief("Failed to list sessions: %s", err)
 			}
 		},
 	}
 	sessionsListCmd.InheritedFlags()
-	sessionsListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (optional filter)")
+	sessionsListCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (optional filter)")
 	sessionsListCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Filter by device ID")
 	sessionsListCmd.PersistentFlags().StringVarP(&deviceName, "device-name", "", "", "Filter by device name")
 	AddFormatFlag(sessionsListCmd, &format)
@@ -60,19 +61,19 @@ func newGetSessionCommand(params *baseParams) *cobra.Command {
 		Short: "Get a session by ID or key",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			keyOrID := args[0]
-			session, err := client.GetSession(keyOrID, projectID)
+			session, err := client.GetSession(cmd.Context(), keyOrID, projectID)
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
-				if err == api.ErrNotFound {
-					dief("Session not found: %s", keyOrID)
+				if errors.Is(err, api.ErrNotFound) {
+					exitf(exitNotFound, "Session not found: %s", keyOrID)
 				}
 				dief("Failed to get session: %s", err)
 			}
@@ -97,7 +98,7 @@ func newGetSessionCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	getSessionCmd.InheritedFlags()
-	getSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
+	getSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
 	return getSessionCmd
 }
 
@@ -112,19 +113,19 @@ func newAddSessionCommand(params *baseParams) *cobra.Command {
 			if deviceID == "" {
 				dief("--device-id is required when creating a session")
 			}
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
-			resp, err := client.CreateSession(api.CreateSessionRequest{
+			resp, err := client.CreateSession(cmd.Context(), api.CreateSessionRequest{
 				Name:      name,
 				ProjectID: projectID,
 				DeviceID:  deviceID,
 			})
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
 				dief("Failed to create session: %s", err)
 			}
@@ -136,8 +137,8 @@ func newAddSessionCommand(params *baseParams) *cobra.Command {
 	}
 	addSessionCmd.InheritedFlags()
 	addSessionCmd.PersistentFlags().StringVarP(&name, "name", "", "", "Name of the session")
-	addSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID")
-	addSessionCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", "", "Device ID (required)")
+	addSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID")
+	addSessionCmd.PersistentFlags().StringVarP(&deviceID, "device-id", "", profileSetting("default_device_id"), "Device ID (required)")
 	AddDeviceIDAutocompletion(addSessionCmd, params)
 	return addSessionCmd
 }
@@ -162,16 +163,16 @@ func newSessionRecordingsListCommand(params *baseParams) *cobra.Command {
 		Short: "List recording IDs in a session",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			keyOrID := args[0]
-			session, err := client.GetSession(keyOrID, projectID)
+			session, err := client.GetSession(cmd.Context(), keyOrID, projectID)
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
 				dief("Failed to list session recordings: %s", err)
 			}
@@ -188,7 +189,7 @@ func newSessionRecordingsListCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	listCmd.InheritedFlags()
-	listCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
+	listCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
 	return listCmd
 }
 
@@ -199,17 +200,17 @@ func newSessionRecordingsAddCommand(params *baseParams) *cobra.Command {
 		Short: "Assign a recording to a session",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			keyOrID := args[0]
 			recordingID := args[1]
-			err := client.AddRecordingToSession(keyOrID, projectID, recordingID)
+			err := client.AddRecordingToSession(cmd.Context(), keyOrID, projectID, recordingID)
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
 				dief("Failed to add recording to session: %s", err)
 			}
@@ -217,7 +218,7 @@ func newSessionRecordingsAddCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	addCmd.InheritedFlags()
-	addCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
+	addCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
 	return addCmd
 }
 
@@ -228,17 +229,17 @@ func newSessionRecordingsRemoveCommand(params *baseParams) *cobra.Command {
 		Short: "Remove a recording from a session",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			keyOrID := args[0]
 			recordingID := args[1]
-			err := client.RemoveRecordingFromSession(keyOrID, projectID, recordingID)
+			err := client.RemoveRecordingFromSession(cmd.Context(), keyOrID, projectID, recordingID)
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
 				dief("Failed to remove recording from session: %s", err)
 			}
@@ -246,7 +247,7 @@ func newSessionRecordingsRemoveCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	removeCmd.InheritedFlags()
-	removeCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
+	removeCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
 	return removeCmd
 }
 
@@ -257,16 +258,16 @@ func newDeleteSessionCommand(params *baseParams) *cobra.Command {
 		Short: "Delete a session",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			client := api.NewRemoteFoxgloveClient(
+			client := newClient(
 				params.baseURL, *params.clientID,
 				params.token,
 				params.userAgent,
 			)
 			keyOrID := args[0]
-			err := client.DeleteSession(keyOrID, projectID)
+			err := client.DeleteSession(cmd.Context(), keyOrID, projectID)
 			if err != nil {
-				if err == api.ErrForbidden {
-					dief("Not authenticated. Run foxglove auth login.")
+				if errors.Is(err, api.ErrForbidden) {
+					exitf(exitAuth, "Not authenticated. Run foxglove auth login.")
 				}
 				dief("Failed to delete session: %s", err)
 			}
@@ -274,6 +275,6 @@ func newDeleteSessionCommand(params *baseParams) *cobra.Command {
 		},
 	}
 	deleteSessionCmd.InheritedFlags()
-	deleteSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", viper.GetString("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
+	deleteSessionCmd.PersistentFlags().StringVarP(&projectID, "project-id", "", profileSetting("default_project_id"), "Project ID (required when session-id-or-key is a session key)")
 	return deleteSessionCmd
 }
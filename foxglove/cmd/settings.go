@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Settings are resolved from, in order of precedence: command-line flags,
+// environment variables, the project config file, the active profile, the
+// rest of the user config file and built-in defaults. Settings held per
+// profile are only read from the active profile, so that one profile's token
+// is never sent to another's server.
+
+// profileEnv maps the settings held per profile to the environment variables
+// that override them. FOXGLOVE_API_KEY stands in for the stored token, and
+// implies that it is an API key.
+var profileEnv = map[string]string{
+	"base_url":           "FOXGLOVE_BASE_URL",
+	"bearer_token":       "FOXGLOVE_API_KEY",
+	"default_project_id": "FOXGLOVE_PROJECT_ID",
+	"default_device_id":  "FOXGLOVE_DEVICE_ID",
+}
+
+// legacyProfileEnv maps settings held per profile to the unprefixed
+// environment variables that earlier releases read for them. They are still
+// read, after those in profileEnv, but are deprecated.
+var legacyProfileEnv = map[string]string{
+	"base_url":     "BASE_URL",
+	"bearer_token": "BEARER_TOKEN",
+	"auth_type":    "AUTH_TYPE",
+}
+
+// warnedLegacyEnv holds the legacy environment variables that a deprecation
+// warning has been printed for.
+var warnedLegacyEnv = map[string]bool{}
+
+// lookupLegacyEnv returns the value of a deprecated environment variable,
+// warning on the first use of each.
+func lookupLegacyEnv(env string, replacement string) (string, bool) {
+	value := os.Getenv(env)
+	if value == "" {
+		return "", false
+	}
+	if !warnedLegacyEnv[env] {
+		warnedLegacyEnv[env] = true
+		fmt.Fprintf(os.Stderr, "Warning: %s is deprecated and will be ignored in a future release; use %s instead\n", env, replacement)
+	}
+	return value, true
+}
+
+// configEnv maps the remaining config keys to the environment variables that
+// override them, in addition to those in transportEnv. They are read by
+// lookupConfigSetting rather than bound to viper, since viper would then
+// write them to the config file along with the user's own settings.
+var configEnv = map[string]string{
+	"retry_max_attempts": "FOXGLOVE_RETRY_MAX_ATTEMPTS",
+	"cache_ttl":          "FOXGLOVE_CACHE_TTL",
+	"credential_helper":  "FOXGLOVE_CREDENTIAL_HELPER",
+	"timeout":            "FOXGLOVE_TIMEOUT",
+}
+
+// settingOrigin describes where the effective value of a setting came from.
+type settingOrigin string
+
+const originDefault settingOrigin = "default"
+
+func flagOrigin(name string) settingOrigin {
+	return settingOrigin("flag --" + name)
+}
+
+func envOrigin(name string) settingOrigin {
+	return settingOrigin("environment variable " + name)
+}
+
+func configOrigin() settingOrigin {
+	return settingOrigin("config file " + viper.ConfigFileUsed())
+}
+
+func profileOrigin(name string) settingOrigin {
+	if name == defaultProfile {
+		return configOrigin()
+	}
+	return settingOrigin(fmt.Sprintf("profile %q in %s", name, viper.ConfigFileUsed()))
+}
+
+// lookupActiveProfile returns the name of the active profile and how it was
+// selected.
+func lookupActiveProfile() (string, settingOrigin) {
+	if profileFlag != "" {
+		return profileFlag, flagOrigin("profile")
+	}
+	if name := os.Getenv("FOXGLOVE_PROFILE"); name != "" {
+		return name, envOrigin("FOXGLOVE_PROFILE")
+	}
+	if name := viper.GetString("current_profile"); name != "" {
+		return name, configOrigin()
+	}
+	return defaultProfile, originDefault
+}
+
+// lookupProfileSetting returns a setting of the active profile and its
+// origin. If the setting is not set, the value is empty and the origin is
+// originDefault.
+func lookupProfileSetting(key string) (string, settingOrigin) {
+	if env, ok := profileEnv[key]; ok {
+		if value := os.Getenv(env); value != "" {
+			return value, envOrigin(env)
+		}
+	}
+	if env := profileEnv["bearer_token"]; key == "auth_type" && os.Getenv(env) != "" {
+		return strconv.Itoa(int(TokenApiKey)), envOrigin(env)
+	}
+	if env, ok := legacyProfileEnv[key]; ok {
+		// FOXGLOVE_API_KEY also sets the auth type.
+		replacement := defaultString(profileEnv[key], profileEnv["bearer_token"])
+		if value, ok := lookupLegacyEnv(env, replacement); ok {
+			return value, envOrigin(env)
+		}
+	}
+	if value, origin, ok := lookupProjectSetting(key); ok {
+		return value, origin
+	}
+	viperKey := profileKey(key)
+	if viper.IsSet(viperKey) {
+		return viper.GetString(viperKey), profileOrigin(activeProfile())
+	}
+	return "", originDefault
+}
+
+// lookupConfigSetting returns a config setting that is not held per profile,
+// and its origin. If the setting is not set, the value is empty and the
+// origin is originDefault.
+func lookupConfigSetting(key string) (string, settingOrigin) {
+	for _, envs := range []map[string]string{configEnv, transportEnv} {
+		if env, ok := envs[key]; ok {
+			if value := os.Getenv(env); value != "" {
+				return value, envOrigin(env)
+			}
+		}
+	}
+	if value, origin, ok := lookupProjectSetting(key); ok {
+		return value, origin
+	}
+	if viper.IsSet(key) {
+		// Lists, such as ca_files, are shown separated like PATH.
+		switch viper.Get(key).(type) {
+		case []any, []string:
+			values := viper.GetStringSlice(key)
+			return strings.Join(values, string(os.PathListSeparator)), configOrigin()
+		}
+		return viper.GetString(key), configOrigin()
+	}
+	return "", originDefault
+}
+
+// configSetting returns a config setting that is not held per profile, or
+// from the environment variable that overrides it.
+func configSetting(key string) string {
+	value, _ := lookupConfigSetting(key)
+	return value
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupProfileSetting(t *testing.T) {
+	t.Run("environment overrides the profile", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		viper.Set("default_project_id", "prj_config")
+		value, origin := lookupProfileSetting("default_project_id")
+		assert.Equal(t, "prj_config", value)
+		assert.Equal(t, settingOrigin("config file "+configfile), origin)
+
+		t.Setenv("FOXGLOVE_PROJECT_ID", "prj_env")
+		value, origin = lookupProfileSetting("default_project_id")
+		assert.Equal(t, "prj_env", value)
+		assert.Equal(t, envOrigin("FOXGLOVE_PROJECT_ID"), origin)
+	})
+	t.Run("named profiles don't fall back to the default profile", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		viper.Set("bearer_token", "default-token")
+		viper.Set("profiles.staging.base_url", "https://api.staging.example.com")
+		profileFlag = "staging"
+
+		value, origin := lookupProfileSetting("base_url")
+		assert.Equal(t, "https://api.staging.example.com", value)
+		assert.Equal(t, settingOrigin(`profile "staging" in `+configfile), origin)
+		value, origin = lookupProfileSetting("bearer_token")
+		assert.Equal(t, "", value)
+		assert.Equal(t, originDefault, origin)
+	})
+	t.Run("an API key in the environment is used as one", func(t *testing.T) {
+		withTestConfig(t)
+		viper.Set("bearer_token", "session-token")
+		viper.Set("auth_type", TokenSession)
+		t.Setenv("FOXGLOVE_API_KEY", "secret")
+		assert.Equal(t, "secret", profileSetting("bearer_token"))
+		assert.True(t, TokenIsApiKey("secret"))
+	})
+	t.Run("legacy environment variables are still read", func(t *testing.T) {
+		withTestConfig(t)
+		viper.Set("base_url", "https://api.config.example.com")
+		t.Setenv("BASE_URL", "https://api.legacy.example.com")
+		value, origin := lookupProfileSetting("base_url")
+		assert.Equal(t, "https://api.legacy.example.com", value)
+		assert.Equal(t, envOrigin("BASE_URL"), origin)
+
+		t.Setenv("FOXGLOVE_BASE_URL", "https://api.env.example.com")
+		assert.Equal(t, "https://api.env.example.com", profileSetting("base_url"))
+	})
+}
+
+func TestLookupConfigSetting(t *testing.T) {
+	configfile := withTestConfig(t)
+	value, origin := lookupConfigSetting("cache_ttl")
+	assert.Equal(t, "", value)
+	assert.Equal(t, originDefault, origin)
+
+	assert.Nil(t, os.WriteFile(configfile, []byte("cache_ttl: 1h\n"), 0600))
+	assert.Nil(t, viper.ReadInConfig())
+	value, origin = lookupConfigSetting("cache_ttl")
+	assert.Equal(t, "1h", value)
+	assert.Equal(t, settingOrigin("config file "+configfile), origin)
+
+	t.Setenv("FOXGLOVE_CACHE_TTL", "10m")
+	value, origin = lookupConfigSetting("cache_ttl")
+	assert.Equal(t, "10m", value)
+	assert.Equal(t, envOrigin("FOXGLOVE_CACHE_TTL"), origin)
+}
+
+func TestConfigWritesOmitEnvironment(t *testing.T) {
+	configfile := withTestConfig(t)
+	assert.Nil(t, os.WriteFile(configfile, []byte("bearer_token: secret\nformat: json\n"), 0600))
+	assert.Nil(t, viper.ReadInConfig())
+	t.Setenv("FOXGLOVE_PROXY_URL", "http://proxy:3128")
+	t.Setenv("FOXGLOVE_TIMEOUT", "1m")
+	t.Setenv("FOXGLOVE_CA_FILES", "ca.pem")
+	t.Setenv("FOXGLOVE_RETRY_MAX_ATTEMPTS", "9")
+	assert.Equal(t, "9", configSetting("retry_max_attempts"))
+
+	assert.Nil(t, unsetConfigKey("bearer_token"))
+	assert.Nil(t, setConfigKey("time_zone", "UTC"))
+
+	contents, err := os.ReadFile(configfile)
+	assert.Nil(t, err)
+	assert.Equal(t, "format: json\ntime_zone: UTC\n", string(contents))
+}
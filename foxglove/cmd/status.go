@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// statusLine is a setting shown by `auth status`.
+type statusLine struct {
+	setting string
+	value   string
+	origin  settingOrigin
+}
+
+// redactToken hides a token for display, keeping only the prefix that marks
+// API keys.
+func redactToken(token string) string {
+	if strings.HasPrefix(token, "fox_sk_") {
+		return "fox_sk_********"
+	}
+	return "********"
+}
+
+// authStatus describes the effective settings commands authenticate with.
+// token is the token of the active profile, which may have been read from
+// the credential helper.
+func authStatus(token string) []statusLine {
+	profile, profileFrom := lookupActiveProfile()
+	baseURL, baseURLFrom := lookupProfileSetting("base_url")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	helper, helperFrom := lookupConfigSetting("credential_helper")
+	credential := "none"
+	_, credentialFrom := lookupProfileSetting("bearer_token")
+	if token != "" {
+		kind := "session token"
+		if TokenIsApiKey(token) {
+			kind = "API key"
+		}
+		credential = fmt.Sprintf("%s %s", kind, redactToken(token))
+		if credentialFrom == originDefault {
+			credentialFrom = settingOrigin(fmt.Sprintf("credential helper %q", helper))
+		}
+	}
+	projectID, projectFrom := lookupProfileSetting("default_project_id")
+	return []statusLine{
+		{"Profile", profile, profileFrom},
+		{"Base URL", baseURL, baseURLFrom},
+		{"Credential", credential, credentialFrom},
+		{"Project ID", defaultString(projectID, "none"), projectFrom},
+		{"Credential helper", defaultString(helper, "none"), helperFrom},
+	}
+}
+
+func printAuthStatus(w io.Writer, lines []statusLine, explain bool) {
+	headers := []string{"Setting", "Value"}
+	if explain {
+		headers = append(headers, "Source")
+	}
+	data := [][]string{}
+	for _, line := range lines {
+		row := []string{line.setting, line.value}
+		if explain {
+			row = append(row, string(line.origin))
+		}
+		data = append(data, row)
+	}
+	tw.PrintTable(w, headers, data)
+}
+
+func newStatusCommand(params *baseParams) *cobra.Command {
+	var explain bool
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the profile, credential and settings commands will use",
+		Long: `Show the profile, credential and settings commands will use. Tokens are
+never printed in full.
+
+Each setting is taken from the first of these that sets it: a command-line
+flag, an environment variable (FOXGLOVE_PROFILE, FOXGLOVE_API_KEY,
+FOXGLOVE_BASE_URL, FOXGLOVE_PROJECT_ID, ...), a project .foxglove.yaml file,
+the active profile, the rest of the config file, and finally the built-in
+default. Pass --explain to see which one each value came from.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printAuthStatus(os.Stdout, authStatus(params.token), explain)
+			if !IsAuthenticated(params.token) {
+				exitf(exitAuth, "Not signed in. Run `foxglove auth login`, `foxglove auth configure-api-key`, or set FOXGLOVE_API_KEY to continue.")
+			}
+		},
+	}
+	statusCmd.PersistentFlags().BoolVarP(&explain, "explain", "", false, "show where each value came from")
+	return statusCmd
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthStatus(t *testing.T) {
+	t.Run("explains where each value came from", func(t *testing.T) {
+		configfile := withTestConfig(t)
+		viper.Set("profiles.ci.default_project_id", "prj_profile")
+		t.Setenv("FOXGLOVE_PROFILE", "ci")
+		t.Setenv("FOXGLOVE_API_KEY", "fox_sk_secret")
+
+		lines := authStatus("fox_sk_secret")
+		assert.Equal(t, []statusLine{
+			{"Profile", "ci", envOrigin("FOXGLOVE_PROFILE")},
+			{"Base URL", defaultBaseURL, originDefault},
+			{"Credential", "API key fox_sk_********", envOrigin("FOXGLOVE_API_KEY")},
+			{"Project ID", "prj_profile", settingOrigin(`profile "ci" in ` + configfile)},
+			{"Credential helper", "none", originDefault},
+		}, lines)
+
+		buf := &bytes.Buffer{}
+		printAuthStatus(buf, lines, true)
+		assert.Contains(t, buf.String(), "environment variable FOXGLOVE_API_KEY")
+		assert.NotContains(t, buf.String(), "secret")
+	})
+	t.Run("attributes tokens read by the credential helper", func(t *testing.T) {
+		withTestConfig(t)
+		viper.Set("credential_helper", encryptedFileHelperName)
+		viper.Set("auth_type", TokenSession)
+		lines := authStatus("session-token")
+		assert.Equal(t, statusLine{"Credential", "session token ********", `credential helper "encrypted-file"`}, lines[2])
+	})
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/mcap/go/mcap"
+)
+
+// mcapSink receives the records of consecutive MCAP streams, such as the
+// partial files or resumed downloads of an export, and writes them to one
+// output.
+type mcapSink interface {
+	// nextStream starts the next stream. Schema and channel IDs are
+	// specific to each stream.
+	nextStream()
+	writeSchema(schema *mcap.Schema) error
+	writeChannel(channel *mcap.Channel) error
+	writeMessage(message *mcap.Message) error
+	writeMetadata(metadata *mcap.Metadata) error
+	writeAttachment(attachment *mcap.Attachment) error
+	close() error
+}
+
+// streamReadError is returned by copyMCAPStream if a stream ends before its
+// data is complete.
+type streamReadError struct {
+	err error
+}
+
+func (e *streamReadError) Error() string {
+	return fmt.Sprintf("failed to read message: %s", e.err)
+}
+
+func (e *streamReadError) Unwrap() error {
+	return e.err
+}
+
+// copyMCAPStream writes the records of an MCAP stream to sink, leaving out the
+// messages that stitcher finds were written from earlier streams. It returns
+// the log time of the last message received and the number received. If the
+// stream ends early, the error is a *streamReadError.
+func copyMCAPStream(r io.Reader, sink mcapSink, stitcher *stitcher) (*fileInfo, error) {
+	info := &fileInfo{}
+	sink.nextStream()
+	stitcher.nextFile()
+	// An error writing an attachment surfaces from the lexer, so keep it to
+	// tell it apart from a read error.
+	var writeErr error
+	lexer, err := mcap.NewLexer(r, &mcap.LexerOptions{
+		AttachmentCallback: func(ar *mcap.AttachmentReader) error {
+			writeErr = sink.writeAttachment(&mcap.Attachment{
+				LogTime:    ar.LogTime,
+				CreateTime: ar.CreateTime,
+				Name:       ar.Name,
+				MediaType:  ar.MediaType,
+				DataSize:   ar.DataSize,
+				Data:       ar.Data(),
+			})
+			return writeErr
+		},
+	})
+	if err != nil {
+		return info, &streamReadError{err}
+	}
+	topics := make(map[uint16]string)
+	for {
+		tokenType, token, err := lexer.Next(nil)
+		if err != nil {
+			if writeErr != nil {
+				return info, fmt.Errorf("failed to write attachment: %w", writeErr)
+			}
+			return info, &streamReadError{err}
+		}
+		switch tokenType {
+		case mcap.TokenMessage:
+			message, err := mcap.ParseMessage(token)
+			if err != nil {
+				return info, fmt.Errorf("failed to parse message: %w", err)
+			}
+			info.messageCount++
+			info.maxTime = max(info.maxTime, message.LogTime)
+			if !stitcher.keep(topics[message.ChannelID], message.LogTime, message.Sequence, message.Data) {
+				continue
+			}
+			if err := sink.writeMessage(message); err != nil {
+				return info, fmt.Errorf("failed to write message: %w", err)
+			}
+		case mcap.TokenChannel:
+			channel, err := mcap.ParseChannel(token)
+			if err != nil {
+				return info, fmt.Errorf("failed to parse channel: %w", err)
+			}
+			topics[channel.ID] = channel.Topic
+			if err := sink.writeChannel(channel); err != nil {
+				return info, fmt.Errorf("failed to write channel: %w", err)
+			}
+		case mcap.TokenSchema:
+			schema, err := mcap.ParseSchema(token)
+			if err != nil {
+				return info, fmt.Errorf("failed to parse schema: %w", err)
+			}
+			if err := sink.writeSchema(schema); err != nil {
+				return info, fmt.Errorf("failed to write schema: %w", err)
+			}
+		case mcap.TokenMetadata:
+			metadata, err := mcap.ParseMetadata(token)
+			if err != nil {
+				return info, fmt.Errorf("failed to parse metadata: %w", err)
+			}
+			if err := sink.writeMetadata(metadata); err != nil {
+				return info, fmt.Errorf("failed to write metadata: %w", err)
+			}
+		case mcap.TokenDataEnd:
+			return info, nil
+		}
+	}
+}
+
+// jsonSink writes the messages of consecutive MCAP streams as lines of JSON.
+type jsonSink struct {
+	writer   *jsonWriter
+	schemas  map[uint16]*mcap.Schema
+	channels map[uint16]*mcap.Channel
+}
+
+func newJSONSink(w io.Writer) *jsonSink {
+	return &jsonSink{
+		writer:   newJSONWriter(w),
+		schemas:  make(map[uint16]*mcap.Schema),
+		channels: make(map[uint16]*mcap.Channel),
+	}
+}
+
+func (s *jsonSink) nextStream() {
+	s.writer.reset()
+	clear(s.schemas)
+	clear(s.channels)
+}
+
+func (s *jsonSink) writeSchema(schema *mcap.Schema) error {
+	s.schemas[schema.ID] = schema
+	return nil
+}
+
+func (s *jsonSink) writeChannel(channel *mcap.Channel) error {
+	s.channels[channel.ID] = channel
+	return nil
+}
+
+func (s *jsonSink) writeMessage(message *mcap.Message) error {
+	channel, ok := s.channels[message.ChannelID]
+	if !ok {
+		return fmt.Errorf("message on unknown channel %d", message.ChannelID)
+	}
+	return s.writer.writeMessage(s.schemas[channel.SchemaID], channel, message)
+}
+
+func (s *jsonSink) writeMetadata(*mcap.Metadata) error {
+	return nil
+}
+
+func (s *jsonSink) writeAttachment(*mcap.Attachment) error {
+	return nil
+}
+
+func (s *jsonSink) close() error {
+	return nil
+}
+
+// streamExport writes the data selected by request to w as it is received, in
+// the JSON or MCAP output format of the request. If the download is cut short,
+// the rest is requested from the last timestamp received, and only the
+// messages not already written are emitted. MCAP output is written anew
+// rather than passed through as received, so that it stays one valid file.
+func streamExport(
+	ctx context.Context,
+	w io.Writer,
+	request api.StreamRequest,
+	compression mcap.CompressionFormat,
+	export exportFunc,
+) error {
+	var sink mcapSink
+	switch request.OutputFormat {
+	case "json":
+		sink = newJSONSink(w)
+	case "mcap0":
+		combiner, err := newMCAPCombiner(w, compression)
+		if err != nil {
+			return err
+		}
+		sink = combiner
+	default:
+		return fmt.Errorf("unsupported format for resumable streaming: %s", request.OutputFormat)
+	}
+	request.OutputFormat = downloadFormat(request.OutputFormat)
+	stitcher := newStitcher()
+	state := resumeState{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := streamOnce(ctx, sink, stitcher, &request, export)
+		var readErr *streamReadError
+		if err != nil && !errors.As(err, &readErr) {
+			return err
+		}
+		if readErr == nil {
+			break
+		}
+		debugf("stream was cut short after %d messages: %s", info.messageCount, readErr)
+		if !state.advance(&request, info) {
+			break
+		}
+	}
+	return sink.close()
+}
+
+// streamOnce makes one request of a streamed export, and copies what it
+// receives to sink. If the request fails, or the download fails for a reason
+// a new request can't fix, the error is returned. A download cut short
+// partway is reported by a *streamReadError, and can be resumed.
+func streamOnce(
+	ctx context.Context,
+	sink mcapSink,
+	stitcher *stitcher,
+	request *api.StreamRequest,
+	export exportFunc,
+) (*fileInfo, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	received := &countingWriter{w: pipeWriter}
+	errs := make(chan error, 1)
+	go func() {
+		err := export(ctx, received, request)
+		pipeWriter.CloseWithError(err)
+		errs <- err
+	}()
+	info, err := copyMCAPStream(pipeReader, sink, stitcher)
+	var readErr *streamReadError
+	if err != nil && !errors.As(err, &readErr) {
+		// Stop the download if the output can't be written.
+		pipeReader.CloseWithError(err)
+		<-errs
+		return info, err
+	}
+	// Let the download run to its end, past the data.
+	io.Copy(io.Discard, pipeReader)
+	if exportErr := <-errs; exportErr != nil {
+		// A request that failed outright received nothing, and must not be
+		// mistaken for an empty download.
+		if ctx.Err() != nil || received.n == 0 || !resumableExportError(exportErr) {
+			return info, exportErr
+		}
+		debugf("download failed after %d bytes: %s", received.n, exportErr)
+	}
+	return info, err
+}
+
+// resumableExportError reports whether a download that failed with err may
+// be continued by a new request. Errors the server returns, such as an
+// expired session or a missing device, would only be returned again.
+func resumableExportError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, api.ErrForbidden) || errors.Is(err, api.ErrNotFound) {
+		return false
+	}
+	var apiErr *api.APIError
+	return !errors.As(err, &apiErr) || apiErr.StatusCode >= 500
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
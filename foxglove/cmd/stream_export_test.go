@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/foxglove/mcap/go/mcap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeStringMCAP writes messages as std_msgs/String, which can be converted
+// to JSON.
+func writeStringMCAP(t *testing.T, messages []stitchedMessage) []byte {
+	buf := &bytes.Buffer{}
+	writer, err := mcap.NewWriter(buf, &mcap.WriterOptions{})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteHeader(&mcap.Header{}))
+	require.NoError(t, writer.WriteSchema(&mcap.Schema{ID: 1, Name: "std_msgs/String", Encoding: "ros1msg", Data: []byte("string data")}))
+	require.NoError(t, writer.WriteChannel(&mcap.Channel{ID: 0, SchemaID: 1, Topic: "/a", MessageEncoding: "ros1"}))
+	require.NoError(t, writer.WriteChannel(&mcap.Channel{ID: 1, SchemaID: 1, Topic: "/b", MessageEncoding: "ros1"}))
+	for _, m := range messages {
+		channelID := uint16(0)
+		if m.topic == "/b" {
+			channelID = 1
+		}
+		data := binary.LittleEndian.AppendUint32(nil, uint32(len(m.data)))
+		data = append(data, m.data...)
+		require.NoError(t, writer.WriteMessage(&mcap.Message{ChannelID: channelID, LogTime: m.logTime, Data: data}))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func readStringJSON(t *testing.T, r io.Reader) []stitchedMessage {
+	messages := []stitchedMessage{}
+	decoder := json.NewDecoder(r)
+	for {
+		var message Message
+		err := decoder.Decode(&message)
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		require.NoError(t, err)
+		var data struct {
+			Data string `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(message.Data, &data))
+		messages = append(messages, stitchedMessage{message.Topic, uint64(message.LogTime), data.Data})
+	}
+}
+
+// newStringStreamServer serves the messages logged from the start of each
+// request, with the first downloads cut off after truncateAfter bytes if it
+// is positive.
+func newStringStreamServer(t *testing.T, ctx context.Context, messages []stitchedMessage, truncateAfter int) (*api.MockFoxgloveServer, string) {
+	sv, err := api.NewMockServer(ctx)
+	require.NoError(t, err)
+	sv.StreamData = func(req api.StreamRequest) []byte {
+		selected := []stitchedMessage{}
+		for _, m := range messages {
+			if req.Start == nil || m.logTime >= uint64(req.Start.UnixNano()) {
+				selected = append(selected, m)
+			}
+		}
+		return writeStringMCAP(t, selected)
+	}
+	if truncateAfter > 0 {
+		sv.InjectFault(api.Fault{PathPrefix: "/storage/", Count: 3, TruncateAfter: truncateAfter})
+	}
+	client := api.NewRemoteFoxgloveClient(sv.BaseURL(), "client-id", "", "test-app")
+	token, err := client.SignIn(ctx, "client-id")
+	require.NoError(t, err)
+	return sv, token
+}
+
+func TestStreamExport(t *testing.T) {
+	messages := stitchTestMessages()
+	full := len(writeStringMCAP(t, messages))
+	for _, format := range []string{"json", "mcap0"} {
+		for k := 1; k < 12; k += 3 {
+			truncateAfter := full * k / 12
+			t.Run(fmt.Sprintf("%s truncated after %d bytes", format, truncateAfter), func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				sv, token := newStringStreamServer(t, ctx, messages, truncateAfter)
+				buf := &bytes.Buffer{}
+				err := streamExport(
+					ctx,
+					buf,
+					api.StreamRequest{DeviceID: "test-device", OutputFormat: format},
+					mcap.CompressionLZ4,
+					func(ctx context.Context, w io.Writer, request *api.StreamRequest) error {
+						return executeExport(ctx, w, sv.BaseURL(), "abc", token, "user-agent", request)
+					},
+				)
+				require.NoError(t, err)
+				assert.Greater(t, sv.RequestCount("/v1/data/stream"), 1)
+				if format == "json" {
+					assert.Equal(t, messages, readStringJSON(t, buf))
+					return
+				}
+				reader, err := mcap.NewReader(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+				info, err := reader.Info()
+				require.NoError(t, err)
+				assert.Len(t, info.Schemas, 1)
+				assert.Len(t, info.Channels, 2)
+				exported := readStitchTestMCAP(t, bytes.NewReader(buf.Bytes()))
+				require.Len(t, exported, len(messages))
+				for i := range exported {
+					// The strings are length-prefixed as ROS 1 encodes them.
+					exported[i].data = exported[i].data[4:]
+				}
+				assert.Equal(t, messages, exported)
+			})
+		}
+	}
+}
+
+func TestJSONExportToFile(t *testing.T) {
+	messages := stitchTestMessages()
+	full := len(writeStringMCAP(t, messages))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sv, token := newStringStreamServer(t, ctx, messages, full/3)
+	tmpdir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "output.json")
+	err := doExport(
+		ctx,
+		output,
+		sv.BaseURL(),
+		"abc",
+		token,
+		"user-agent",
+		&api.StreamRequest{DeviceID: "test-device", OutputFormat: "json"},
+		exportOptions{tmpdir: tmpdir},
+	)
+	require.NoError(t, err)
+	assert.Greater(t, sv.RequestCount("/v1/data/stream"), 1)
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, messages, readStringJSON(t, f))
+	staged, err := os.ReadDir(tmpdir)
+	require.NoError(t, err)
+	assert.Empty(t, staged)
+}
+
+func TestStreamExportErrors(t *testing.T) {
+	messages := stitchTestMessages()
+	cases := []struct {
+		assertion string
+		token     func(token string) string
+		fault     api.Fault
+		err       error
+	}{
+		{
+			"returns a rejected request",
+			func(string) string { return "expired-token" },
+			api.Fault{},
+			api.ErrForbidden,
+		},
+		{
+			"returns a failed download",
+			func(token string) string { return token },
+			api.Fault{PathPrefix: "/storage/", Count: 10, Status: 404},
+			api.ErrNotFound,
+		},
+	}
+	for _, c := range cases {
+		for _, format := range []string{"json", "mcap0"} {
+			t.Run(fmt.Sprintf("%s as %s", c.assertion, format), func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				sv, token := newStringStreamServer(t, ctx, messages, 0)
+				sv.InjectFault(c.fault)
+				buf := &bytes.Buffer{}
+				err := streamExport(
+					ctx,
+					buf,
+					api.StreamRequest{DeviceID: "test-device", OutputFormat: format},
+					mcap.CompressionLZ4,
+					func(ctx context.Context, w io.Writer, request *api.StreamRequest) error {
+						return executeExport(ctx, w, sv.BaseURL(), "abc", c.token(token), "user-agent", request)
+					},
+				)
+				assert.ErrorIs(t, err, c.err)
+				if format == "json" {
+					assert.Empty(t, buf.String())
+				}
+			})
+		}
+	}
+}
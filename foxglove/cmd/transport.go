@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+)
+
+// transportEnv maps the config keys that configure the HTTP transport to the
+// environment variables that override them.
+var transportEnv = map[string]string{
+	"proxy_url":       "FOXGLOVE_PROXY_URL",
+	"ca_files":        "FOXGLOVE_CA_FILES",
+	"client_cert":     "FOXGLOVE_CLIENT_CERT",
+	"client_key":      "FOXGLOVE_CLIENT_KEY",
+	"connect_timeout": "FOXGLOVE_CONNECT_TIMEOUT",
+	"read_timeout":    "FOXGLOVE_READ_TIMEOUT",
+	"keep_alive":      "FOXGLOVE_KEEP_ALIVE",
+}
+
+func durationSetting(key string) (time.Duration, error) {
+	value := configSetting(key)
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return duration, nil
+}
+
+// loadTransportConfig reads the transport configuration from the config file
+// and environment. CA files may be given as a list, or as a single string
+// separated like PATH.
+func loadTransportConfig() (api.TransportConfig, error) {
+	config := api.TransportConfig{
+		ProxyURL:   configSetting("proxy_url"),
+		ClientCert: configSetting("client_cert"),
+		ClientKey:  configSetting("client_key"),
+		CAFiles:    filepath.SplitList(configSetting("ca_files")),
+	}
+	var err error
+	if config.ConnectTimeout, err = durationSetting("connect_timeout"); err != nil {
+		return config, err
+	}
+	if config.ReadTimeout, err = durationSetting("read_timeout"); err != nil {
+		return config, err
+	}
+	if config.KeepAlive, err = durationSetting("keep_alive"); err != nil {
+		return config, err
+	}
+	return config, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foxglove/foxglove-cli/foxglove/api"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTransportConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Run("reads settings from the config", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("proxy_url", "http://proxy:3128")
+		viper.Set("ca_files", []string{"a.pem", "b.pem"})
+		viper.Set("client_cert", "client.crt")
+		viper.Set("client_key", "client.key")
+		viper.Set("connect_timeout", "10s")
+		viper.Set("read_timeout", "1m")
+		viper.Set("keep_alive", "-1s")
+		config, err := loadTransportConfig()
+		assert.Nil(t, err)
+		assert.Equal(t, api.TransportConfig{
+			ProxyURL:       "http://proxy:3128",
+			CAFiles:        []string{"a.pem", "b.pem"},
+			ClientCert:     "client.crt",
+			ClientKey:      "client.key",
+			ConnectTimeout: 10 * time.Second,
+			ReadTimeout:    time.Minute,
+			KeepAlive:      -time.Second,
+		}, config)
+	})
+	t.Run("reads settings from the environment", func(t *testing.T) {
+		viper.Reset()
+		t.Setenv("FOXGLOVE_CA_FILES", strings.Join([]string{"a.pem", "b.pem"}, string(os.PathListSeparator)))
+		t.Setenv("FOXGLOVE_READ_TIMEOUT", "5s")
+		config, err := loadTransportConfig()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a.pem", "b.pem"}, config.CAFiles)
+		assert.Equal(t, 5*time.Second, config.ReadTimeout)
+	})
+	t.Run("rejects durations without units", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("connect_timeout", "10")
+		_, err := loadTransportConfig()
+		assert.ErrorContains(t, err, "invalid connect_timeout")
+	})
+}
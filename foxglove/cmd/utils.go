@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,13 +19,15 @@ import (
 	tw "github.com/foxglove/foxglove-cli/foxglove/util/tablewriter"
 	"github.com/foxglove/mcap/go/mcap"
 	"github.com/relvacode/iso8601"
+	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var ErrTruncatedMCAP = errors.New("truncated mcap file")
 var ErrInvalidInput = errors.New("magic bytes do not match bag or mcap format")
 
+var bagMagic = []byte("#ROSBAG V2.0\n")
+
 func fileLooksLikeMCAP(r io.ReadSeeker) (bool, error) {
 	buf := make([]byte, len(mcap.Magic))
 	_, err := r.Read(buf)
@@ -47,7 +53,6 @@ func fileLooksLikeMCAP(r io.ReadSeeker) (bool, error) {
 }
 
 func fileLooksLikeBag(r io.ReadSeeker) (bool, error) {
-	bagMagic := []byte("#ROSBAG V2.0\n")
 	buf := make([]byte, len(bagMagic))
 	_, err := r.Read(buf)
 	if err != nil {
@@ -81,61 +86,239 @@ func validateImportLooksLegal(r io.ReadSeeker) error {
 	return ErrInvalidInput
 }
 
+// validateImportStreamLooksLegal checks the magic bytes at the start of a
+// stream without consuming them. Unlike validateImportLooksLegal it cannot
+// detect a truncated MCAP file, since that would require reading to the end.
+func validateImportStreamLooksLegal(r *bufio.Reader) error {
+	head, err := r.Peek(len(bagMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read magic bytes: %w", err)
+	}
+	if bytes.HasPrefix(head, mcap.Magic) || bytes.Equal(head, bagMagic) {
+		return nil
+	}
+	return ErrInvalidInput
+}
+
+// newProgressBar returns a callback that draws the progress of a transfer,
+// and a function that finishes the bar. The bar is created on the first
+// report, once the total size is known.
+func newProgressBar(description string) (api.ProgressFunc, func()) {
+	var bar *progressbar.ProgressBar
+	progress := func(transferred, total int64) {
+		if bar == nil {
+			bar = progressbar.DefaultBytes(total, description)
+		}
+		_ = bar.Set64(transferred)
+	}
+	finish := func() {
+		if bar != nil {
+			_ = bar.Close()
+		}
+	}
+	return progress, finish
+}
+
 func renderList[RequestType api.Request, ResponseType api.Record](
+	ctx context.Context,
 	w io.Writer,
 	req RequestType,
-	fn func(RequestType) ([]ResponseType, error),
+	fn func(context.Context, RequestType) ([]ResponseType, error),
 	format string,
 ) error {
-	records, err := fn(req)
-	if err != nil {
-		return err
+	return renderPages(w, func(yield func([]ResponseType, error) bool) {
+		yield(fn(ctx, req))
+	}, format)
+}
+
+// renderRecords renders records that have already been fetched, in the
+// same formats as renderList.
+func renderRecords[ResponseType api.Record](w io.Writer, records []ResponseType, format string) error {
+	return renderPages(w, func(yield func([]ResponseType, error) bool) {
+		yield(records, nil)
+	}, format)
+}
+
+// renderPagedList renders the results of a paged list request. If all is set,
+// every page is fetched and rendered as it arrives, with the request's limit
+// used as the page size.
+func renderPagedList[RequestType api.PagedRequest, ResponseType api.Record](
+	ctx context.Context,
+	w io.Writer,
+	req RequestType,
+	fn func(context.Context, RequestType) ([]ResponseType, error),
+	format string,
+	all bool,
+) error {
+	if !all {
+		return renderList(ctx, w, req, fn, format)
 	}
+	return renderPages(w, api.Pages(ctx, req, fn), format)
+}
+
+// renderPages writes records to w one page at a time, so that memory use is
+// bounded by the page size rather than the total number of records. The pages
+// of a table render as one table, with column widths fixed by the first.
+func renderPages[ResponseType api.Record](
+	w io.Writer,
+	pages iter.Seq2[[]ResponseType, error],
+	format string,
+) error {
+	var write func([]ResponseType) error
+	var finish func() error
 	switch format {
 	case "table":
-		if len(records) == 0 {
-			fmt.Println("No records found")
+		// The rows of all pages form one table, laid out for the first.
+		var table *tw.Writer
+		write = func(records []ResponseType) error {
+			if table == nil {
+				table = tw.NewWriter(w, records[0].Headers())
+			}
+			data := [][]string{}
+			for _, record := range records {
+				data = append(data, record.Fields())
+			}
+			table.Write(data)
 			return nil
 		}
-		data := [][]string{}
-		for _, record := range records {
-			data = append(data, record.Fields())
+		finish = func() error {
+			if table == nil {
+				fmt.Println("No records found")
+			}
+			return nil
 		}
-		tw.PrintTable(w, records[0].Headers(), data)
 	case "json":
-		err := renderJSON(w, records)
-		if err != nil {
-			return fmt.Errorf("failed to render JSON: %w", err)
+		writer := newJSONArrayWriter(w)
+		write = func(records []ResponseType) error {
+			return writeJSONRecords(writer, records)
+		}
+		finish = writer.Close
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		write = func(records []ResponseType) error {
+			for _, record := range records {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+			return nil
 		}
+		finish = func() error { return nil }
 	case "csv":
-		err := renderCSV(w, records)
-		if err != nil {
-			return fmt.Errorf("failed to render CSV: %w", err)
+		writer := &csvRecordWriter{Writer: csv.NewWriter(w)}
+		write = func(records []ResponseType) error {
+			return writeCSVRecords(writer, records)
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
 		}
 	default:
 		return fmt.Errorf("unsupported format %s", format)
 	}
+	for records, err := range pages {
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if err := write(records); err != nil {
+			return fmt.Errorf("failed to render %s: %w", strings.ToUpper(format), err)
+		}
+	}
+	if err := finish(); err != nil {
+		return fmt.Errorf("failed to render %s: %w", strings.ToUpper(format), err)
+	}
 	return nil
 }
 
-func renderJSON[RecordType api.Record](w io.Writer, records []RecordType) error {
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "    ")
-	return encoder.Encode(records)
+// jsonArrayWriter writes a JSON array incrementally, one element at a time,
+// in the same layout as an indented json.Encoder.
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
 }
 
-func renderCSV[RecordType api.Record](w io.Writer, records []RecordType) error {
-	writer := csv.NewWriter(w)
-	err := writer.Write(records[0].Headers())
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	data, err := json.MarshalIndent(v, "    ", "    ")
 	if err != nil {
 		return err
 	}
+	prefix := ",\n    "
+	if a.count == 0 {
+		prefix = "[\n    "
+	}
+	if _, err := io.WriteString(a.w, prefix); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+func (a *jsonArrayWriter) Close() error {
+	if a.count == 0 {
+		_, err := io.WriteString(a.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(a.w, "\n]\n")
+	return err
+}
+
+func writeJSONRecords[RecordType api.Record](writer *jsonArrayWriter, records []RecordType) error {
 	for _, record := range records {
-		err := writer.Write(record.Fields())
-		if err != nil {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvRecordWriter writes records as CSV, preceded by a header row taken from
+// the first record.
+type csvRecordWriter struct {
+	*csv.Writer
+	wroteHeader bool
+}
+
+func writeCSVRecords[RecordType api.Record](writer *csvRecordWriter, records []RecordType) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if !writer.wroteHeader {
+		if err := writer.Write(records[0].Headers()); err != nil {
+			return err
+		}
+		writer.wroteHeader = true
+	}
+	for _, record := range records {
+		if err := writer.Write(record.Fields()); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+func renderJSON[RecordType api.Record](w io.Writer, records []RecordType) error {
+	writer := newJSONArrayWriter(w)
+	if err := writeJSONRecords(writer, records); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func renderCSV[RecordType api.Record](w io.Writer, records []RecordType) error {
+	writer := &csvRecordWriter{Writer: csv.NewWriter(w)}
+	if err := writeCSVRecords(writer, records); err != nil {
+		return err
+	}
 	writer.Flush()
 	return writer.Error()
 }
@@ -153,7 +336,18 @@ func AddFormatFlag(cmd *cobra.Command, format *string) {
 		"format",
 		"",
 		"",
-		"render output in specified format (table, json, csv)",
+		"render output in specified format (table, json, ndjson, csv)",
+	)
+}
+
+// AddAllFlag defines an `all` flag on a paged list command, which fetches
+// every page of results rather than only the first.
+func AddAllFlag(cmd *cobra.Command, all *bool) {
+	cmd.PersistentFlags().BoolVar(
+		all,
+		"all",
+		false,
+		"fetch all pages of results, using --limit as the page size",
 	)
 }
 
@@ -166,13 +360,14 @@ func AddJsonFlag(cmd *cobra.Command, isJsonFormat *bool) {
 	)
 }
 
-// Ensure --json alias is not conflicting with --format's value
+// Ensure --json alias is not conflicting with --format's value. Without
+// either flag, the configured format is used.
 func ResolveFormat(formatFlagValue string, jsonFlagValue bool) string {
 	if formatFlagValue == "" {
 		if jsonFlagValue {
 			return "json"
 		} else {
-			return "table"
+			return defaultString(configSetting("format"), "table")
 		}
 	}
 
@@ -188,23 +383,13 @@ func ResolveFormat(formatFlagValue string, jsonFlagValue bool) string {
 func AddDeviceAutocompletion(cmd *cobra.Command, params *baseParams) {
 	if err := cmd.RegisterFlagCompletionFunc(
 		"device-id",
-		listDevicesAutocompletionFunc(
-			params.baseURL,
-			*params.clientID,
-			params.token,
-			params.userAgent,
-		),
+		listDevicesAutocompletionFunc(params),
 	); err != nil {
 		dief("failed to register device-id autocompletion: %v", err)
 	}
 	if err := cmd.RegisterFlagCompletionFunc(
 		"device-name",
-		listDevicesByNameAutocompletionFunc(
-			params.baseURL,
-			*params.clientID,
-			params.token,
-			params.userAgent,
-		),
+		listDevicesByNameAutocompletionFunc(params),
 	); err != nil {
 		dief("failed to register device-name autocompletion: %v", err)
 	}
@@ -215,12 +400,7 @@ func AddDeviceAutocompletion(cmd *cobra.Command, params *baseParams) {
 func AddDeviceIDAutocompletion(cmd *cobra.Command, params *baseParams) {
 	if err := cmd.RegisterFlagCompletionFunc(
 		"device-id",
-		listDevicesAutocompletionFunc(
-			params.baseURL,
-			*params.clientID,
-			params.token,
-			params.userAgent,
-		),
+		listDevicesAutocompletionFunc(params),
 	); err != nil {
 		dief("failed to register device-id autocompletion: %v", err)
 	}
@@ -235,6 +415,10 @@ func promptForInput(prompt string) string {
 	return value
 }
 
+// timeZone is the zone of timestamps given without a UTC offset, as set by
+// the time-zone config key.
+var timeZone = time.UTC
+
 // maybeConvertToRFC3339 converts an ISO8601 timestamp to RFC3339, if an input
 // timestamp is supplied. If the input is empty, it returns an empty string and
 // no error.
@@ -246,11 +430,20 @@ func maybeConvertToRFC3339(timestamp string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// The parser reports explicit offsets as fixed zones, so UTC without a
+	// trailing Z means no offset was given.
+	if parsed.Location() == time.UTC && !strings.HasSuffix(strings.ToUpper(timestamp), "Z") {
+		parsed = time.Date(
+			parsed.Year(), parsed.Month(), parsed.Day(),
+			parsed.Hour(), parsed.Minute(), parsed.Second(), parsed.Nanosecond(),
+			timeZone,
+		)
+	}
 	return parsed.Format(time.RFC3339), nil
 }
 
 func TokenIsApiKey(token string) bool {
-	authType := viper.GetInt("auth_type")
+	authType, _ := strconv.Atoi(profileSetting("auth_type"))
 	switch AuthType(authType) {
 	case TokenApiKey:
 		return true
@@ -261,8 +454,8 @@ func TokenIsApiKey(token string) bool {
 	}
 }
 
-func IsAuthenticated() bool {
-	token := viper.GetString("bearer_token")
+// IsAuthenticated reports whether a token was found for the active profile.
+func IsAuthenticated(token string) bool {
 	return token != ""
 }
 
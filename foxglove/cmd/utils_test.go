@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/foxglove/foxglove-cli/foxglove/api"
 	"github.com/foxglove/mcap/go/mcap"
 	"github.com/relvacode/iso8601"
 	"github.com/stretchr/testify/assert"
@@ -50,6 +58,29 @@ func TestValidateImportLooksLegal(t *testing.T) {
 	})
 }
 
+func TestValidateImportStreamLooksLegal(t *testing.T) {
+	cases := []struct {
+		assertion string
+		input     []byte
+		err       error
+	}{
+		{"accepts an mcap stream", mcap.Magic, nil},
+		{"accepts a bag stream", []byte("#ROSBAG V2.0\nrest of bag"), nil},
+		{"rejects a stream that is neither bag nor mcap", make([]byte, 20), ErrInvalidInput},
+		{"rejects a short stream", []byte("#ROS"), ErrInvalidInput},
+	}
+	for _, c := range cases {
+		t.Run(c.assertion, func(t *testing.T) {
+			reader := bufio.NewReader(bytes.NewReader(c.input))
+			assert.ErrorIs(t, validateImportStreamLooksLegal(reader), c.err)
+			// The magic bytes must remain available to the upload.
+			rest, err := io.ReadAll(reader)
+			assert.Nil(t, err)
+			assert.Equal(t, c.input, rest)
+		})
+	}
+}
+
 func TestRenderCSV(t *testing.T) {
 	records := []TestRecord{
 		{A: "a", B: "b"},
@@ -95,16 +126,84 @@ func TestRenderList(t *testing.T) {
 			"csv",
 			"csv",
 		},
+		{
+			"ndjson",
+			"ndjson",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.assertion, func(t *testing.T) {
 			buf := &bytes.Buffer{}
-			err := renderList(buf, nil, func(any) ([]TestRecord, error) { return records, nil }, c.format)
+			err := renderList(context.Background(), buf, nil, func(context.Context, any) ([]TestRecord, error) { return records, nil }, c.format)
 			assert.Nil(t, err)
 		})
 	}
 }
 
+func TestRenderPagedList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv, err := api.NewMockServer(ctx)
+	assert.Nil(t, err)
+	client := api.NewMockAuthedClient(t, sv.BaseURL())
+	total := len(sv.RegisteredRecordings())
+
+	t.Run("returns a single page without --all", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.RecordingsRequest{Limit: 10}, client.Recordings, "ndjson", false)
+		assert.Nil(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 10)
+	})
+	t.Run("streams every page as NDJSON", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.RecordingsRequest{Limit: 10}, client.Recordings, "ndjson", true)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, total)
+		for _, line := range lines {
+			record := api.RecordingsResponse{}
+			assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		}
+	})
+	t.Run("streams every page as a single JSON array", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.EventsRequest{Limit: 7}, client.Events, "json", true)
+		assert.Nil(t, err)
+		records := []api.EventResponseItem{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &records))
+		assert.Equal(t, sv.RegisteredEvents(), records)
+	})
+	t.Run("writes the CSV header once", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.RecordingsRequest{Limit: 10}, client.Recordings, "csv", true)
+		assert.Nil(t, err)
+		rows, err := csv.NewReader(buf).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, rows, total+1)
+		assert.Equal(t, api.RecordingsResponse{}.Headers(), rows[0])
+	})
+	t.Run("renders every page as one table", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.RecordingsRequest{Limit: 10}, client.Recordings, "table", true)
+		assert.Nil(t, err)
+		// Tests have no terminal, so each record is printed under its number,
+		// which continues across pages.
+		out := buf.String()
+		assert.Equal(t, 1, strings.Count(out, "-[ RECORD 1 ]"))
+		assert.Contains(t, out, fmt.Sprintf("-[ RECORD %d ]", total))
+		assert.NotContains(t, out, fmt.Sprintf("-[ RECORD %d ]", total+1))
+		for _, recording := range sv.RegisteredRecordings() {
+			assert.Contains(t, out, recording.ID)
+		}
+	})
+	t.Run("renders an empty JSON array", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := renderPagedList(ctx, buf, &api.RecordingsRequest{Offset: total}, client.Recordings, "json", true)
+		assert.Nil(t, err)
+		assert.Equal(t, "[]\n", buf.String())
+	})
+}
+
 func TestMaybeConvertToRFC3339(t *testing.T) {
 	cases := []struct {
 		assertion string
@@ -147,6 +246,22 @@ func TestMaybeConvertToRFC3339(t *testing.T) {
 			assert.Equal(t, c.err, err)
 		})
 	}
+
+	t.Run("interprets timestamps without an offset in the configured zone", func(t *testing.T) {
+		berlin, err := time.LoadLocation("Europe/Berlin")
+		assert.Nil(t, err)
+		timeZone = berlin
+		t.Cleanup(func() { timeZone = time.UTC })
+		output, err := maybeConvertToRFC3339("2021-01-01T12:00:00")
+		assert.Nil(t, err)
+		assert.Equal(t, "2021-01-01T12:00:00+01:00", output)
+		output, err = maybeConvertToRFC3339("2021-01-01T12:00:00Z")
+		assert.Nil(t, err)
+		assert.Equal(t, "2021-01-01T12:00:00Z", output)
+		output, err = maybeConvertToRFC3339("2021-01-01T12:00:00-05:00")
+		assert.Nil(t, err)
+		assert.Equal(t, "2021-01-01T12:00:00-05:00", output)
+	})
 }
 
 func TestValidateSessionKeyRequiresProjectID(t *testing.T) {
@@ -9,8 +9,9 @@ import (
 // versionCmd represents the version command
 func newVersionCommand(version string) *cobra.Command {
 	versionCmd := &cobra.Command{
-		Use:   "version",
-		Short: "print Foxglove CLI version",
+		Use:         "version",
+		Short:       "print Foxglove CLI version",
+		Annotations: map[string]string{noCredentials: "true"},
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println(version)
 		},
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -17,12 +18,12 @@ type PropertyDefinition struct {
 
 // Validate CLI properties input & convert to args for a device request.
 // This requires downloading the available properties for the org.
-func DeviceProperties(propertyPairs []string, client *api.FoxgloveClient) (map[string]interface{}, error) {
+func DeviceProperties(ctx context.Context, propertyPairs []string, client *api.FoxgloveClient) (map[string]interface{}, error) {
 	if len(propertyPairs) == 0 {
 		return nil, nil
 	}
 
-	propertyMap, err := fetchAvailableProperties(client)
+	propertyMap, err := fetchAvailableProperties(ctx, client)
 	if err != nil {
 		return nil, fmt.Errorf("%s", err)
 	}
@@ -66,8 +67,8 @@ func DeviceProperties(propertyPairs []string, client *api.FoxgloveClient) (map[s
 }
 
 // Download device custom properties and convert to a lookup map
-func fetchAvailableProperties(client *api.FoxgloveClient) (OrgCustomProperties, error) {
-	propertiesResp, err := client.DeviceCustomProperties(api.CustomPropertiesRequest{
+func fetchAvailableProperties(ctx context.Context, client *api.FoxgloveClient) (OrgCustomProperties, error) {
+	propertiesResp, err := client.DeviceCustomProperties(ctx, api.CustomPropertiesRequest{
 		ResourceType: "device",
 	})
 	if err != nil {
@@ -26,7 +26,7 @@ func TestDeviceProperties(t *testing.T) {
 		client := newAuthedClient(t, sv.BaseURL())
 
 		input := []string{"foo:bar"}
-		_, err = DeviceProperties(input, client)
+		_, err = DeviceProperties(ctx, input, client)
 		assert.Equal(t, fmt.Errorf("unknown key: foo"), err)
 	})
 
@@ -36,7 +36,7 @@ func TestDeviceProperties(t *testing.T) {
 		numProp := propertyOfType("number")
 
 		input := []string{fmt.Sprintf("%s:foo", numProp.Key)}
-		_, err := DeviceProperties(input, client)
+		_, err := DeviceProperties(ctx, input, client)
 		assert.Equal(t, err, fmt.Errorf("invalid value for number: foo"))
 	})
 
@@ -54,7 +54,7 @@ func TestDeviceProperties(t *testing.T) {
 			fmt.Sprintf("%s:1.5", numProp.Key),
 			fmt.Sprintf("%s:%s", enumProp.Key, enumProp.Values[0]),
 		}
-		properties, err := DeviceProperties(input, client)
+		properties, err := DeviceProperties(ctx, input, client)
 		assert.Nil(t, err)
 		assert.Equal(t, properties, map[string]interface{}{
 			strProp.Key:  "bar",
@@ -72,7 +72,7 @@ func newAuthedClient(t *testing.T, baseUrl string) *api.FoxgloveClient {
 		"",
 		"user-agent",
 	)
-	token, err := client.SignIn("client-id")
+	token, err := client.SignIn(context.Background(), "client-id")
 	assert.Nil(t, err)
 	return api.NewRemoteFoxgloveClient(
 		baseUrl,
@@ -0,0 +1,464 @@
+// Package qrcode encodes short strings, such as login URLs, as QR codes that
+// can be printed to a terminal. It supports byte mode at error correction
+// level L, in versions 1 to 10, which holds up to 271 bytes.
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code is an encoded QR code.
+type Code struct {
+	// Size is the width and height of the code in modules.
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// versionInfo describes the error correction blocks of a version at level L.
+type versionInfo struct {
+	ecPerBlock int
+	// dataPerBlock holds the number of data codewords of each block.
+	dataPerBlock []int
+	alignment    []int
+}
+
+var versions = []versionInfo{
+	1:  {7, []int{19}, nil},
+	2:  {10, []int{34}, []int{6, 18}},
+	3:  {15, []int{55}, []int{6, 22}},
+	4:  {20, []int{80}, []int{6, 26}},
+	5:  {26, []int{108}, []int{6, 30}},
+	6:  {18, []int{68, 68}, []int{6, 34}},
+	7:  {20, []int{78, 78}, []int{6, 22, 38}},
+	8:  {24, []int{97, 97}, []int{6, 24, 42}},
+	9:  {30, []int{116, 116}, []int{6, 26, 46}},
+	10: {18, []int{68, 68, 69, 69}, []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	total := 0
+	for _, n := range v.dataPerBlock {
+		total += n
+	}
+	return total
+}
+
+// Encode returns the smallest QR code holding data.
+func Encode(data string) (*Code, error) {
+	for version := 1; version < len(versions); version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		capacity := versions[version].dataCodewords() * 8
+		if 4+countBits+8*len(data) <= capacity {
+			return encode(version, countBits, []byte(data)), nil
+		}
+	}
+	return nil, fmt.Errorf("data too long for a QR code: %d bytes", len(data))
+}
+
+func encode(version int, countBits int, data []byte) *Code {
+	info := versions[version]
+	bits := &bitBuffer{}
+	bits.append(0b0100, 4) // byte mode
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := info.dataCodewords() * 8
+	bits.append(0, min(4, capacity-bits.len()))
+	bits.append(0, (8-bits.len()%8)%8)
+	for pad := 0xEC; bits.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	q := newCode(version)
+	q.drawCodewords(interleave(info, bits.bytes()))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask) // masks are their own inverse
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return &Code{Size: q.size, modules: q.modules}
+}
+
+// interleave splits data into blocks, appends error correction codewords to
+// each, and interleaves the results.
+func interleave(info versionInfo, data []byte) []byte {
+	divisor := reedSolomonDivisor(info.ecPerBlock)
+	blocks := [][]byte{}
+	ecBlocks := [][]byte{}
+	maxData := 0
+	for _, n := range info.dataPerBlock {
+		block := data[:n]
+		data = data[n:]
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(block, divisor))
+		maxData = max(maxData, n)
+	}
+	result := []byte{}
+	for i := 0; i < maxData; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, (value>>i)&1 == 1)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, len(b.bits)/8)
+	for i, bit := range b.bits {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// code is a QR code under construction. Function modules, such as the finder
+// patterns, are excluded from data placement and masking.
+type code struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newCode(version int) *code {
+	size := 17 + 4*version
+	q := &code{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+	positions := versions[version].alignment
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			corner := (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0)
+			if !corner {
+				q.drawAlignment(x, y)
+			}
+		}
+	}
+	// Reserve the format areas until a mask is chosen.
+	q.drawFormat(0)
+	q.drawVersion()
+	return q
+}
+
+func (q *code) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+func (q *code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= q.size || y < 0 || y >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (q *code) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information, which records the
+// error correction level and mask.
+func (q *code) drawFormat(mask int) {
+	data := 0b01<<3 | mask // level L
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(i))
+	}
+	q.setFunction(8, q.size-8, true)
+}
+
+// drawVersion draws the version information carried by versions 7 and up.
+func (q *code) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+	rem := q.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := q.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.setFunction(a, b, dark)
+		q.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places data in the zigzag order, in pairs of columns from
+// the right, skipping the vertical timing pattern.
+func (q *code) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			y := vert
+			if upward {
+				y = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !q.isFunction[y][x] && i < len(data)*8 {
+					q.modules[y][x] = (data[i/8]>>(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *code) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.isFunction[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code may be to scan, following the rules used
+// to choose a mask.
+func (q *code) penalty() int {
+	penalty := 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= q.size; i++ {
+			if i < q.size && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				penalty += 3 + run - 5
+			}
+			run = 1
+		}
+		// Finder-like patterns: dark-light-dark×3-light-dark with four
+		// light modules on either side.
+		pattern := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= q.size; i++ {
+			match := true
+			for j, dark := range pattern {
+				if get(i+j) != dark {
+					match = false
+					break
+				}
+			}
+			if match && (lightRun(get, i-4, i, q.size) || lightRun(get, i+7, i+11, q.size)) {
+				penalty += 40
+			}
+		}
+	}
+	for y := 0; y < q.size; y++ {
+		line(func(x int) bool { return q.modules[y][x] })
+	}
+	for x := 0; x < q.size; x++ {
+		line(func(y int) bool { return q.modules[y][x] })
+	}
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size && c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				penalty += 3
+			}
+		}
+	}
+	total := q.size * q.size
+	penalty += 10 * (abs(dark*20-total*10) / total)
+	return penalty
+}
+
+// lightRun reports whether modules from start to end are all light, treating
+// modules beyond the edge as light.
+func lightRun(get func(int) bool, start, end, size int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < size && get(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// reedSolomonDivisor returns the generator polynomial of the given degree,
+// omitting its leading coefficient.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// String renders the code for a terminal, two rows of modules per line,
+// surrounded by the quiet zone the standard requires. Light modules are drawn as
+// blocks, so the code reads correctly on a dark background.
+func (c *Code) String() string {
+	const quiet = 4
+	light := func(x, y int) bool {
+		if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+			return true
+		}
+		return !c.modules[y][x]
+	}
+	sb := &strings.Builder{}
+	for y := -quiet; y < c.Size+quiet; y += 2 {
+		for x := -quiet; x < c.Size+quiet; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,76 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("encodes a url", func(t *testing.T) {
+		code, err := Encode("https://foxglove.dev")
+		assert.Nil(t, err)
+		expected := []string{
+			"#######..###.##...#######",
+			"#.....#....##.#...#.....#",
+			"#.###.#..###....#.#.###.#",
+			"#.###.#.#.##.#.##.#.###.#",
+			"#.###.#.#.#.#.....#.###.#",
+			"#.....#..##...#.#.#.....#",
+			"#######.#.#.#.#.#.#######",
+			".........#..#.#.#........",
+			"##...###.##.####....##...",
+			"#.##.#..#.#....#...#####.",
+			"#.#..###.#.#####.#..##.##",
+			"##..##.##.##...###.#.#..#",
+			"#.#.#######..###..##....#",
+			"####...#..#.##.###.#...#.",
+			"#.#..##..##..####..###.##",
+			"#.#..#.#....####..##.##.#",
+			"#...####.#..#.#.#####.#..",
+			"........#.......#...#....",
+			"#######.#.#.###.#.#.#...#",
+			"#.....#.####..###...#...#",
+			"#.###.#..###...######.###",
+			"#.###.#..#..##..###....##",
+			"#.###.#..#...##.#....##.#",
+			"#.....#.#.#.##..#####...#",
+			"#######.#..###..#....#..#",
+		}
+		actual := []string{}
+		for y := 0; y < code.Size; y++ {
+			row := ""
+			for x := 0; x < code.Size; x++ {
+				if code.Dark(x, y) {
+					row += "#"
+				} else {
+					row += "."
+				}
+			}
+			actual = append(actual, row)
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("chooses the smallest version", func(t *testing.T) {
+		for _, c := range []struct {
+			length int
+			size   int
+		}{{17, 21}, {18, 25}, {154, 45}, {155, 49}, {271, 57}} {
+			code, err := Encode(strings.Repeat("x", c.length))
+			assert.Nil(t, err)
+			assert.Equal(t, c.size, code.Size, c.length)
+		}
+	})
+	t.Run("rejects data that doesn't fit", func(t *testing.T) {
+		_, err := Encode(strings.Repeat("x", 272))
+		assert.ErrorContains(t, err, "too long")
+	})
+	t.Run("renders two rows per line", func(t *testing.T) {
+		code, err := Encode("https://foxglove.dev")
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSuffix(code.String(), "\n"), "\n")
+		assert.Len(t, lines, (25+8+1)/2)
+		assert.Equal(t, 25+8, len([]rune(lines[0])))
+	})
+}
@@ -8,6 +8,19 @@ import (
 	"strings"
 )
 
+// Color enables bold table headers, using ANSI escape sequences.
+var Color bool
+
+// formatHeader formats a header padded to width, which excludes any escape
+// sequences.
+func formatHeader(s string, width int) string {
+	padded := fmt.Sprintf("%-*s", width, s)
+	if !Color {
+		return padded
+	}
+	return "\x1b[1m" + s + "\x1b[0m" + padded[len(s):]
+}
+
 func computeHotdogCellWidths(headers []string, data [][]string) (int, []int) {
 	cellWidths := make([]int, len(headers))
 	for i, header := range headers {
@@ -45,14 +58,12 @@ printHotDog outputs a table of records formatted like this:
 | dev_qOo9LfqjfymSj50y | hilti-handheld       | 2023-06-01T11:37:55Z | 2023-06-01T11:37:55Z |
 | dev_kmJaeAdpSyLkqORp | my-new-device        | 2023-05-26T16:40:38Z | 2023-05-26T16:40:38Z |
 */
-func printHotDog(w io.Writer, headers []string, data [][]string) {
-	_, cellWidths := computeHotdogCellWidths(headers, data)
-
+func printHotDog(w io.Writer, headers []string, cellWidths []int) {
 	// write the headers
 	fmt.Fprintf(w, "|")
-	for i, header := range headers {
-		padding := (cellWidths[i] - len(header)) / 2
-		fmt.Fprintf(w, "%s%s%s|", strings.Repeat(" ", padding), header, strings.Repeat(" ", padding))
+	for i, h := range headers {
+		padding := (cellWidths[i] - len(h)) / 2
+		fmt.Fprintf(w, "%s%s|", strings.Repeat(" ", padding), formatHeader(h, len(h)+padding))
 	}
 	fmt.Fprintln(w)
 
@@ -63,12 +74,13 @@ func printHotDog(w io.Writer, headers []string, data [][]string) {
 		fmt.Fprintf(w, "|")
 	}
 	fmt.Fprintln(w)
+}
 
-	// write the data
+func printHotDogRows(w io.Writer, cellWidths []int, data [][]string) {
 	for _, row := range data {
 		fmt.Fprint(w, "|")
 		for i, col := range row {
-			fmt.Fprintf(w, " %s%s|", col, strings.Repeat(" ", cellWidths[i]-len(col)-1))
+			fmt.Fprintf(w, " %s%s|", col, strings.Repeat(" ", max(cellWidths[i]-len(col)-1, 0)))
 		}
 		fmt.Fprintln(w)
 	}
@@ -83,7 +95,7 @@ printHamburger outputs a series of records formatted like this:
 	Created At    | 2021-11-17T18:23:49Z
 	Updated At    | 2021-11-17T18:23:49Z
 */
-func printHamburger(w io.Writer, termwidth int, headers []string, data [][]string) {
+func computeHamburgerWidths(termwidth int, headers []string, data [][]string) (int, int) {
 	var maxHeaderWidth int
 	var maxRecordWidth int
 
@@ -118,20 +130,25 @@ func printHamburger(w io.Writer, termwidth int, headers []string, data [][]strin
 	if dashesRightExtent > maxAllowedExtent {
 		dashesRightExtent = maxAllowedExtent
 	}
+	return maxHeaderWidth, dashesRightExtent
+}
+
+// printHamburger prints records, numbering them from first.
+func printHamburger(w io.Writer, maxHeaderWidth int, dashesRightExtent int, headers []string, data [][]string, first int) {
 	rightDashes := strings.Repeat("-", dashesRightExtent)
 
 	// print the records
 	for i, row := range data {
-		recordHeader := fmt.Sprintf("-[ RECORD %d ]", i+1)
+		recordHeader := fmt.Sprintf("-[ RECORD %d ]", first+i)
 		header := fmt.Sprintf(
 			"%s%s+%s",
 			recordHeader,
-			strings.Repeat("-", maxHeaderWidth-len(recordHeader)),
+			strings.Repeat("-", max(maxHeaderWidth-len(recordHeader), 0)),
 			rightDashes,
 		)
 		fmt.Fprintln(w, header)
 		for j, col := range row {
-			fmt.Fprintf(w, "%-*s| %-*s", maxHeaderWidth, headers[j], dashesRightExtent-1, col)
+			fmt.Fprintf(w, "%s| %-*s", formatHeader(headers[j], maxHeaderWidth), dashesRightExtent-1, col)
 			fmt.Fprintln(w)
 		}
 	}
@@ -153,11 +170,48 @@ func getTermWidth() int {
 }
 
 func PrintTable(w io.Writer, headers []string, data [][]string) {
-	termWidth := getTermWidth()
-	tableWidth, _ := computeHotdogCellWidths(headers, data)
-	if termWidth < tableWidth {
-		printHamburger(w, termWidth, headers, data)
-		return
+	NewWriter(w, headers).Write(data)
+}
+
+// Writer prints a table whose rows arrive in batches, such as the pages of a
+// listing, without holding them all. The layout and column widths are chosen
+// from the first batch, as PrintTable would for it alone; a longer value in a
+// later batch extends its own line rather than the column.
+type Writer struct {
+	w       io.Writer
+	headers []string
+	started bool
+	records int
+
+	hamburger         bool
+	cellWidths        []int
+	maxHeaderWidth    int
+	dashesRightExtent int
+}
+
+// NewWriter returns a Writer of a table with the given headers to w.
+func NewWriter(w io.Writer, headers []string) *Writer {
+	return &Writer{w: w, headers: headers}
+}
+
+// Write prints the next rows of the table.
+func (t *Writer) Write(data [][]string) {
+	if !t.started {
+		t.started = true
+		termWidth := getTermWidth()
+		tableWidth, cellWidths := computeHotdogCellWidths(t.headers, data)
+		if termWidth < tableWidth {
+			t.hamburger = true
+			t.maxHeaderWidth, t.dashesRightExtent = computeHamburgerWidths(termWidth, t.headers, data)
+		} else {
+			t.cellWidths = cellWidths
+			printHotDog(t.w, t.headers, cellWidths)
+		}
+	}
+	if t.hamburger {
+		printHamburger(t.w, t.maxHeaderWidth, t.dashesRightExtent, t.headers, data, t.records+1)
+	} else {
+		printHotDogRows(t.w, t.cellWidths, data)
 	}
-	printHotDog(w, headers, data)
+	t.records += len(data)
 }